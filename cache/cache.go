@@ -3,33 +3,83 @@
 package cache
 
 import (
+	"strings"
 	"sync"
 	"time"
 
 	lru "github.com/hashicorp/golang-lru/v2"
 )
 
+const (
+	// PublicIndex is the cache key for the default public index.
+	PublicIndex = "public"
+	// PrivateIndex is the cache key for the default private index.
+	PrivateIndex = "private"
+)
+
 // PackageInfo represents information about a package in an index.
 type PackageInfo struct {
 	Exists     bool
 	LastUpdate time.Time
 }
 
-// PackagePageInfo represents cached HTML content for a package page.
+// PackagePageInfo represents cached HTML content for a package page, along
+// with the HTTP validators the index served it with.
 type PackagePageInfo struct {
-	HTML       []byte
+	HTML         []byte
+	ETag         string
+	LastModified string
+	LastUpdate   time.Time
+}
+
+// hasValidators reports whether a page can be revalidated with its index.
+func (info PackagePageInfo) hasValidators() bool {
+	return info.ETag != "" || info.LastModified != ""
+}
+
+// MetadataInfo represents cached core metadata of a distribution file.
+type MetadataInfo struct {
+	Data       []byte
+	LastUpdate time.Time
+}
+
+// UploadTimesInfo represents cached upload times of the files of a package.
+type UploadTimesInfo struct {
+	Times      map[string]time.Time
 	LastUpdate time.Time
 }
 
-// Cache represents the LRU cache for package information and HTML content.
+// ProjectJSONInfo represents a cached PyPI JSON API response for a project or
+// one of its releases.
+type ProjectJSONInfo struct {
+	Data       []byte
+	LastUpdate time.Time
+}
+
+// IndexStats holds the number of cached entries for a single index.
+type IndexStats struct {
+	Packages int
+	Pages    int
+	Metadata int
+}
+
+// indexCache holds the existence, page and metadata caches for a single index.
+type indexCache struct {
+	packages *lru.Cache[string, PackageInfo]
+	pages    *lru.Cache[string, PackagePageInfo]
+	metadata *lru.Cache[string, MetadataInfo]
+	uploads  *lru.Cache[string, UploadTimesInfo]
+	projects *lru.Cache[string, ProjectJSONInfo]
+}
+
+// Cache represents the LRU cache for package information and HTML content,
+// keyed by index name.
 type Cache struct {
-	publicCache      *lru.Cache[string, PackageInfo]
-	privateCache     *lru.Cache[string, PackageInfo]
-	publicPageCache  *lru.Cache[string, PackagePageInfo]
-	privatePageCache *lru.Cache[string, PackagePageInfo]
-	ttl              time.Duration
-	enabled          bool
-	mu               sync.RWMutex
+	indexes map[string]*indexCache
+	size    int
+	ttl     time.Duration
+	enabled bool
+	mu      sync.RWMutex
 }
 
 // NewCache creates a new cache instance.
@@ -38,194 +88,353 @@ func NewCache(size, ttlHours int, enabled bool) (*Cache, error) {
 		return &Cache{enabled: false}, nil
 	}
 
-	publicCache, err := lru.New[string, PackageInfo](size)
+	c := &Cache{
+		indexes: make(map[string]*indexCache),
+		size:    size,
+		ttl:     time.Duration(ttlHours) * time.Hour,
+		enabled: true,
+	}
+
+	// Create the default indexes up front so an invalid size is reported here.
+	for _, name := range []string{PublicIndex, PrivateIndex} {
+		ic, err := newIndexCache(size)
+		if err != nil {
+			return nil, err
+		}
+		c.indexes[name] = ic
+	}
+
+	return c, nil
+}
+
+// newIndexCache creates the LRU caches for a single index.
+func newIndexCache(size int) (*indexCache, error) {
+	packages, err := lru.New[string, PackageInfo](size)
+	if err != nil {
+		return nil, err
+	}
+
+	pages, err := lru.New[string, PackagePageInfo](size)
 	if err != nil {
 		return nil, err
 	}
 
-	privateCache, err := lru.New[string, PackageInfo](size)
+	metadata, err := lru.New[string, MetadataInfo](size)
 	if err != nil {
 		return nil, err
 	}
 
-	publicPageCache, err := lru.New[string, PackagePageInfo](size)
+	uploads, err := lru.New[string, UploadTimesInfo](size)
 	if err != nil {
 		return nil, err
 	}
 
-	privatePageCache, err := lru.New[string, PackagePageInfo](size)
+	projects, err := lru.New[string, ProjectJSONInfo](size)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Cache{
-		publicCache:      publicCache,
-		privateCache:     privateCache,
-		publicPageCache:  publicPageCache,
-		privatePageCache: privatePageCache,
-		ttl:              time.Duration(ttlHours) * time.Hour,
-		enabled:          true,
-	}, nil
+	return &indexCache{packages: packages, pages: pages, metadata: metadata, uploads: uploads, projects: projects}, nil
 }
 
-// GetPublicPackage checks if a package exists in the public index.
-func (c *Cache) GetPublicPackage(packageName string) (PackageInfo, bool) {
+// lookup returns the cache for an index, or nil if nothing was stored for it yet.
+func (c *Cache) lookup(index string) *indexCache {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.indexes[index]
+}
+
+// index returns the cache for an index, creating it on first use.
+func (c *Cache) index(index string) *indexCache {
+	if ic := c.lookup(index); ic != nil {
+		return ic
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if ic, ok := c.indexes[index]; ok {
+		return ic
+	}
+	// The size was validated when the default indexes were created.
+	ic, err := newIndexCache(c.size)
+	if err != nil {
+		return nil
+	}
+	c.indexes[index] = ic
+	return ic
+}
+
+// GetPackage checks if a package exists in the named index.
+func (c *Cache) GetPackage(index, packageName string) (PackageInfo, bool) {
 	if !c.enabled {
 		return PackageInfo{}, false
 	}
 
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	ic := c.lookup(index)
+	if ic == nil {
+		return PackageInfo{}, false
+	}
 
-	info, exists := c.publicCache.Get(packageName)
+	info, exists := ic.packages.Get(packageName)
 	if !exists {
 		return PackageInfo{}, false
 	}
 
 	// Check if entry has expired
 	if time.Since(info.LastUpdate) > c.ttl {
-		c.publicCache.Remove(packageName)
+		ic.packages.Remove(packageName)
 		return PackageInfo{}, false
 	}
 
 	return info, true
 }
 
-// GetPrivatePackage checks if a package exists in the private index.
-func (c *Cache) GetPrivatePackage(packageName string) (PackageInfo, bool) {
+// SetPackage sets package information for the named index.
+func (c *Cache) SetPackage(index, packageName string, exists bool) {
 	if !c.enabled {
-		return PackageInfo{}, false
+		return
 	}
 
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	ic := c.index(index)
+	if ic == nil {
+		return
+	}
 
-	info, exists := c.privateCache.Get(packageName)
+	info := PackageInfo{
+		Exists:     exists,
+		LastUpdate: time.Now(),
+	}
+
+	ic.packages.Add(packageName, info)
+}
+
+// GetPackagePage retrieves cached HTML content for a package page from the named index.
+func (c *Cache) GetPackagePage(index, packageName string) (PackagePageInfo, bool) {
+	if !c.enabled {
+		return PackagePageInfo{}, false
+	}
+
+	ic := c.lookup(index)
+	if ic == nil {
+		return PackagePageInfo{}, false
+	}
+
+	info, exists := ic.pages.Get(packageName)
 	if !exists {
-		return PackageInfo{}, false
+		return PackagePageInfo{}, false
 	}
 
-	// Check if entry has expired
+	// Check if entry has expired; pages with validators are kept so they can
+	// be revalidated instead of fetched again
 	if time.Since(info.LastUpdate) > c.ttl {
-		c.privateCache.Remove(packageName)
-		return PackageInfo{}, false
+		if !info.hasValidators() {
+			ic.pages.Remove(packageName)
+		}
+		return PackagePageInfo{}, false
 	}
 
 	return info, true
 }
 
-// SetPublicPackage sets package information for the public index.
-func (c *Cache) SetPublicPackage(packageName string, exists bool) {
+// GetStalePackagePage retrieves a cached package page from the named index
+// even when it has expired, so it can be revalidated with the index.
+func (c *Cache) GetStalePackagePage(index, packageName string) (PackagePageInfo, bool) {
+	if !c.enabled {
+		return PackagePageInfo{}, false
+	}
+
+	ic := c.lookup(index)
+	if ic == nil {
+		return PackagePageInfo{}, false
+	}
+
+	return ic.pages.Peek(packageName)
+}
+
+// SetPackagePage sets HTML content for a package page from the named index.
+func (c *Cache) SetPackagePage(index, packageName string, html []byte) {
 	if !c.enabled {
 		return
 	}
 
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	ic := c.index(index)
+	if ic == nil {
+		return
+	}
 
-	info := PackageInfo{
-		Exists:     exists,
+	info := PackagePageInfo{
+		HTML:       html,
 		LastUpdate: time.Now(),
 	}
 
-	c.publicCache.Add(packageName, info)
+	ic.pages.Add(packageName, info)
 }
 
-// SetPrivatePackage sets package information for the private index.
-func (c *Cache) SetPrivatePackage(packageName string, exists bool) {
+// SetPackagePageInfo sets a package page from the named index along with its
+// validators, restarting its TTL.
+func (c *Cache) SetPackagePageInfo(index, packageName string, info PackagePageInfo) {
 	if !c.enabled {
 		return
 	}
 
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	info := PackageInfo{
-		Exists:     exists,
-		LastUpdate: time.Now(),
+	ic := c.index(index)
+	if ic == nil {
+		return
 	}
 
-	c.privateCache.Add(packageName, info)
+	info.LastUpdate = time.Now()
+	ic.pages.Add(packageName, info)
 }
 
-// GetPublicPackagePage retrieves cached HTML content for a public package page.
-func (c *Cache) GetPublicPackagePage(packageName string) (PackagePageInfo, bool) {
+// GetMetadata retrieves cached core metadata of a file from the named index.
+func (c *Cache) GetMetadata(index, fileName string) (MetadataInfo, bool) {
 	if !c.enabled {
-		return PackagePageInfo{}, false
+		return MetadataInfo{}, false
 	}
 
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	ic := c.lookup(index)
+	if ic == nil {
+		return MetadataInfo{}, false
+	}
 
-	info, exists := c.publicPageCache.Get(packageName)
+	info, exists := ic.metadata.Get(fileName)
 	if !exists {
-		return PackagePageInfo{}, false
+		return MetadataInfo{}, false
 	}
 
 	// Check if entry has expired
 	if time.Since(info.LastUpdate) > c.ttl {
-		c.publicPageCache.Remove(packageName)
-		return PackagePageInfo{}, false
+		ic.metadata.Remove(fileName)
+		return MetadataInfo{}, false
 	}
 
 	return info, true
 }
 
-// GetPrivatePackagePage retrieves cached HTML content for a private package page.
-func (c *Cache) GetPrivatePackagePage(packageName string) (PackagePageInfo, bool) {
+// SetMetadata sets core metadata of a file from the named index.
+func (c *Cache) SetMetadata(index, fileName string, data []byte) {
 	if !c.enabled {
-		return PackagePageInfo{}, false
+		return
 	}
 
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	ic := c.index(index)
+	if ic == nil {
+		return
+	}
+
+	info := MetadataInfo{
+		Data:       data,
+		LastUpdate: time.Now(),
+	}
+
+	ic.metadata.Add(fileName, info)
+}
+
+// GetUploadTimes retrieves cached upload times of the files of a package from the named index.
+func (c *Cache) GetUploadTimes(index, packageName string) (UploadTimesInfo, bool) {
+	if !c.enabled {
+		return UploadTimesInfo{}, false
+	}
+
+	ic := c.lookup(index)
+	if ic == nil {
+		return UploadTimesInfo{}, false
+	}
 
-	info, exists := c.privatePageCache.Get(packageName)
+	info, exists := ic.uploads.Get(packageName)
 	if !exists {
-		return PackagePageInfo{}, false
+		return UploadTimesInfo{}, false
 	}
 
 	// Check if entry has expired
 	if time.Since(info.LastUpdate) > c.ttl {
-		c.privatePageCache.Remove(packageName)
-		return PackagePageInfo{}, false
+		ic.uploads.Remove(packageName)
+		return UploadTimesInfo{}, false
 	}
 
 	return info, true
 }
 
-// SetPublicPackagePage sets HTML content for a public package page.
-func (c *Cache) SetPublicPackagePage(packageName string, html []byte) {
+// SetUploadTimes sets upload times of the files of a package from the named index.
+func (c *Cache) SetUploadTimes(index, packageName string, times map[string]time.Time) {
 	if !c.enabled {
 		return
 	}
 
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	ic := c.index(index)
+	if ic == nil {
+		return
+	}
 
-	info := PackagePageInfo{
-		HTML:       html,
+	info := UploadTimesInfo{
+		Times:      times,
 		LastUpdate: time.Now(),
 	}
 
-	c.publicPageCache.Add(packageName, info)
+	ic.uploads.Add(packageName, info)
 }
 
-// SetPrivatePackagePage sets HTML content for a private package page.
-func (c *Cache) SetPrivatePackagePage(packageName string, html []byte) {
+// InvalidatePackage drops the cached page, upload times and JSON API
+// responses of a package on the named index, e.g. after a file was uploaded.
+func (c *Cache) InvalidatePackage(index, packageName string) {
 	if !c.enabled {
 		return
 	}
 
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	ic := c.lookup(index)
+	if ic == nil {
+		return
+	}
 
-	info := PackagePageInfo{
-		HTML:       html,
-		LastUpdate: time.Now(),
+	ic.pages.Remove(packageName)
+	ic.uploads.Remove(packageName)
+	for _, key := range ic.projects.Keys() {
+		if key == packageName || strings.HasPrefix(key, packageName+"/") {
+			ic.projects.Remove(key)
+		}
 	}
+}
 
-	c.privatePageCache.Add(packageName, info)
+// GetPublicPackage checks if a package exists in the public index.
+func (c *Cache) GetPublicPackage(packageName string) (PackageInfo, bool) {
+	return c.GetPackage(PublicIndex, packageName)
+}
+
+// GetPrivatePackage checks if a package exists in the private index.
+func (c *Cache) GetPrivatePackage(packageName string) (PackageInfo, bool) {
+	return c.GetPackage(PrivateIndex, packageName)
+}
+
+// SetPublicPackage sets package information for the public index.
+func (c *Cache) SetPublicPackage(packageName string, exists bool) {
+	c.SetPackage(PublicIndex, packageName, exists)
+}
+
+// SetPrivatePackage sets package information for the private index.
+func (c *Cache) SetPrivatePackage(packageName string, exists bool) {
+	c.SetPackage(PrivateIndex, packageName, exists)
+}
+
+// GetPublicPackagePage retrieves cached HTML content for a public package page.
+func (c *Cache) GetPublicPackagePage(packageName string) (PackagePageInfo, bool) {
+	return c.GetPackagePage(PublicIndex, packageName)
+}
+
+// GetPrivatePackagePage retrieves cached HTML content for a private package page.
+func (c *Cache) GetPrivatePackagePage(packageName string) (PackagePageInfo, bool) {
+	return c.GetPackagePage(PrivateIndex, packageName)
+}
+
+// SetPublicPackagePage sets HTML content for a public package page.
+func (c *Cache) SetPublicPackagePage(packageName string, html []byte) {
+	c.SetPackagePage(PublicIndex, packageName, html)
+}
+
+// SetPrivatePackagePage sets HTML content for a private package page.
+func (c *Cache) SetPrivatePackagePage(packageName string, html []byte) {
+	c.SetPackagePage(PrivateIndex, packageName, html)
 }
 
 // Clear clears all cached data.
@@ -234,26 +443,82 @@ func (c *Cache) Clear() {
 		return
 	}
 
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 
-	c.publicCache.Purge()
-	c.privateCache.Purge()
-	c.publicPageCache.Purge()
-	c.privatePageCache.Purge()
+	for _, ic := range c.indexes {
+		ic.packages.Purge()
+		ic.pages.Purge()
+		ic.metadata.Purge()
+		ic.uploads.Purge()
+		ic.projects.Purge()
+	}
 }
 
-// ClearPrivateOnly clears only the private caches for testing purposes.
+// ClearPrivateOnly clears every index except the public one, for testing purposes.
 func (c *Cache) ClearPrivateOnly() {
 	if !c.enabled {
 		return
 	}
 
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for name, ic := range c.indexes {
+		if name == PublicIndex {
+			continue
+		}
+		ic.packages.Purge()
+		ic.pages.Purge()
+		ic.metadata.Purge()
+		ic.uploads.Purge()
+		ic.projects.Purge()
+	}
+}
+
+// GetProjectJSON retrieves a cached JSON API response from the named index.
+// The key is the project name, followed by "/" and the version for releases.
+func (c *Cache) GetProjectJSON(index, key string) (ProjectJSONInfo, bool) {
+	if !c.enabled {
+		return ProjectJSONInfo{}, false
+	}
+
+	ic := c.lookup(index)
+	if ic == nil {
+		return ProjectJSONInfo{}, false
+	}
+
+	info, exists := ic.projects.Get(key)
+	if !exists {
+		return ProjectJSONInfo{}, false
+	}
+
+	// Check if entry has expired
+	if time.Since(info.LastUpdate) > c.ttl {
+		ic.projects.Remove(key)
+		return ProjectJSONInfo{}, false
+	}
 
-	c.privateCache.Purge()
-	c.privatePageCache.Purge()
+	return info, true
+}
+
+// SetProjectJSON sets a JSON API response from the named index.
+func (c *Cache) SetProjectJSON(index, key string, data []byte) {
+	if !c.enabled {
+		return
+	}
+
+	ic := c.index(index)
+	if ic == nil {
+		return
+	}
+
+	info := ProjectJSONInfo{
+		Data:       data,
+		LastUpdate: time.Now(),
+	}
+
+	ic.projects.Add(key, info)
 }
 
 // IsEnabled returns whether the cache is enabled.
@@ -261,14 +526,33 @@ func (c *Cache) IsEnabled() bool {
 	return c.enabled
 }
 
-// GetStats returns cache statistics.
+// GetStats returns cache statistics. Entries of every index other than the
+// public one are counted as private.
 func (c *Cache) GetStats() (publicCount, privateCount, publicPageCount, privatePageCount int) {
+	for name, stats := range c.IndexStats() {
+		if name == PublicIndex {
+			publicCount += stats.Packages
+			publicPageCount += stats.Pages
+			continue
+		}
+		privateCount += stats.Packages
+		privatePageCount += stats.Pages
+	}
+	return publicCount, privateCount, publicPageCount, privatePageCount
+}
+
+// IndexStats returns the number of cached entries for each index.
+func (c *Cache) IndexStats() map[string]IndexStats {
+	stats := make(map[string]IndexStats)
 	if !c.enabled {
-		return 0, 0, 0, 0
+		return stats
 	}
 
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	return c.publicCache.Len(), c.privateCache.Len(), c.publicPageCache.Len(), c.privatePageCache.Len()
+	for name, ic := range c.indexes {
+		stats[name] = IndexStats{Packages: ic.packages.Len(), Pages: ic.pages.Len(), Metadata: ic.metadata.Len()}
+	}
+	return stats
 }
@@ -257,3 +257,199 @@ func TestPackagePageCachingDisabled(t *testing.T) {
 		t.Error("Expected not to find package page when cache is disabled")
 	}
 }
+
+func TestCachePerIndex(t *testing.T) {
+	cache, err := NewCache(10, 1, true)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Indexes other than the defaults are created on first use
+	cache.SetPackage("team", "torch", true)
+	cache.SetPackage("cuda", "torch", false)
+	cache.SetPackagePage("team", "torch", []byte("<html>team</html>"))
+
+	info, found := cache.GetPackage("team", "torch")
+	if !found || !info.Exists {
+		t.Error("Expected package to exist in team index")
+	}
+	info, found = cache.GetPackage("cuda", "torch")
+	if !found || info.Exists {
+		t.Error("Expected package to be cached as missing in cuda index")
+	}
+	if _, found := cache.GetPackage("org", "torch"); found {
+		t.Error("Expected no entry for an index that was never populated")
+	}
+	if _, found := cache.GetPackagePage("cuda", "torch"); found {
+		t.Error("Expected pages to be cached per index")
+	}
+
+	stats := cache.IndexStats()
+	if stats["team"].Packages != 1 || stats["team"].Pages != 1 || stats["cuda"].Packages != 1 {
+		t.Errorf("Unexpected index stats: %+v", stats)
+	}
+
+	// Custom indexes count as private in the aggregate stats
+	_, privateLen, _, privatePageLen := cache.GetStats()
+	if privateLen != 2 || privatePageLen != 1 {
+		t.Errorf("Expected 2 private packages and 1 private page, got %d and %d", privateLen, privatePageLen)
+	}
+
+	cache.ClearPrivateOnly()
+	if _, found := cache.GetPackage("team", "torch"); found {
+		t.Error("Expected custom index to be cleared with the private caches")
+	}
+}
+
+func TestMetadataCaching(t *testing.T) {
+	cache, err := NewCache(10, 1, true)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	metadata := []byte("Metadata-Version: 2.1\nName: demo\n")
+	cache.SetMetadata(PrivateIndex, "demo-1.0-py3-none-any.whl.metadata", metadata)
+
+	info, found := cache.GetMetadata(PrivateIndex, "demo-1.0-py3-none-any.whl.metadata")
+	if !found || string(info.Data) != string(metadata) {
+		t.Errorf("Expected cached metadata, got %q (found=%t)", info.Data, found)
+	}
+	if _, found := cache.GetMetadata(PublicIndex, "demo-1.0-py3-none-any.whl.metadata"); found {
+		t.Error("Expected metadata to be cached per index")
+	}
+	if stats := cache.IndexStats(); stats[PrivateIndex].Metadata != 1 {
+		t.Errorf("Expected 1 cached metadata entry, got %+v", stats[PrivateIndex])
+	}
+
+	cache.Clear()
+	if _, found := cache.GetMetadata(PrivateIndex, "demo-1.0-py3-none-any.whl.metadata"); found {
+		t.Error("Expected metadata to be cleared")
+	}
+
+	disabled, err := NewCache(10, 1, false)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	disabled.SetMetadata(PrivateIndex, "demo-1.0.tar.gz.metadata", metadata)
+	if _, found := disabled.GetMetadata(PrivateIndex, "demo-1.0.tar.gz.metadata"); found {
+		t.Error("Expected disabled cache to return no metadata")
+	}
+}
+
+func TestUploadTimesCaching(t *testing.T) {
+	cache, err := NewCache(10, 1, true)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	uploaded := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	cache.SetUploadTimes(PublicIndex, "demo", map[string]time.Time{"demo-1.0.tar.gz": uploaded})
+
+	info, found := cache.GetUploadTimes(PublicIndex, "demo")
+	if !found || !info.Times["demo-1.0.tar.gz"].Equal(uploaded) {
+		t.Errorf("Expected cached upload times, got %+v (found=%t)", info, found)
+	}
+	if _, found := cache.GetUploadTimes(PrivateIndex, "demo"); found {
+		t.Error("Expected upload times to be cached per index")
+	}
+
+	cache.ClearPrivateOnly()
+	if _, found := cache.GetUploadTimes(PublicIndex, "demo"); !found {
+		t.Error("Expected public upload times to survive ClearPrivateOnly")
+	}
+	cache.Clear()
+	if _, found := cache.GetUploadTimes(PublicIndex, "demo"); found {
+		t.Error("Expected upload times to be cleared")
+	}
+}
+
+func TestProjectJSONCaching(t *testing.T) {
+	cache, err := NewCache(10, 1, true)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	data := []byte(`{"info": {"name": "demo"}}`)
+	cache.SetProjectJSON(PublicIndex, "demo", data)
+
+	info, found := cache.GetProjectJSON(PublicIndex, "demo")
+	if !found || string(info.Data) != string(data) {
+		t.Errorf("Expected cached JSON, got %q (found=%t)", info.Data, found)
+	}
+	if _, found := cache.GetProjectJSON(PublicIndex, "demo/1.0"); found {
+		t.Error("Expected releases to be cached separately from projects")
+	}
+	if _, found := cache.GetProjectJSON(PrivateIndex, "demo"); found {
+		t.Error("Expected JSON to be cached per index")
+	}
+
+	cache.Clear()
+	if _, found := cache.GetProjectJSON(PublicIndex, "demo"); found {
+		t.Error("Expected JSON to be cleared")
+	}
+}
+
+func TestInvalidatePackage(t *testing.T) {
+	cache, err := NewCache(10, 1, true)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	cache.SetPrivatePackage("demo", false)
+	cache.SetPrivatePackagePage("demo", []byte("<html></html>"))
+	cache.SetProjectJSON(PrivateIndex, "demo", []byte("{}"))
+	cache.SetProjectJSON(PrivateIndex, "demo/1.0", []byte("{}"))
+	cache.SetProjectJSON(PrivateIndex, "demo-extra", []byte("{}"))
+	cache.SetPublicPackagePage("demo", []byte("<html></html>"))
+
+	cache.InvalidatePackage(PrivateIndex, "demo")
+
+	if _, found := cache.GetPrivatePackagePage("demo"); found {
+		t.Error("Expected the page to be invalidated")
+	}
+	if _, found := cache.GetProjectJSON(PrivateIndex, "demo/1.0"); found {
+		t.Error("Expected release JSON to be invalidated")
+	}
+	if _, found := cache.GetProjectJSON(PrivateIndex, "demo-extra"); !found {
+		t.Error("Expected other projects to stay cached")
+	}
+	if _, found := cache.GetPublicPackagePage("demo"); !found {
+		t.Error("Expected other indexes to stay cached")
+	}
+	if _, found := cache.GetPrivatePackage("demo"); !found {
+		t.Error("Expected existence to be left to the caller")
+	}
+}
+
+func TestPackagePageRevalidation(t *testing.T) {
+	cache, err := NewCache(10, 1, true)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	cache.SetPackagePageInfo(PrivateIndex, "demo", PackagePageInfo{HTML: []byte("<html></html>"), ETag: `"v1"`})
+	cache.SetPrivatePackagePage("plain", []byte("<html></html>"))
+
+	info, found := cache.GetPrivatePackagePage("demo")
+	if !found || info.ETag != `"v1"` {
+		t.Errorf("Expected the page with its validators, got %+v (found=%t)", info, found)
+	}
+
+	// Expire both pages
+	for _, name := range []string{"demo", "plain"} {
+		info, _ := cache.GetStalePackagePage(PrivateIndex, name)
+		info.LastUpdate = time.Now().Add(-2 * time.Hour)
+		cache.lookup(PrivateIndex).pages.Add(name, info)
+	}
+
+	if _, found := cache.GetPrivatePackagePage("demo"); found {
+		t.Error("Expected the expired page not to be served")
+	}
+	if stale, found := cache.GetStalePackagePage(PrivateIndex, "demo"); !found || stale.ETag != `"v1"` {
+		t.Errorf("Expected the expired page to be kept for revalidation, got %+v (found=%t)", stale, found)
+	}
+	cache.GetPrivatePackagePage("plain")
+	if _, found := cache.GetStalePackagePage(PrivateIndex, "plain"); found {
+		t.Error("Expected expired pages without validators to be dropped")
+	}
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// AsOfIncludeUntimed lists private files without an upload time in as-of snapshots.
+	AsOfIncludeUntimed = "include"
+	// AsOfExcludeUntimed hides private files without an upload time from as-of snapshots.
+	AsOfExcludeUntimed = "exclude"
+)
+
+// ParseAsOf parses an as-of snapshot timestamp. RFC 3339 timestamps and plain
+// dates, which mean midnight UTC, are accepted.
+func ParseAsOf(value string) (time.Time, error) {
+	t, err := parseTimestamp(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid as-of timestamp: %w", err)
+	}
+	return t, nil
+}
+
+// parseTimestamp parses an RFC 3339 timestamp or a plain date, which means midnight UTC.
+func parseTimestamp(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%q is not an RFC 3339 timestamp such as 2026-09-01T00:00:00Z or a date", value)
+}
+
+// AsOfTime returns the configured snapshot timestamp, or the zero time when
+// the proxy serves the current state of its indexes.
+func (c *Config) AsOfTime() (time.Time, error) {
+	if c.AsOf == "" {
+		return time.Time{}, nil
+	}
+	return ParseAsOf(c.AsOf)
+}
+
+// IncludesUntimedPrivateFiles reports whether as-of snapshots list private
+// files whose upload time is unknown.
+func (c *Config) IncludesUntimedPrivateFiles() bool {
+	return c.AsOfUntimedPrivateFiles != AsOfExcludeUntimed
+}
+
+// validateAsOf checks the as-of snapshot settings.
+func (c *Config) validateAsOf() error {
+	if _, err := c.AsOfTime(); err != nil {
+		return fmt.Errorf("as_of: %w", err)
+	}
+	switch c.AsOfUntimedPrivateFiles {
+	case "", AsOfIncludeUntimed, AsOfExcludeUntimed:
+		return nil
+	default:
+		return fmt.Errorf("as_of_untimed_private_files: unknown policy %q", c.AsOfUntimedPrivateFiles)
+	}
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseAsOf(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Time
+		wantErr  bool
+	}{
+		{"2026-09-01T00:00:00Z", time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC), false},
+		{"2026-09-01T02:30:00+02:00", time.Date(2026, 9, 1, 0, 30, 0, 0, time.UTC), false},
+		{"2026-09-01", time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC), false},
+		{"yesterday", time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			result, err := ParseAsOf(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected an error for %q", tt.value)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if !result.Equal(tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateAsOf(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{"disabled", Config{}, false},
+		{"timestamp", Config{AsOf: "2026-09-01T00:00:00Z", AsOfUntimedPrivateFiles: AsOfExcludeUntimed}, false},
+		{"invalid timestamp", Config{AsOf: "last week"}, true},
+		{"invalid policy", Config{AsOfUntimedPrivateFiles: "maybe"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.cfg.validateAsOf(); (err != nil) != tt.wantErr {
+				t.Errorf("Expected error %t, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	if !(&Config{}).IncludesUntimedPrivateFiles() {
+		t.Error("Expected untimed private files to be included by default")
+	}
+}
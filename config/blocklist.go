@@ -0,0 +1,103 @@
+package config
+
+import (
+	"fmt"
+	"python-index-proxy/pypi"
+	"time"
+)
+
+// BlockEntry bans releases or files of a package without removing them
+// upstream. An entry names a project with an optional PEP 440 version
+// specifier, or an exact file name.
+type BlockEntry struct {
+	// Package is the project the entry applies to.
+	Package string `mapstructure:"package"`
+	// Versions is a PEP 440 specifier such as "==2.0.1" or ">=1.0,<1.2";
+	// empty blocks every version of the package.
+	Versions string `mapstructure:"versions"`
+	// Filename blocks a single distribution file.
+	Filename string `mapstructure:"filename"`
+	// Reason is reported to clients whose downloads are refused.
+	Reason string `mapstructure:"reason"`
+	// Expires optionally ends the block at an RFC 3339 timestamp or date.
+	Expires string `mapstructure:"expires"`
+}
+
+// CompiledBlockEntry is a blocklist entry with its specifier and expiry parsed.
+type CompiledBlockEntry struct {
+	BlockEntry
+	specifiers pypi.SpecifierSet
+	expires    time.Time
+}
+
+// Active reports whether the entry is in effect at the given time.
+func (b CompiledBlockEntry) Active(now time.Time) bool {
+	return b.expires.IsZero() || now.Before(b.expires)
+}
+
+// Blocks reports whether the entry applies to a distribution file of a package.
+func (b CompiledBlockEntry) Blocks(packageName, fileName string) bool {
+	return selectsFile(b.Package, b.specifiers, b.Filename, packageName, fileName)
+}
+
+// selectsFile reports whether an entry naming either a project with an
+// optional version specifier, or an exact file name, applies to a
+// distribution file of a package.
+func selectsFile(project string, specifiers pypi.SpecifierSet, filename, packageName, fileName string) bool {
+	if filename != "" {
+		return filename == fileName
+	}
+	if pypi.NormalizeName(project) != pypi.NormalizeName(packageName) {
+		return false
+	}
+	if len(specifiers) == 0 {
+		return true
+	}
+
+	dist, err := pypi.ParseDistributionFilename(fileName)
+	if err != nil {
+		return false
+	}
+	version, err := pypi.ParseVersion(dist.Version)
+	if err != nil {
+		return false
+	}
+	return specifiers.Contains(version)
+}
+
+// CompileBlocklist validates and compiles the blocklist entries.
+func (c *Config) CompileBlocklist() ([]CompiledBlockEntry, error) {
+	entries := make([]CompiledBlockEntry, 0, len(c.Blocklist))
+	for i, b := range c.Blocklist {
+		compiled, err := compileBlockEntry(b)
+		if err != nil {
+			return nil, fmt.Errorf("blocklist entry %d: %w", i+1, err)
+		}
+		entries = append(entries, compiled)
+	}
+	return entries, nil
+}
+
+// compileBlockEntry validates a single blocklist entry.
+func compileBlockEntry(b BlockEntry) (CompiledBlockEntry, error) {
+	switch {
+	case b.Package == "" && b.Filename == "":
+		return CompiledBlockEntry{}, fmt.Errorf("package or filename is required")
+	case b.Filename != "" && b.Versions != "":
+		return CompiledBlockEntry{}, fmt.Errorf("versions can't be combined with filename")
+	case b.Reason == "":
+		return CompiledBlockEntry{}, fmt.Errorf("reason is required")
+	}
+
+	compiled := CompiledBlockEntry{BlockEntry: b}
+	var err error
+	if compiled.specifiers, err = pypi.ParseSpecifierSet(b.Versions); err != nil {
+		return CompiledBlockEntry{}, err
+	}
+	if b.Expires != "" {
+		if compiled.expires, err = parseTimestamp(b.Expires); err != nil {
+			return CompiledBlockEntry{}, fmt.Errorf("expires: %w", err)
+		}
+	}
+	return compiled, nil
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConfig_CompileBlocklist(t *testing.T) {
+	cfg := &Config{
+		Blocklist: []BlockEntry{
+			{Package: "Requests", Versions: ">=2.32.0,<2.32.3", Reason: "CVE-2024-35195"},
+			{Filename: "internal-1.4.0-py3-none-any.whl", Reason: "revoked build"},
+			{Package: "leftpad", Reason: "unpublished", Expires: "2026-01-01"},
+		},
+	}
+
+	entries, err := cfg.CompileBlocklist()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	tests := []struct {
+		entry    int
+		pkg      string
+		filename string
+		expected bool
+	}{
+		{0, "requests", "requests-2.32.0.tar.gz", true},
+		{0, "requests", "requests-2.32.2-py3-none-any.whl", true},
+		{0, "requests", "requests-2.32.3.tar.gz", false},
+		{0, "httpx", "httpx-2.32.0.tar.gz", false},
+		{1, "internal", "internal-1.4.0-py3-none-any.whl", true},
+		{1, "internal", "internal-1.4.0.tar.gz", false},
+		{2, "left-pad", "left_pad-1.0.tar.gz", false},
+		{2, "leftpad", "leftpad-1.0.tar.gz", true},
+	}
+	for _, tt := range tests {
+		if result := entries[tt.entry].Blocks(tt.pkg, tt.filename); result != tt.expected {
+			t.Errorf("Expected entry %d to block %s = %t, got %t", tt.entry, tt.filename, tt.expected, result)
+		}
+	}
+
+	if !entries[0].Active(time.Now()) {
+		t.Error("Expected an entry without expiry to be active")
+	}
+	if entries[2].Active(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Error("Expected the entry to expire at its expiry date")
+	}
+	if !entries[2].Active(time.Date(2025, 12, 31, 23, 0, 0, 0, time.UTC)) {
+		t.Error("Expected the entry to be active before its expiry date")
+	}
+}
+
+func TestConfig_CompileBlocklistErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry BlockEntry
+	}{
+		{"no target", BlockEntry{Reason: "bad"}},
+		{"no reason", BlockEntry{Package: "requests"}},
+		{"versions with filename", BlockEntry{Filename: "requests-2.0.tar.gz", Versions: "==2.0", Reason: "bad"}},
+		{"invalid specifier", BlockEntry{Package: "requests", Versions: "2.0", Reason: "bad"}},
+		{"invalid expiry", BlockEntry{Package: "requests", Reason: "bad", Expires: "soon"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Blocklist: []BlockEntry{tt.entry}}
+			if _, err := cfg.CompileBlocklist(); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
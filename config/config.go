@@ -3,31 +3,117 @@ package config
 
 import (
 	"fmt"
+	"net/url"
+	"path/filepath"
+	"python-index-proxy/pypi"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
+const (
+	// PublicIndexName is the name given to the public index when the chain is
+	// derived from public_pypi_url.
+	PublicIndexName = "public"
+	// PrivateIndexName is the name given to the private index when the chain is
+	// derived from private_pypi_url.
+	PrivateIndexName = "private"
+)
+
+// IndexConfig describes a single upstream index in the routing chain.
+type IndexConfig struct {
+	// Name identifies the index in logs, cache keys and routing rules.
+	Name string `mapstructure:"name"`
+	// URL is the base URL of the index's simple API, or a file:// URL of a
+	// local directory holding one subdirectory of files per project.
+	URL string `mapstructure:"url"`
+	// Priority orders the chain; lower values are consulted first.
+	Priority int `mapstructure:"priority"`
+	// Public marks the index as untrusted, so its wheels are filtered out.
+	Public bool `mapstructure:"public"`
+	// JSONAPIURL optionally points at a PyPI-compatible JSON API, e.g. "https://pypi.org/pypi/".
+	JSONAPIURL string `mapstructure:"json_api_url"`
+	// FilesURL is no longer supported: files are fetched from the links of
+	// package pages. It is kept so configs that still set it are rejected.
+	FilesURL string `mapstructure:"files_url"`
+}
+
+// LocalDir returns the directory of an index kept on local disk, which is
+// configured with a file:// URL such as "file:///var/lib/tejedor/uploads/".
+func (idx IndexConfig) LocalDir() (string, bool) {
+	u, err := url.Parse(idx.URL)
+	if err != nil || u.Scheme != "file" || u.Path == "" {
+		return "", false
+	}
+	return filepath.FromSlash(u.Path), true
+}
+
+// JSONAPIBaseURL returns the JSON API of an index, which serves /pypi/ and
+// upload times. Indexes default to the API next to their simple API, as on
+// pypi.org, devpi and Pulp, so the index derived from private_pypi_url has one
+// too.
+func (idx IndexConfig) JSONAPIBaseURL() string {
+	if idx.JSONAPIURL != "" {
+		return idx.JSONAPIURL
+	}
+	if _, local := idx.LocalDir(); local {
+		return ""
+	}
+	base := strings.TrimSuffix(idx.URL, "/")
+	if !strings.HasSuffix(base, "/simple") {
+		return ""
+	}
+	return strings.TrimSuffix(base, "/simple") + "/pypi/"
+}
+
 // Config holds the application configuration.
 type Config struct {
-	PublicPyPIURL      string   `mapstructure:"public_pypi_url"`
-	PrivatePyPIURL     string   `mapstructure:"private_pypi_url"`
-	Port               int      `mapstructure:"port"`
-	CacheEnabled       bool     `mapstructure:"cache_enabled"`
-	CacheSize          int      `mapstructure:"cache_size"`
-	CacheTTL           int      `mapstructure:"cache_ttl_hours"`
-	PublicOnlyPackages []string `mapstructure:"public_only_packages"`
+	PublicPyPIURL           string               `mapstructure:"public_pypi_url"`
+	PrivatePyPIURL          string               `mapstructure:"private_pypi_url"`
+	Indexes                 []IndexConfig        `mapstructure:"indexes"`
+	Port                    int                  `mapstructure:"port"`
+	CacheEnabled            bool                 `mapstructure:"cache_enabled"`
+	CacheSize               int                  `mapstructure:"cache_size"`
+	CacheTTL                int                  `mapstructure:"cache_ttl_hours"`
+	PublicOnlyPackages      []string             `mapstructure:"public_only_packages"`
+	Rules                   []RoutingRule        `mapstructure:"rules"`
+	PrivateNamespaces       []string             `mapstructure:"private_namespaces"`
+	MergePackages           []string             `mapstructure:"merge_packages"`
+	RootIncludePublic       bool                 `mapstructure:"root_include_public"`
+	RootListingTTL          int                  `mapstructure:"root_listing_ttl_minutes"`
+	WheelPolicies           []WheelPolicy        `mapstructure:"wheel_policies"`
+	DefaultWheelPolicy      string               `mapstructure:"default_wheel_policy"`
+	MinReleaseAge           time.Duration        `mapstructure:"min_release_age"`
+	MinReleaseAgeOverrides  []ReleaseAgeOverride `mapstructure:"min_release_age_overrides"`
+	AsOf                    string               `mapstructure:"as_of"`
+	AsOfUntimedPrivateFiles string               `mapstructure:"as_of_untimed_private_files"`
+	Blocklist               []BlockEntry         `mapstructure:"blocklist"`
+	Yanks                   []YankEntry          `mapstructure:"yanks"`
+	OSV                     OSVConfig            `mapstructure:"osv"`
+	AdminToken              string               `mapstructure:"admin_token"`
+	RequireHashes           bool                 `mapstructure:"require_hashes"`
+	LockFiles               []string             `mapstructure:"lock_files"`
+	Upload                  UploadConfig         `mapstructure:"upload"`
+	FileCacheDir            string               `mapstructure:"file_cache_dir"`
+	PageMaxAge              time.Duration        `mapstructure:"page_max_age"`
 }
 
 // DefaultConfig returns the default configuration.
 func DefaultConfig() *Config {
 	return &Config{
-		PublicPyPIURL:      "https://pypi.org/simple/",
-		PrivatePyPIURL:     "",
-		Port:               8080,
-		CacheEnabled:       true,
-		CacheSize:          20000,
-		CacheTTL:           12,
-		PublicOnlyPackages: []string{},
+		PublicPyPIURL:           "https://pypi.org/simple/",
+		PrivatePyPIURL:          "",
+		Port:                    8080,
+		CacheEnabled:            true,
+		CacheSize:               20000,
+		CacheTTL:                12,
+		PublicOnlyPackages:      []string{},
+		RootListingTTL:          60,
+		DefaultWheelPolicy:      WheelPolicyDeny,
+		AsOfUntimedPrivateFiles: AsOfIncludeUntimed,
+		PageMaxAge:              10 * time.Minute,
 	}
 }
 
@@ -67,6 +153,21 @@ func LoadConfig(configPath string) (*Config, error) {
 	if err := viper.BindEnv("public_only_packages", "PYPI_PROXY_PUBLIC_ONLY_PACKAGES"); err != nil {
 		return nil, fmt.Errorf("error binding public_only_packages env var: %w", err)
 	}
+	if err := viper.BindEnv("lock_files", "PYPI_PROXY_LOCK_FILES"); err != nil {
+		return nil, fmt.Errorf("error binding lock_files env var: %w", err)
+	}
+	if err := viper.BindEnv("admin_token", "PYPI_PROXY_ADMIN_TOKEN"); err != nil {
+		return nil, fmt.Errorf("error binding admin_token env var: %w", err)
+	}
+	if err := viper.BindEnv("file_cache_dir", "PYPI_PROXY_FILE_CACHE_DIR"); err != nil {
+		return nil, fmt.Errorf("error binding file_cache_dir env var: %w", err)
+	}
+	if err := viper.BindEnv("upload.password", "PYPI_PROXY_UPLOAD_PASSWORD"); err != nil {
+		return nil, fmt.Errorf("error binding upload.password env var: %w", err)
+	}
+	if err := viper.BindEnv("upload.token", "PYPI_PROXY_UPLOAD_TOKEN"); err != nil {
+		return nil, fmt.Errorf("error binding upload.token env var: %w", err)
+	}
 
 	// If config file is specified, use it
 	if configPath != "" {
@@ -86,13 +187,79 @@ func LoadConfig(configPath string) (*Config, error) {
 	}
 
 	// Validate required fields
-	if config.PrivatePyPIURL == "" {
+	if config.PrivatePyPIURL == "" && len(config.Indexes) == 0 {
 		return nil, fmt.Errorf("private_pypi_url is required")
 	}
+	if err := validateIndexes(config.Indexes); err != nil {
+		return nil, err
+	}
+	if _, err := config.CompileRules(); err != nil {
+		return nil, err
+	}
+	if _, err := config.PrivateNamespaceMatcher(); err != nil {
+		return nil, fmt.Errorf("private_namespaces: %w", err)
+	}
+	if _, err := NewPackageMatcher(config.MergePackages); err != nil {
+		return nil, fmt.Errorf("merge_packages: %w", err)
+	}
+	if _, err := config.CompileWheelPolicies(); err != nil {
+		return nil, err
+	}
+	if _, err := config.CompileReleaseAgeOverrides(); err != nil {
+		return nil, err
+	}
+	if _, err := config.CompileBlocklist(); err != nil {
+		return nil, err
+	}
+	if _, err := config.CompileYanks(); err != nil {
+		return nil, err
+	}
+	if err := config.OSV.validate(); err != nil {
+		return nil, err
+	}
+	if err := config.Upload.validate(config.IndexChain()); err != nil {
+		return nil, err
+	}
+	if err := config.validateAsOf(); err != nil {
+		return nil, err
+	}
+	if config.PageMaxAge < 0 {
+		return nil, fmt.Errorf("page_max_age must not be negative")
+	}
+	if config.RootListingTTL < 0 {
+		return nil, fmt.Errorf("root_listing_ttl_minutes must not be negative")
+	}
 
 	return config, nil
 }
 
+// validateIndexes checks that every configured index has a unique name and a
+// URL. The routing targets public, private and block are reserved, so a rule
+// target never names both an index and a group of indexes.
+func validateIndexes(indexes []IndexConfig) error {
+	seen := make(map[string]bool, len(indexes))
+	for i, idx := range indexes {
+		if idx.Name == "" {
+			return fmt.Errorf("indexes[%d]: name is required", i)
+		}
+		switch idx.Name {
+		case TargetPublic, TargetPrivate, TargetBlock:
+			return fmt.Errorf("index %q: name is reserved for routing targets", idx.Name)
+		}
+		if idx.URL == "" {
+			return fmt.Errorf("index %q: url is required", idx.Name)
+		}
+		if idx.FilesURL != "" {
+			return fmt.Errorf("index %q: files_url is no longer supported; files are downloaded from the links on package pages, remove it", idx.Name)
+		}
+		if seen[idx.Name] {
+			return fmt.Errorf("index %q is defined more than once", idx.Name)
+		}
+		seen[idx.Name] = true
+	}
+	return nil
+}
+
 // CreateDefaultConfigFile creates a default config file.
 func CreateDefaultConfigFile(path string) error {
 	config := DefaultConfig()
@@ -109,10 +276,35 @@ func CreateDefaultConfigFile(path string) error {
 	return viper.WriteConfigAs(path)
 }
 
+// IndexChain returns the configured indexes ordered by priority.
+// When no indexes are configured, the chain is derived from private_pypi_url
+// and public_pypi_url, with the private index consulted first.
+func (c *Config) IndexChain() []IndexConfig {
+	if len(c.Indexes) == 0 {
+		var chain []IndexConfig
+		if c.PrivatePyPIURL != "" {
+			chain = append(chain, IndexConfig{Name: PrivateIndexName, URL: c.PrivatePyPIURL, Priority: 10})
+		}
+		if c.PublicPyPIURL != "" {
+			chain = append(chain, IndexConfig{Name: PublicIndexName, URL: c.PublicPyPIURL, Priority: 100, Public: true})
+		}
+		return chain
+	}
+
+	chain := make([]IndexConfig, len(c.Indexes))
+	copy(chain, c.Indexes)
+	sort.SliceStable(chain, func(i, j int) bool {
+		return chain[i].Priority < chain[j].Priority
+	})
+	return chain
+}
+
 // IsPublicOnlyPackage checks if a package should always be served from the public index.
+// Names are compared in their PEP 503 normalized form.
 func (c *Config) IsPublicOnlyPackage(packageName string) bool {
+	normalizedName := pypi.NormalizeName(packageName)
 	for _, pkg := range c.PublicOnlyPackages {
-		if pkg == packageName {
+		if pypi.NormalizeName(pkg) == normalizedName {
 			return true
 		}
 	}
@@ -30,6 +30,10 @@ func TestDefaultConfig(t *testing.T) {
 	if config.CacheTTL != 12 {
 		t.Errorf("Expected cache TTL to be 12 hours, got %d", config.CacheTTL)
 	}
+
+	if config.RootIncludePublic || config.RootListingTTL != 60 {
+		t.Errorf("Expected private-only root listing cached for 60 minutes, got %t and %d", config.RootIncludePublic, config.RootListingTTL)
+	}
 }
 
 func TestLoadConfigFromEnvironment(t *testing.T) {
@@ -343,9 +347,15 @@ func TestConfig_IsPublicOnlyPackage(t *testing.T) {
 			expected:    false,
 		},
 		{
-			name:        "case sensitive match",
+			name:        "normalized match",
+			packages:    []string{"Requests", "Zope.Interface"},
+			packageName: "zope_interface",
+			expected:    true,
+		},
+		{
+			name:        "normalized mismatch",
 			packages:    []string{"Requests", "Pydantic"},
-			packageName: "requests",
+			packageName: "requests-oauthlib",
 			expected:    false,
 		},
 	}
@@ -362,3 +372,124 @@ func TestConfig_IsPublicOnlyPackage(t *testing.T) {
 		})
 	}
 }
+
+func TestConfig_IndexChain(t *testing.T) {
+	// Derived from the legacy public/private URLs
+	cfg := &Config{
+		PublicPyPIURL:  "https://pypi.org/simple/",
+		PrivatePyPIURL: "https://private.example.com/simple/",
+	}
+	chain := cfg.IndexChain()
+	if len(chain) != 2 {
+		t.Fatalf("Expected 2 indexes, got %d", len(chain))
+	}
+	if chain[0].Name != PrivateIndexName || chain[0].Public {
+		t.Errorf("Expected private index first, got %+v", chain[0])
+	}
+	if chain[1].Name != PublicIndexName || !chain[1].Public {
+		t.Errorf("Expected public index last, got %+v", chain[1])
+	}
+
+	// Explicit indexes are ordered by priority
+	cfg.Indexes = []IndexConfig{
+		{Name: "pypi", URL: "https://pypi.org/simple/", Priority: 100, Public: true},
+		{Name: "cuda", URL: "https://cuda.example.com/simple/", Priority: 30},
+		{Name: "team", URL: "https://team.example.com/simple/", Priority: 10},
+	}
+	chain = cfg.IndexChain()
+	names := make([]string, 0, len(chain))
+	for _, idx := range chain {
+		names = append(names, idx.Name)
+	}
+	if strings.Join(names, ",") != "team,cuda,pypi" {
+		t.Errorf("Expected chain team,cuda,pypi, got %s", strings.Join(names, ","))
+	}
+}
+
+// TestLoadConfigWithIndexes tests loading an index chain from a config file.
+func TestLoadConfigWithIndexes(t *testing.T) {
+	tempFile, err := os.CreateTemp("", "test-config-*.yaml")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer func() {
+		if err := os.Remove(tempFile.Name()); err != nil {
+			t.Errorf("Failed to remove temp file: %v", err)
+		}
+	}()
+
+	configContent := `
+indexes:
+  - name: team
+    url: "https://team.example.com/simple/"
+    priority: 10
+  - name: pypi
+    url: "https://pypi.org/simple/"
+    priority: 100
+    public: true
+`
+	if _, err := tempFile.WriteString(configContent); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	if err := tempFile.Close(); err != nil {
+		t.Fatalf("Failed to close temp file: %v", err)
+	}
+
+	viper.Reset()
+	defer viper.Reset()
+
+	cfg, err := LoadConfig(tempFile.Name())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(cfg.Indexes) != 2 {
+		t.Fatalf("Expected 2 indexes, got %d", len(cfg.Indexes))
+	}
+	if cfg.Indexes[1].Name != "pypi" || !cfg.Indexes[1].Public || cfg.Indexes[1].Priority != 100 {
+		t.Errorf("Expected public pypi index, got %+v", cfg.Indexes[1])
+	}
+}
+
+func TestValidateIndexes(t *testing.T) {
+	tests := []struct {
+		name    string
+		indexes []IndexConfig
+		wantErr bool
+	}{
+		{"valid", []IndexConfig{{Name: "a", URL: "https://a/simple/"}, {Name: "b", URL: "https://b/simple/"}}, false},
+		{"missing name", []IndexConfig{{URL: "https://a/simple/"}}, true},
+		{"missing url", []IndexConfig{{Name: "a"}}, true},
+		{"duplicate name", []IndexConfig{{Name: "a", URL: "https://a/simple/"}, {Name: "a", URL: "https://b/simple/"}}, true},
+		{"files_url", []IndexConfig{{Name: "a", URL: "https://a/simple/", FilesURL: "https://files.a/"}}, true},
+		{"reserved public", []IndexConfig{{Name: TargetPublic, URL: "https://a/simple/", Public: true}}, true},
+		{"reserved private", []IndexConfig{{Name: TargetPrivate, URL: "https://a/simple/"}}, true},
+		{"reserved block", []IndexConfig{{Name: TargetBlock, URL: "https://a/simple/"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateIndexes(tt.indexes)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateIndexes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestJSONAPIBaseURL(t *testing.T) {
+	tests := []struct {
+		idx      IndexConfig
+		expected string
+	}{
+		{IndexConfig{URL: "https://pypi.org/simple/", Public: true}, "https://pypi.org/pypi/"},
+		{IndexConfig{URL: "https://pulp.example.com/pulp-content/team/simple"}, "https://pulp.example.com/pulp-content/team/pypi/"},
+		{IndexConfig{URL: "https://private.example.com/simple/", JSONAPIURL: "https://api.example.com/pypi/"}, "https://api.example.com/pypi/"},
+		{IndexConfig{URL: "https://private.example.com/repository/"}, ""},
+	}
+	for _, tt := range tests {
+		if got := tt.idx.JSONAPIBaseURL(); got != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.idx.URL, tt.expected, got)
+		}
+	}
+}
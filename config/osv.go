@@ -0,0 +1,83 @@
+package config
+
+import (
+	"fmt"
+	"python-index-proxy/osv"
+)
+
+const (
+	// OSVActionHide removes affected files from package pages and refuses their downloads.
+	OSVActionHide = "hide"
+	// OSVActionYank marks affected files as yanked, with the advisory IDs as the reason.
+	OSVActionYank = "yank"
+	// OSVActionLog only logs affected files.
+	OSVActionLog = "log"
+)
+
+// OSVConfig configures filtering against an offline OSV vulnerability database.
+type OSVConfig struct {
+	// Path is a directory of OSV JSON records or a zip archive of them.
+	Path string `mapstructure:"path"`
+	// RefreshMinutes reloads the database on a schedule; 0 loads it once.
+	RefreshMinutes int `mapstructure:"refresh_minutes"`
+	// Actions maps a severity ("critical", "high", "medium", "low" or
+	// "unknown") to "hide", "yank" or "log".
+	Actions map[string]string `mapstructure:"actions"`
+	// DefaultAction applies to severities without an entry in Actions.
+	DefaultAction string `mapstructure:"default_action"`
+}
+
+// Enabled reports whether an OSV database is configured.
+func (o OSVConfig) Enabled() bool {
+	return o.Path != ""
+}
+
+// Action returns the action for advisories of a severity.
+func (o OSVConfig) Action(severity osv.Severity) string {
+	if action, ok := o.Actions[string(severity)]; ok {
+		return action
+	}
+	if o.DefaultAction != "" {
+		return o.DefaultAction
+	}
+	return OSVActionLog
+}
+
+// validate checks the severities and actions of the OSV settings.
+func (o OSVConfig) validate() error {
+	if o.RefreshMinutes < 0 {
+		return fmt.Errorf("osv.refresh_minutes must not be negative")
+	}
+	if o.DefaultAction != "" && !validOSVAction(o.DefaultAction) {
+		return fmt.Errorf("osv.default_action: unknown action %q", o.DefaultAction)
+	}
+	for severity, action := range o.Actions {
+		if !validSeverity(severity) {
+			return fmt.Errorf("osv.actions: unknown severity %q", severity)
+		}
+		if !validOSVAction(action) {
+			return fmt.Errorf("osv.actions: unknown action %q for %s", action, severity)
+		}
+	}
+	return nil
+}
+
+// validOSVAction reports whether an action is known.
+func validOSVAction(action string) bool {
+	switch action {
+	case OSVActionHide, OSVActionYank, OSVActionLog:
+		return true
+	default:
+		return false
+	}
+}
+
+// validSeverity reports whether a severity name is known.
+func validSeverity(severity string) bool {
+	for _, s := range osv.Severities {
+		if severity == string(s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"python-index-proxy/osv"
+	"testing"
+)
+
+func TestOSVConfig_Action(t *testing.T) {
+	cfg := OSVConfig{
+		Path:          "/var/lib/osv",
+		Actions:       map[string]string{"critical": OSVActionHide, "high": OSVActionYank},
+		DefaultAction: OSVActionLog,
+	}
+	if err := cfg.validate(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	tests := map[osv.Severity]string{
+		osv.SeverityCritical: OSVActionHide,
+		osv.SeverityHigh:     OSVActionYank,
+		osv.SeverityLow:      OSVActionLog,
+		osv.SeverityUnknown:  OSVActionLog,
+	}
+	for severity, expected := range tests {
+		if result := cfg.Action(severity); result != expected {
+			t.Errorf("Expected %s advisories to %s, got %s", severity, expected, result)
+		}
+	}
+
+	if (OSVConfig{}).Action(osv.SeverityCritical) != OSVActionLog {
+		t.Error("Expected advisories to be logged by default")
+	}
+}
+
+func TestOSVConfig_ValidateErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  OSVConfig
+	}{
+		{"unknown severity", OSVConfig{Actions: map[string]string{"severe": OSVActionHide}}},
+		{"unknown action", OSVConfig{Actions: map[string]string{"high": "delete"}}},
+		{"unknown default action", OSVConfig{DefaultAction: "delete"}},
+		{"negative refresh", OSVConfig{RefreshMinutes: -1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.cfg.validate(); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// ReleaseAgeOverride sets a different minimum release age for the packages
+// matching its patterns. Patterns follow the routing rule syntax.
+type ReleaseAgeOverride struct {
+	Packages []string `mapstructure:"packages"`
+	// Age is the minimum release age for matching packages; zero disables the quarantine.
+	Age time.Duration `mapstructure:"age"`
+}
+
+// CompiledReleaseAgeOverride is a release age override with its package patterns compiled.
+type CompiledReleaseAgeOverride struct {
+	ReleaseAgeOverride
+	matcher *PackageMatcher
+}
+
+// Matches reports whether the override applies to a package.
+func (o CompiledReleaseAgeOverride) Matches(packageName string) bool {
+	_, ok := o.matcher.Match(packageName)
+	return ok
+}
+
+// CompileReleaseAgeOverrides validates the minimum release age and compiles
+// its overrides in the order they are configured.
+func (c *Config) CompileReleaseAgeOverrides() ([]CompiledReleaseAgeOverride, error) {
+	if c.MinReleaseAge < 0 {
+		return nil, fmt.Errorf("min_release_age must not be negative")
+	}
+
+	overrides := make([]CompiledReleaseAgeOverride, 0, len(c.MinReleaseAgeOverrides))
+	for i, o := range c.MinReleaseAgeOverrides {
+		if o.Age < 0 {
+			return nil, fmt.Errorf("min_release_age_overrides[%d]: age must not be negative", i)
+		}
+		matcher, err := NewPackageMatcher(o.Packages)
+		if err != nil {
+			return nil, fmt.Errorf("min_release_age_overrides[%d]: %w", i, err)
+		}
+		overrides = append(overrides, CompiledReleaseAgeOverride{ReleaseAgeOverride: o, matcher: matcher})
+	}
+	return overrides, nil
+}
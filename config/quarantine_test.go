@@ -0,0 +1,49 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConfig_CompileReleaseAgeOverrides(t *testing.T) {
+	cfg := &Config{
+		MinReleaseAge: 72 * time.Hour,
+		MinReleaseAgeOverrides: []ReleaseAgeOverride{
+			{Packages: []string{"glob:internal-*"}, Age: 0},
+			{Packages: []string{"requests"}, Age: 24 * time.Hour},
+		},
+	}
+
+	overrides, err := cfg.CompileReleaseAgeOverrides()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(overrides) != 2 {
+		t.Fatalf("Expected 2 overrides, got %d", len(overrides))
+	}
+	if !overrides[0].Matches("internal-tools") || overrides[0].Matches("requests") {
+		t.Error("Expected the first override to match internal-* only")
+	}
+	if !overrides[1].Matches("Requests") {
+		t.Error("Expected the second override to match normalized names")
+	}
+}
+
+func TestConfig_CompileReleaseAgeOverridesErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{"negative default", Config{MinReleaseAge: -time.Hour}},
+		{"negative override", Config{MinReleaseAgeOverrides: []ReleaseAgeOverride{{Packages: []string{"requests"}, Age: -time.Hour}}}},
+		{"invalid pattern", Config{MinReleaseAgeOverrides: []ReleaseAgeOverride{{Packages: []string{"regex:("}}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.cfg.CompileReleaseAgeOverrides(); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"python-index-proxy/pypi"
+	"regexp"
+	"strings"
+)
+
+const (
+	// TargetPublic routes a package to the public indexes of the chain only.
+	TargetPublic = "public"
+	// TargetPrivate routes a package to the private indexes of the chain only.
+	TargetPrivate = "private"
+	// TargetBlock refuses to serve a package at all.
+	TargetBlock = "block"
+)
+
+// RoutingRule sends packages matching any of its patterns to a target.
+// Patterns are matched against PEP 503-normalized names. A pattern is a
+// regular expression when prefixed with "regex:", a glob when prefixed with
+// "glob:" or when it contains any of "*?[", and an exact name otherwise.
+type RoutingRule struct {
+	Name     string   `mapstructure:"name"`
+	Patterns []string `mapstructure:"patterns"`
+	// Target is "public", "private", "block" or the name of an index in the chain.
+	Target string `mapstructure:"target"`
+}
+
+// Rule is a routing rule with its patterns compiled.
+type Rule struct {
+	RoutingRule
+	matcher *PackageMatcher
+}
+
+// Matches reports whether the rule applies to a package.
+func (r Rule) Matches(packageName string) bool {
+	_, ok := r.matcher.Match(packageName)
+	return ok
+}
+
+// CompileRules compiles the routing rules in the order they are configured.
+func (c *Config) CompileRules() ([]Rule, error) {
+	indexNames := make(map[string]bool)
+	for _, idx := range c.IndexChain() {
+		indexNames[idx.Name] = true
+	}
+
+	rules := make([]Rule, 0, len(c.Rules))
+	for i, rr := range c.Rules {
+		if rr.Name == "" {
+			rr.Name = fmt.Sprintf("rule-%d", i+1)
+		}
+		switch rr.Target {
+		case TargetPublic, TargetPrivate, TargetBlock:
+		default:
+			if !indexNames[rr.Target] {
+				return nil, fmt.Errorf("rule %q: unknown target %q", rr.Name, rr.Target)
+			}
+		}
+		matcher, err := NewPackageMatcher(rr.Patterns)
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %w", rr.Name, err)
+		}
+		rules = append(rules, Rule{RoutingRule: rr, matcher: matcher})
+	}
+	return rules, nil
+}
+
+// PrivateNamespaceMatcher compiles the private namespaces. Plain entries are
+// name prefixes, so "acme-" covers "acme-utils"; glob and regex entries are
+// matched like routing rule patterns.
+func (c *Config) PrivateNamespaceMatcher() (*PackageMatcher, error) {
+	patterns := make([]string, 0, len(c.PrivateNamespaces))
+	for _, ns := range c.PrivateNamespaces {
+		if strings.HasPrefix(ns, "regex:") || strings.HasPrefix(ns, "glob:") || strings.ContainsAny(ns, "*?[") {
+			patterns = append(patterns, ns)
+			continue
+		}
+		patterns = append(patterns, "glob:"+ns+"*")
+	}
+	return NewPackageMatcher(patterns)
+}
+
+// patternKind identifies how a pattern is matched.
+type patternKind int
+
+const (
+	patternExact patternKind = iota
+	patternGlob
+	patternRegex
+)
+
+// pattern is a single compiled package name pattern.
+type pattern struct {
+	raw   string
+	kind  patternKind
+	value string
+	re    *regexp.Regexp
+}
+
+// PackageMatcher matches package names against exact, glob and regex patterns.
+type PackageMatcher struct {
+	patterns []pattern
+}
+
+// NewPackageMatcher compiles a list of package name patterns.
+func NewPackageMatcher(patterns []string) (*PackageMatcher, error) {
+	m := &PackageMatcher{patterns: make([]pattern, 0, len(patterns))}
+	for _, raw := range patterns {
+		p := pattern{raw: raw}
+		switch {
+		case strings.HasPrefix(raw, "regex:"):
+			re, err := regexp.Compile(strings.TrimPrefix(raw, "regex:"))
+			if err != nil {
+				return nil, fmt.Errorf("invalid regex pattern %q: %w", raw, err)
+			}
+			p.kind = patternRegex
+			p.re = re
+		case strings.HasPrefix(raw, "glob:") || strings.ContainsAny(raw, "*?["):
+			p.kind = patternGlob
+			p.value = normalizeGlob(strings.TrimPrefix(raw, "glob:"))
+			if _, err := path.Match(p.value, ""); err != nil {
+				return nil, fmt.Errorf("invalid glob pattern %q: %w", raw, err)
+			}
+		default:
+			p.kind = patternExact
+			p.value = pypi.NormalizeName(raw)
+		}
+		m.patterns = append(m.patterns, p)
+	}
+	return m, nil
+}
+
+// normalizeGlob applies PEP 503 normalization to the literal parts of a glob,
+// leaving character classes untouched.
+func normalizeGlob(glob string) string {
+	var b strings.Builder
+	for glob != "" {
+		start := strings.IndexByte(glob, '[')
+		if start < 0 {
+			b.WriteString(pypi.NormalizeName(glob))
+			break
+		}
+		end := strings.IndexByte(glob[start:], ']')
+		if end < 0 {
+			b.WriteString(pypi.NormalizeName(glob[:start]))
+			b.WriteString(glob[start:])
+			break
+		}
+		b.WriteString(pypi.NormalizeName(glob[:start]))
+		b.WriteString(glob[start : start+end+1])
+		glob = glob[start+end+1:]
+	}
+	return b.String()
+}
+
+// Match reports whether a package name matches any pattern and returns the
+// first pattern that matched.
+func (m *PackageMatcher) Match(packageName string) (string, bool) {
+	if m == nil {
+		return "", false
+	}
+	name := pypi.NormalizeName(packageName)
+	for _, p := range m.patterns {
+		switch p.kind {
+		case patternExact:
+			if name == p.value {
+				return p.raw, true
+			}
+		case patternGlob:
+			if ok, _ := path.Match(p.value, name); ok {
+				return p.raw, true
+			}
+		case patternRegex:
+			if p.re.MatchString(name) {
+				return p.raw, true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,99 @@
+package config
+
+import "testing"
+
+func TestPackageMatcher(t *testing.T) {
+	matcher, err := NewPackageMatcher([]string{"Zope.Interface", "types-*", "regex:^azure-(core|identity)$", "glob:django_[a-c]*"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	tests := []struct {
+		packageName string
+		pattern     string
+		matched     bool
+	}{
+		{"zope-interface", "Zope.Interface", true},
+		{"zope_interface", "Zope.Interface", true},
+		{"types-requests", "types-*", true},
+		{"Types_PyYAML", "types-*", true},
+		{"azure-core", "regex:^azure-(core|identity)$", true},
+		{"azure_identity", "regex:^azure-(core|identity)$", true},
+		{"azure-storage-blob", "", false},
+		{"django-axes", "glob:django_[a-c]*", true},
+		{"django-rest", "", false},
+		{"requests", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.packageName, func(t *testing.T) {
+			pattern, matched := matcher.Match(tt.packageName)
+			if matched != tt.matched {
+				t.Errorf("Match(%q) matched = %v, want %v", tt.packageName, matched, tt.matched)
+			}
+			if pattern != tt.pattern {
+				t.Errorf("Match(%q) pattern = %q, want %q", tt.packageName, pattern, tt.pattern)
+			}
+		})
+	}
+}
+
+func TestNewPackageMatcherInvalid(t *testing.T) {
+	if _, err := NewPackageMatcher([]string{"regex:(unclosed"}); err == nil {
+		t.Error("Expected error for invalid regex")
+	}
+	if _, err := NewPackageMatcher([]string{"glob:[unclosed"}); err == nil {
+		t.Error("Expected error for invalid glob")
+	}
+}
+
+func TestConfig_CompileRules(t *testing.T) {
+	cfg := &Config{
+		PublicPyPIURL:  "https://pypi.org/simple/",
+		PrivatePyPIURL: "https://private.example.com/simple/",
+		Rules: []RoutingRule{
+			{Name: "stubs", Patterns: []string{"types-*"}, Target: TargetPublic},
+			{Patterns: []string{"evil-*"}, Target: TargetBlock},
+			{Name: "pinned", Patterns: []string{"torch"}, Target: PrivateIndexName},
+		},
+	}
+
+	rules, err := cfg.CompileRules()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(rules) != 3 {
+		t.Fatalf("Expected 3 rules, got %d", len(rules))
+	}
+	if rules[1].Name != "rule-2" {
+		t.Errorf("Expected unnamed rule to be named rule-2, got %s", rules[1].Name)
+	}
+	if !rules[0].Matches("types-requests") || rules[0].Matches("requests") {
+		t.Error("Expected stubs rule to match only types-* packages")
+	}
+
+	cfg.Rules = []RoutingRule{{Name: "bad", Patterns: []string{"x"}, Target: "nowhere"}}
+	if _, err := cfg.CompileRules(); err == nil {
+		t.Error("Expected error for unknown target")
+	}
+}
+
+func TestConfig_PrivateNamespaceMatcher(t *testing.T) {
+	cfg := &Config{PrivateNamespaces: []string{"acme-", "Corp_", "regex:^internal[0-9]+$"}}
+
+	matcher, err := cfg.PrivateNamespaceMatcher()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	for _, name := range []string{"acme-utils", "ACME_Utils", "corp-tools", "internal42"} {
+		if _, ok := matcher.Match(name); !ok {
+			t.Errorf("Expected %s to be in a private namespace", name)
+		}
+	}
+	for _, name := range []string{"acmeutils", "requests", "internal-tools"} {
+		if _, ok := matcher.Match(name); ok {
+			t.Errorf("Expected %s not to be in a private namespace", name)
+		}
+	}
+}
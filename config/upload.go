@@ -0,0 +1,65 @@
+package config
+
+import "fmt"
+
+// UploadConfig configures the legacy PyPI upload API served at /legacy/.
+// Uploads are forwarded to the upload URL of the private index, or stored in
+// the directory of the upload index when it is a local (file://) index.
+type UploadConfig struct {
+	// URL is the legacy upload API of the private index, e.g.
+	// "https://private.example.com/legacy/".
+	URL string `mapstructure:"url"`
+	// Username and Password authenticate the proxy to the upload URL. They
+	// are never the credentials clients upload with.
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	// StorageDir is no longer supported: uploads are stored in a local upload
+	// index instead, which serves them. It is kept so configs that still set
+	// it are rejected.
+	StorageDir string `mapstructure:"storage_dir"`
+	// Token is the password clients upload with, as in
+	// "twine upload -u __token__ -p <token>".
+	Token string `mapstructure:"token"`
+	// Index names the index uploads land in, whose caches are invalidated
+	// after each upload. It defaults to the private index.
+	Index string `mapstructure:"index"`
+}
+
+// Enabled reports whether uploads are accepted.
+func (u UploadConfig) Enabled() bool {
+	return u.URL != "" || u.Token != ""
+}
+
+// IndexName returns the name of the index uploads land in.
+func (u UploadConfig) IndexName() string {
+	if u.Index != "" {
+		return u.Index
+	}
+	return PrivateIndexName
+}
+
+// validate checks that clients must authenticate and that uploads go to a
+// backend: the upload URL, or else a local upload index.
+func (u UploadConfig) validate(indexes []IndexConfig) error {
+	if u.StorageDir != "" {
+		return fmt.Errorf("upload: storage_dir is no longer supported; point upload.index at an index with a file:// url to store uploads locally")
+	}
+	if !u.Enabled() {
+		return nil
+	}
+	if u.Token == "" {
+		return fmt.Errorf("upload: token is required when uploads are enabled")
+	}
+	if u.URL != "" {
+		return nil
+	}
+	for _, idx := range indexes {
+		if idx.Name != u.IndexName() {
+			continue
+		}
+		if _, local := idx.LocalDir(); local {
+			return nil
+		}
+	}
+	return fmt.Errorf("upload: url is required unless upload index %q is a local index with a file:// url", u.IndexName())
+}
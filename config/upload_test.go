@@ -0,0 +1,47 @@
+package config
+
+import "testing"
+
+func TestUploadConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     UploadConfig
+		wantErr bool
+	}{
+		{"disabled", UploadConfig{}, false},
+		{"forwarding", UploadConfig{URL: "https://private.example.com/legacy/", Token: "secret"}, false},
+		{"local index", UploadConfig{Index: "uploads", Token: "secret"}, false},
+		{"remote index without url", UploadConfig{Index: "team", Token: "secret"}, true},
+		{"missing index without url", UploadConfig{Token: "secret"}, true},
+		{"storage_dir", UploadConfig{StorageDir: "/var/lib/uploads", Token: "secret"}, true},
+		{"no token", UploadConfig{URL: "https://private.example.com/legacy/"}, true},
+	}
+
+	indexes := []IndexConfig{
+		{Name: "uploads", URL: "file:///var/lib/tejedor/uploads/"},
+		{Name: "team", URL: "https://team.example.com/simple/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.cfg.validate(indexes); (err != nil) != tt.wantErr {
+				t.Errorf("Expected error = %t, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	if index := (UploadConfig{}).IndexName(); index != PrivateIndexName {
+		t.Errorf("Expected uploads to land in the private index, got %s", index)
+	}
+}
+
+func TestIndexConfig_LocalDir(t *testing.T) {
+	if dir, ok := (IndexConfig{URL: "file:///var/lib/tejedor/uploads/"}).LocalDir(); !ok || dir != "/var/lib/tejedor/uploads/" {
+		t.Errorf("Expected the directory of a file:// index, got %q (%t)", dir, ok)
+	}
+	if _, ok := (IndexConfig{URL: "https://private.example.com/simple/"}).LocalDir(); ok {
+		t.Error("Expected an https index not to be local")
+	}
+	if api := (IndexConfig{URL: "file:///srv/simple"}).JSONAPIBaseURL(); api != "" {
+		t.Errorf("Expected local indexes to have no JSON API, got %q", api)
+	}
+}
@@ -0,0 +1,111 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"python-index-proxy/pypi"
+	"strings"
+)
+
+const (
+	// WheelPolicyDeny serves no public wheels.
+	WheelPolicyDeny = "deny"
+	// WheelPolicyAllowPure serves pure-Python public wheels such as "py3-none-any".
+	WheelPolicyAllowPure = "allow-pure"
+	// WheelPolicyAllowTags serves public wheels with a tag in the allowlist.
+	WheelPolicyAllowTags = "allow-tags"
+	// WheelPolicyAllow serves every public wheel.
+	WheelPolicyAllow = "allow"
+)
+
+// WheelPolicy selects which wheels from public indexes are served for the
+// packages matching its patterns. Patterns follow the routing rule syntax.
+type WheelPolicy struct {
+	Packages []string `mapstructure:"packages"`
+	// Policy is "deny", "allow-pure", "allow-tags" or "allow".
+	Policy string `mapstructure:"policy"`
+	// Tags is the allowlist for "allow-tags": "{python}-{abi}-{platform}"
+	// compatibility tags, which may contain glob wildcards.
+	Tags []string `mapstructure:"tags"`
+}
+
+// AllowsWheel reports whether the policy serves a wheel.
+func (wp WheelPolicy) AllowsWheel(dist pypi.Distribution) bool {
+	switch wp.Policy {
+	case WheelPolicyAllow:
+		return true
+	case WheelPolicyAllowPure:
+		return dist.IsPure()
+	case WheelPolicyAllowTags:
+		for _, tag := range dist.Tags() {
+			for _, pattern := range wp.Tags {
+				if ok, err := path.Match(strings.ToLower(pattern), tag); err == nil && ok {
+					return true
+				}
+			}
+		}
+		return false
+	default:
+		return false
+	}
+}
+
+// CompiledWheelPolicy is a wheel policy with its package patterns compiled.
+type CompiledWheelPolicy struct {
+	WheelPolicy
+	matcher *PackageMatcher
+}
+
+// Matches reports whether the policy applies to a package.
+func (wp CompiledWheelPolicy) Matches(packageName string) bool {
+	_, ok := wp.matcher.Match(packageName)
+	return ok
+}
+
+// CompileWheelPolicies validates and compiles the wheel policies in the order they are configured.
+func (c *Config) CompileWheelPolicies() ([]CompiledWheelPolicy, error) {
+	if err := validateWheelPolicy(c.FallbackWheelPolicy()); err != nil {
+		return nil, fmt.Errorf("default_wheel_policy: %w", err)
+	}
+
+	policies := make([]CompiledWheelPolicy, 0, len(c.WheelPolicies))
+	for i, wp := range c.WheelPolicies {
+		if err := validateWheelPolicy(wp); err != nil {
+			return nil, fmt.Errorf("wheel policy %d: %w", i+1, err)
+		}
+		matcher, err := NewPackageMatcher(wp.Packages)
+		if err != nil {
+			return nil, fmt.Errorf("wheel policy %d: %w", i+1, err)
+		}
+		policies = append(policies, CompiledWheelPolicy{WheelPolicy: wp, matcher: matcher})
+	}
+	return policies, nil
+}
+
+// FallbackWheelPolicy returns the policy for packages no wheel policy matches.
+// Public wheels are denied unless default_wheel_policy says otherwise.
+func (c *Config) FallbackWheelPolicy() WheelPolicy {
+	if c.DefaultWheelPolicy == "" {
+		return WheelPolicy{Policy: WheelPolicyDeny}
+	}
+	return WheelPolicy{Policy: c.DefaultWheelPolicy}
+}
+
+// validateWheelPolicy checks the policy name and tag allowlist of a wheel policy.
+func validateWheelPolicy(wp WheelPolicy) error {
+	switch wp.Policy {
+	case WheelPolicyDeny, WheelPolicyAllowPure, WheelPolicyAllow:
+	case WheelPolicyAllowTags:
+		if len(wp.Tags) == 0 {
+			return fmt.Errorf("policy %q requires tags", wp.Policy)
+		}
+		for _, tag := range wp.Tags {
+			if _, err := path.Match(tag, ""); err != nil {
+				return fmt.Errorf("invalid tag pattern %q: %w", tag, err)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown policy %q", wp.Policy)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"python-index-proxy/pypi"
+	"testing"
+)
+
+func TestWheelPolicy_AllowsWheel(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   WheelPolicy
+		filename string
+		expected bool
+	}{
+		{"deny refuses pure wheels", WheelPolicy{Policy: WheelPolicyDeny}, "six-1.16.0-py2.py3-none-any.whl", false},
+		{"allow serves binary wheels", WheelPolicy{Policy: WheelPolicyAllow}, "numpy-2.0.0-cp312-cp312-win_amd64.whl", true},
+		{"allow-pure serves pure wheels", WheelPolicy{Policy: WheelPolicyAllowPure}, "six-1.16.0-py2.py3-none-any.whl", true},
+		{"allow-pure refuses abi3 wheels", WheelPolicy{Policy: WheelPolicyAllowPure}, "cffi-1.16.0-cp312-abi3-win_amd64.whl", false},
+		{"allow-pure refuses platform wheels", WheelPolicy{Policy: WheelPolicyAllowPure}, "demo-1.0-py3-none-macosx_11_0_arm64.whl", false},
+		{
+			"allow-tags matches a glob",
+			WheelPolicy{Policy: WheelPolicyAllowTags, Tags: []string{"cp312-cp312-manylinux*_x86_64"}},
+			"numpy-2.0.0-cp312-cp312-manylinux_2_17_x86_64.manylinux2014_x86_64.whl",
+			true,
+		},
+		{
+			"allow-tags matches any expanded tag",
+			WheelPolicy{Policy: WheelPolicyAllowTags, Tags: []string{"py3-none-any"}},
+			"six-1.16.0-py2.py3-none-any.whl",
+			true,
+		},
+		{
+			"allow-tags refuses other platforms",
+			WheelPolicy{Policy: WheelPolicyAllowTags, Tags: []string{"cp312-cp312-manylinux*_x86_64"}},
+			"numpy-2.0.0-cp312-cp312-win_amd64.whl",
+			false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dist, err := pypi.ParseDistributionFilename(tt.filename)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if result := tt.policy.AllowsWheel(dist); result != tt.expected {
+				t.Errorf("Expected AllowsWheel(%s) = %t, got %t", tt.filename, tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestConfig_CompileWheelPolicies(t *testing.T) {
+	cfg := &Config{
+		WheelPolicies: []WheelPolicy{
+			{Packages: []string{"numpy", "glob:scipy*"}, Policy: WheelPolicyAllowTags, Tags: []string{"cp312-*"}},
+			{Packages: []string{"regex:^types-"}, Policy: WheelPolicyAllowPure},
+		},
+	}
+
+	policies, err := cfg.CompileWheelPolicies()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(policies) != 2 || !policies[0].Matches("SciPy") || !policies[1].Matches("types-requests") || policies[1].Matches("numpy") {
+		t.Errorf("Unexpected compiled policies: %+v", policies)
+	}
+	if cfg.FallbackWheelPolicy().Policy != WheelPolicyDeny {
+		t.Errorf("Expected deny as the fallback policy, got %s", cfg.FallbackWheelPolicy().Policy)
+	}
+
+	invalid := []*Config{
+		{WheelPolicies: []WheelPolicy{{Packages: []string{"numpy"}, Policy: "maybe"}}},
+		{WheelPolicies: []WheelPolicy{{Packages: []string{"numpy"}, Policy: WheelPolicyAllowTags}}},
+		{WheelPolicies: []WheelPolicy{{Packages: []string{"numpy"}, Policy: WheelPolicyAllowTags, Tags: []string{"cp312-["}}}},
+		{WheelPolicies: []WheelPolicy{{Packages: []string{"regex:("}, Policy: WheelPolicyAllow}}},
+		{DefaultWheelPolicy: "sometimes"},
+	}
+	for i, c := range invalid {
+		if _, err := c.CompileWheelPolicies(); err == nil {
+			t.Errorf("Expected error for invalid config %d", i)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"fmt"
+	"python-index-proxy/pypi"
+)
+
+// YankEntry marks releases or files of a package as yanked (PEP 592) without
+// touching the upstream index. Like a blocklist entry, it names a project
+// with an optional PEP 440 version specifier, or an exact file name.
+type YankEntry struct {
+	// Package is the project the entry applies to.
+	Package string `mapstructure:"package" json:"package,omitempty"`
+	// Versions is a PEP 440 specifier; empty yanks every version of the package.
+	Versions string `mapstructure:"versions" json:"versions,omitempty"`
+	// Filename yanks a single distribution file.
+	Filename string `mapstructure:"filename" json:"filename,omitempty"`
+	// Reason is published as the yank reason.
+	Reason string `mapstructure:"reason" json:"reason"`
+}
+
+// CompiledYankEntry is a yank entry with its specifier parsed.
+type CompiledYankEntry struct {
+	YankEntry
+	specifiers pypi.SpecifierSet
+}
+
+// Yanks reports whether the entry applies to a distribution file of a package.
+func (y CompiledYankEntry) Yanks(packageName, fileName string) bool {
+	return selectsFile(y.Package, y.specifiers, y.Filename, packageName, fileName)
+}
+
+// CompileYanks validates and compiles the configured yank entries.
+func (c *Config) CompileYanks() ([]CompiledYankEntry, error) {
+	entries := make([]CompiledYankEntry, 0, len(c.Yanks))
+	for i, y := range c.Yanks {
+		compiled, err := CompileYankEntry(y)
+		if err != nil {
+			return nil, fmt.Errorf("yank entry %d: %w", i+1, err)
+		}
+		entries = append(entries, compiled)
+	}
+	return entries, nil
+}
+
+// CompileYankEntry validates a single yank entry.
+func CompileYankEntry(y YankEntry) (CompiledYankEntry, error) {
+	switch {
+	case y.Package == "" && y.Filename == "":
+		return CompiledYankEntry{}, fmt.Errorf("package or filename is required")
+	case y.Filename != "" && y.Versions != "":
+		return CompiledYankEntry{}, fmt.Errorf("versions can't be combined with filename")
+	case y.Reason == "":
+		return CompiledYankEntry{}, fmt.Errorf("reason is required")
+	}
+
+	specifiers, err := pypi.ParseSpecifierSet(y.Versions)
+	if err != nil {
+		return CompiledYankEntry{}, err
+	}
+	return CompiledYankEntry{YankEntry: y, specifiers: specifiers}, nil
+}
@@ -0,0 +1,55 @@
+package config
+
+import "testing"
+
+func TestConfig_CompileYanks(t *testing.T) {
+	cfg := &Config{
+		Yanks: []YankEntry{
+			{Package: "Requests", Versions: "==2.32.0", Reason: "breaks our TLS setup"},
+			{Filename: "internal-1.4.0-py3-none-any.whl", Reason: "use 1.4.1"},
+		},
+	}
+
+	entries, err := cfg.CompileYanks()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	tests := []struct {
+		entry    int
+		pkg      string
+		filename string
+		expected bool
+	}{
+		{0, "requests", "requests-2.32.0.tar.gz", true},
+		{0, "requests", "requests-2.32.1.tar.gz", false},
+		{1, "internal", "internal-1.4.0-py3-none-any.whl", true},
+		{1, "internal", "internal-1.4.0.tar.gz", false},
+	}
+	for _, tt := range tests {
+		if result := entries[tt.entry].Yanks(tt.pkg, tt.filename); result != tt.expected {
+			t.Errorf("Expected entry %d to yank %s = %t, got %t", tt.entry, tt.filename, tt.expected, result)
+		}
+	}
+}
+
+func TestConfig_CompileYanksErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry YankEntry
+	}{
+		{"no target", YankEntry{Reason: "bad"}},
+		{"no reason", YankEntry{Package: "requests"}},
+		{"versions with filename", YankEntry{Filename: "requests-2.0.tar.gz", Versions: "==2.0", Reason: "bad"}},
+		{"invalid specifier", YankEntry{Package: "requests", Versions: "2.0", Reason: "bad"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Yanks: []YankEntry{tt.entry}}
+			if _, err := cfg.CompileYanks(); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+// Package lock loads lock files that pin the exact artifacts a build may use:
+// pip requirements files with --hash options and PEP 751 pylock.toml files.
+package lock
+
+import (
+	"fmt"
+	"path/filepath"
+	"python-index-proxy/pypi"
+	"strings"
+)
+
+// Pin is a version of a package allowed by a lock file.
+type Pin struct {
+	// Version is the pinned version.
+	Version pypi.Version
+	// Exact is the version of an arbitrary equality pin (===), which is
+	// compared as a string and may not be a PEP 440 version. Version is unset
+	// for such pins.
+	Exact string
+	// Files maps the names of the locked files to their SHA-256 digest, which
+	// may be empty. PEP 751 locks name their files; requirements files don't.
+	Files map[string]string
+	// Hashes holds the SHA-256 digests any file of the version must match.
+	Hashes map[string]bool
+	// Source is the lock file the pin was read from.
+	Source string
+}
+
+// String returns the pinned version as written for arbitrary equality pins,
+// or in its normalized form.
+func (p Pin) String() string {
+	if p.Exact != "" {
+		return p.Exact
+	}
+	return p.Version.String()
+}
+
+// matches reports whether the pin covers a file version, given as published
+// and parsed, with valid set when it is a PEP 440 version.
+func (p Pin) matches(published string, version pypi.Version, valid bool) bool {
+	if p.Exact != "" {
+		return strings.EqualFold(p.Exact, published)
+	}
+	return valid && p.Version.Compare(version) == 0
+}
+
+// allows explains why a file of the pinned version is refused, or returns an
+// empty string when the pin allows it. The digest is the SHA-256 the file is
+// published with, if known.
+func (p Pin) allows(fileName, digest string) string {
+	if len(p.Files) > 0 {
+		locked, ok := p.Files[fileName]
+		if !ok {
+			return fmt.Sprintf("%s is not a locked file of version %s", fileName, p)
+		}
+		if locked != "" && digest != "" && !strings.EqualFold(locked, digest) {
+			return fmt.Sprintf("%s has sha256 %s, but %s locks %s", fileName, digest, p.Source, locked)
+		}
+		return ""
+	}
+	if len(p.Hashes) > 0 {
+		if digest == "" {
+			return fmt.Sprintf("%s has no published sha256 to check against %s", fileName, p.Source)
+		}
+		if !p.Hashes[strings.ToLower(digest)] {
+			return fmt.Sprintf("%s has sha256 %s, which %s doesn't list", fileName, digest, p.Source)
+		}
+	}
+	return ""
+}
+
+// Lock is the union of the pins of one or more lock files.
+type Lock struct {
+	// Sources lists the lock files, in the order they were given.
+	Sources []string
+	pins    map[string][]Pin
+}
+
+// Name describes the lock files for messages, e.g. "requirements.txt".
+func (l *Lock) Name() string {
+	names := make([]string, 0, len(l.Sources))
+	for _, s := range l.Sources {
+		names = append(names, filepath.Base(s))
+	}
+	return strings.Join(names, ", ")
+}
+
+// Has reports whether a package is pinned.
+func (l *Lock) Has(packageName string) bool {
+	return len(l.pins[pypi.NormalizeName(packageName)]) > 0
+}
+
+// Pins returns the pins of a package.
+func (l *Lock) Pins(packageName string) []Pin {
+	return l.pins[pypi.NormalizeName(packageName)]
+}
+
+// Refusal explains why a distribution file is not allowed by the lock, or
+// returns an empty string when it is. The digest is the SHA-256 the file is
+// published with, or empty when unknown.
+func (l *Lock) Refusal(packageName, fileName, digest string) string {
+	pins := l.Pins(packageName)
+	if len(pins) == 0 {
+		return fmt.Sprintf("%s is not in %s", pypi.NormalizeName(packageName), l.Name())
+	}
+
+	dist, err := pypi.ParseDistributionFilename(fileName)
+	if err != nil {
+		return fmt.Sprintf("%s is not a distribution file", fileName)
+	}
+	version, err := pypi.ParseVersion(dist.Version)
+	valid := err == nil
+
+	reason := fmt.Sprintf("version %s of %s is not pinned in %s", dist.Version, dist.ProjectName(), l.Name())
+	for _, pin := range pins {
+		if !pin.matches(dist.Version, version, valid) {
+			continue
+		}
+		if reason = pin.allows(fileName, digest); reason == "" {
+			return ""
+		}
+	}
+	return reason
+}
+
+// LockedDigest returns the SHA-256 digest a lock names for a file, or an empty
+// string when no lock names the file with a digest.
+func (l *Lock) LockedDigest(packageName, fileName string) string {
+	for _, pin := range l.Pins(packageName) {
+		if digest := pin.Files[fileName]; digest != "" {
+			return digest
+		}
+	}
+	return ""
+}
+
+// add records a pin of a package.
+func (l *Lock) add(packageName string, pin Pin) {
+	name := pypi.NormalizeName(packageName)
+	l.pins[name] = append(l.pins[name], pin)
+}
+
+// Load reads lock files. Files named *.toml are read as PEP 751 locks, the
+// others as pip requirements files.
+func Load(paths []string) (*Lock, error) {
+	l := &Lock{Sources: paths, pins: make(map[string][]Pin)}
+	for _, path := range paths {
+		var err error
+		if strings.HasSuffix(path, ".toml") {
+			err = loadPylock(l, path)
+		} else {
+			err = loadRequirements(l, path, nil)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
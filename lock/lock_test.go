@@ -0,0 +1,164 @@
+package lock
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	requestsSdistSHA = "55365417734eb18255590a9ff9eb97e9e1da868d4ccd6402399eaf68af20a760"
+	requestsWheelSHA = "70761cfe03c773ceb22aa2f671b4757976145175cdfca038c02654d061d6dcc6"
+	idnaWheelSHA     = "946d195a0d259cbba61165e88e65941f16e9b36ea6ddb97f00452bae8b1287d3"
+)
+
+// writeFile creates a file in a directory and returns its path.
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadRequirements(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "base.txt", "idna==3.7 --hash=sha256:"+idnaWheelSHA+"\n")
+	path := writeFile(t, dir, "requirements.txt", `# pinned by pip-compile
+--index-url https://proxy.example.com/simple/
+-r base.txt
+Requests[socks]==2.32.3 ; python_version >= "3.8" \
+    --hash=sha256:`+requestsSdistSHA+` \
+    --hash sha256:`+strings.ToUpper(requestsWheelSHA)+`
+certifi==2024.7.4  # no hashes
+legacy===1.0-custom
+urllib3 === 2.2.2
+`)
+
+	l, err := Load([]string{path})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if l.Name() != "requirements.txt" {
+		t.Errorf("Expected lock name requirements.txt, got %s", l.Name())
+	}
+
+	tests := []struct {
+		pkg     string
+		file    string
+		digest  string
+		allowed bool
+	}{
+		{"requests", "requests-2.32.3.tar.gz", requestsSdistSHA, true},
+		{"requests", "requests-2.32.3-py3-none-any.whl", requestsWheelSHA, true},
+		{"requests", "requests-2.32.3-py3-none-any.whl", idnaWheelSHA, false},
+		{"requests", "requests-2.32.3-py3-none-any.whl", "", false},
+		{"requests", "requests-2.32.2.tar.gz", requestsSdistSHA, false},
+		{"idna", "idna-3.7-py3-none-any.whl", idnaWheelSHA, true},
+		{"certifi", "certifi-2024.07.04-py3-none-any.whl", "", true},
+		{"urllib3", "urllib3-2.2.2-py3-none-any.whl", "", true},
+		// Arbitrary equality pins match the version string exactly
+		{"urllib3", "urllib3-2.2.2.0-py3-none-any.whl", "", false},
+		{"urllib3", "urllib3-2.2.3-py3-none-any.whl", "", false},
+		{"legacy", "legacy-1.0.tar.gz", "", false},
+		{"pyyaml", "pyyaml-6.0.1.tar.gz", "", false},
+	}
+	for _, tt := range tests {
+		if reason := l.Refusal(tt.pkg, tt.file, tt.digest); (reason == "") != tt.allowed {
+			t.Errorf("Expected %s allowed = %t, got reason %q", tt.file, tt.allowed, reason)
+		}
+	}
+	if pins := l.Pins("legacy"); len(pins) != 1 || pins[0].String() != "1.0-custom" {
+		t.Errorf("Expected the arbitrary equality pin as written, got %+v", pins)
+	}
+}
+
+func TestLoadPylock(t *testing.T) {
+	path := writeFile(t, t.TempDir(), "pylock.toml", `lock-version = "1.0"
+created-by = "uv"
+
+[[packages]]
+name = "requests"
+version = "2.32.3"
+
+[packages.sdist]
+url = "https://files.pythonhosted.org/packages/requests-2.32.3.tar.gz"
+hashes = {sha256 = "`+requestsSdistSHA+`"}
+
+[[packages.wheels]]
+name = "requests-2.32.3-py3-none-any.whl"
+url = "https://files.pythonhosted.org/packages/requests-2.32.3-py3-none-any.whl"
+hashes = {sha256 = "`+requestsWheelSHA+`"}
+
+[[packages]]
+name = "idna"
+
+[[packages.wheels]]
+url = "https://files.pythonhosted.org/packages/idna-3.7-py3-none-any.whl"
+hashes = {sha256 = "`+idnaWheelSHA+`"}
+
+[[packages]]
+name = "local-tool"
+directory = {path = "./tools"}
+`)
+
+	l, err := Load([]string{path})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	tests := []struct {
+		pkg     string
+		file    string
+		digest  string
+		allowed bool
+	}{
+		{"requests", "requests-2.32.3.tar.gz", requestsSdistSHA, true},
+		{"requests", "requests-2.32.3-py3-none-any.whl", "", true},
+		{"requests", "requests-2.32.3-py3-none-any.whl", idnaWheelSHA, false},
+		{"requests", "requests-2.32.3-cp312-cp312-manylinux_2_17_x86_64.whl", "", false},
+		{"idna", "idna-3.7-py3-none-any.whl", idnaWheelSHA, true},
+		{"idna", "idna-3.7.tar.gz", "", false},
+		{"local-tool", "local_tool-1.0.tar.gz", "", false},
+	}
+	for _, tt := range tests {
+		if reason := l.Refusal(tt.pkg, tt.file, tt.digest); (reason == "") != tt.allowed {
+			t.Errorf("Expected %s allowed = %t, got reason %q", tt.file, tt.allowed, reason)
+		}
+	}
+
+	if digest := l.LockedDigest("Requests", "requests-2.32.3.tar.gz"); digest != requestsSdistSHA {
+		t.Errorf("Expected the locked sdist digest, got %q", digest)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{"unpinned requirement", "unpinned.txt", "requests>=2.0\n"},
+		{"invalid hash", "hash.txt", "requests==2.32.3 --hash=sha256\n"},
+		{"include cycle", "cycle.txt", "-r cycle.txt\n"},
+		{"missing include", "include.txt", "-r missing.txt\n"},
+		{"invalid toml", "bad.toml", "lock-version = \n"},
+		{"unsupported lock version", "future.toml", "lock-version = \"2.0\"\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeFile(t, dir, tt.file, tt.content)
+			if _, err := Load([]string{path}); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+
+	if _, err := Load([]string{filepath.Join(dir, "missing.txt")}); err == nil {
+		t.Error("Expected an error for a missing lock file")
+	}
+}
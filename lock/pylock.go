@@ -0,0 +1,112 @@
+package lock
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"python-index-proxy/pypi"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// pylockFile is the part of a PEP 751 lock file the proxy uses.
+type pylockFile struct {
+	LockVersion string          `toml:"lock-version"`
+	Packages    []pylockPackage `toml:"packages"`
+}
+
+// pylockPackage is a locked package. Packages installed from a VCS, a local
+// directory or an archive URL have neither an sdist nor wheels.
+type pylockPackage struct {
+	Name    string           `toml:"name"`
+	Version string           `toml:"version"`
+	Sdist   *pylockArtifact  `toml:"sdist"`
+	Wheels  []pylockArtifact `toml:"wheels"`
+}
+
+// pylockArtifact is a locked sdist or wheel.
+type pylockArtifact struct {
+	Name   string            `toml:"name"`
+	URL    string            `toml:"url"`
+	Path   string            `toml:"path"`
+	Hashes map[string]string `toml:"hashes"`
+}
+
+// fileName returns the name of an artifact, taken from its URL or path when
+// the lock doesn't state it.
+func (a pylockArtifact) fileName() string {
+	if a.Name != "" {
+		return a.Name
+	}
+	if a.URL != "" {
+		if u, err := url.Parse(a.URL); err == nil {
+			if name, err := url.PathUnescape(path.Base(u.Path)); err == nil {
+				return name
+			}
+		}
+	}
+	if a.Path != "" {
+		return path.Base(strings.ReplaceAll(a.Path, `\`, "/"))
+	}
+	return ""
+}
+
+// loadPylock adds the pins of a PEP 751 lock file.
+func loadPylock(l *Lock, lockPath string) error {
+	data, err := os.ReadFile(lockPath)
+	if err != nil {
+		return fmt.Errorf("error opening lock file: %w", err)
+	}
+
+	var lockFile pylockFile
+	if err := toml.Unmarshal(data, &lockFile); err != nil {
+		return fmt.Errorf("error decoding lock file %s: %w", lockPath, err)
+	}
+	if lockFile.LockVersion == "" {
+		return fmt.Errorf("lock file %s has no lock-version", lockPath)
+	}
+	if major, _, _ := strings.Cut(lockFile.LockVersion, "."); major != "1" {
+		return fmt.Errorf("lock file %s has unsupported lock-version %s", lockPath, lockFile.LockVersion)
+	}
+
+	for _, pkg := range lockFile.Packages {
+		artifacts := pkg.Wheels
+		if pkg.Sdist != nil {
+			artifacts = append(artifacts, *pkg.Sdist)
+		}
+		if len(artifacts) == 0 {
+			// Not installed from an index
+			continue
+		}
+		if pkg.Name == "" {
+			return fmt.Errorf("lock file %s: package without a name", lockPath)
+		}
+
+		pin := Pin{Files: make(map[string]string, len(artifacts)), Source: lockPath}
+		for _, a := range artifacts {
+			name := a.fileName()
+			if name == "" {
+				return fmt.Errorf("lock file %s: %s has an artifact without a name", lockPath, pkg.Name)
+			}
+			pin.Files[name] = strings.ToLower(a.Hashes["sha256"])
+		}
+
+		version := pkg.Version
+		if version == "" {
+			// The version is optional; all artifacts of a package share it
+			for name := range pin.Files {
+				if dist, err := pypi.ParseDistributionFilename(name); err == nil {
+					version = dist.Version
+					break
+				}
+			}
+		}
+		if pin.Version, err = pypi.ParseVersion(version); err != nil {
+			return fmt.Errorf("lock file %s: %s: %w", lockPath, pkg.Name, err)
+		}
+		l.add(pkg.Name, pin)
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package lock
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"python-index-proxy/pypi"
+	"regexp"
+	"strings"
+)
+
+// requirementPattern matches a pinned requirement such as
+// "requests[socks]==2.32.3 ; python_version >= '3.8'", or one pinned with
+// arbitrary equality (===).
+var requirementPattern = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9._-]*)\s*(?:\[[^\]]*\])?\s*(===?)\s*([^\s;]+)\s*(?:;.*)?$`)
+
+// loadRequirements adds the pins of a pip requirements file and of the files
+// it includes with -r. The stack guards against include cycles.
+func loadRequirements(l *Lock, path string, stack []string) error {
+	for _, seen := range stack {
+		if seen == path {
+			return fmt.Errorf("requirements file %s includes itself", path)
+		}
+	}
+	stack = append(stack, path)
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error opening lock file: %w", err)
+	}
+	defer func() {
+		_ = f.Close() // read-only file, nothing to flush
+	}()
+
+	scanner := bufio.NewScanner(f)
+	var logical strings.Builder
+	lineNo, start := 0, 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		if logical.Len() == 0 {
+			start = lineNo
+		}
+
+		// Backslash continuations join physical lines into one requirement
+		if trimmed := strings.TrimRight(line, " \t"); strings.HasSuffix(trimmed, `\`) {
+			logical.WriteString(strings.TrimSuffix(trimmed, `\`))
+			logical.WriteString(" ")
+			continue
+		}
+		logical.WriteString(line)
+
+		if err := parseRequirementLine(l, path, logical.String(), stack); err != nil {
+			return fmt.Errorf("%s:%d: %w", path, start, err)
+		}
+		logical.Reset()
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading lock file %s: %w", path, err)
+	}
+	if logical.Len() > 0 {
+		if err := parseRequirementLine(l, path, logical.String(), stack); err != nil {
+			return fmt.Errorf("%s:%d: %w", path, start, err)
+		}
+	}
+	return nil
+}
+
+// parseRequirementLine adds the pin of a single logical requirements line.
+func parseRequirementLine(l *Lock, path, line string, stack []string) error {
+	line = stripComment(line)
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil
+	}
+
+	// Options on their own line: -r includes another file, the rest such as
+	// --index-url don't affect which artifacts are pinned
+	if strings.HasPrefix(fields[0], "-") {
+		name, value := splitOption(fields)
+		if name == "-r" || name == "--requirement" {
+			if value == "" {
+				return fmt.Errorf("%s needs a file name", name)
+			}
+			if !filepath.IsAbs(value) {
+				value = filepath.Join(filepath.Dir(path), value)
+			}
+			return loadRequirements(l, value, stack)
+		}
+		return nil
+	}
+
+	var spec []string
+	hashes := make(map[string]bool)
+	for i := 0; i < len(fields); i++ {
+		if !strings.HasPrefix(fields[i], "--") {
+			spec = append(spec, fields[i])
+			continue
+		}
+		name, value := splitOption(fields[i:])
+		if !strings.Contains(fields[i], "=") {
+			i++
+		}
+		if name != "--hash" {
+			continue
+		}
+		algorithm, digest, ok := strings.Cut(value, ":")
+		if !ok || digest == "" {
+			return fmt.Errorf("invalid hash %q", value)
+		}
+		if algorithm == "sha256" {
+			hashes[strings.ToLower(digest)] = true
+		}
+	}
+
+	requirement := strings.Join(spec, " ")
+	m := requirementPattern.FindStringSubmatch(requirement)
+	if m == nil {
+		return fmt.Errorf("requirement %q is not pinned with ==", requirement)
+	}
+	pin := Pin{Hashes: hashes, Source: path}
+	if m[2] == "===" {
+		// Arbitrary equality compares versions as plain strings (PEP 440)
+		pin.Exact = m[3]
+	} else {
+		version, err := pypi.ParseVersion(m[3])
+		if err != nil {
+			return fmt.Errorf("requirement %q: %w", requirement, err)
+		}
+		pin.Version = version
+	}
+
+	l.add(m[1], pin)
+	return nil
+}
+
+// splitOption returns the name and value of the option starting fields, in
+// either the "--name=value" or the "--name value" form.
+func splitOption(fields []string) (name, value string) {
+	if name, value, ok := strings.Cut(fields[0], "="); ok {
+		return name, value
+	}
+	if len(fields) > 1 {
+		return fields[0], fields[1]
+	}
+	return fields[0], ""
+}
+
+// stripComment removes a comment, which starts with "#" at the beginning of a
+// line or after whitespace.
+func stripComment(line string) string {
+	for i := 0; i < len(line); i++ {
+		if line[i] == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t') {
+			return line[:i]
+		}
+	}
+	return line
+}
@@ -2,12 +2,14 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"net/http"
 	"python-index-proxy/config"
 	"python-index-proxy/proxy"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -21,6 +23,7 @@ func main() {
 	var cacheEnabled bool
 	var cacheSize int
 	var cacheTTL int
+	var lockFiles string
 
 	flag.StringVar(&configPath, "config", "", "Path to configuration file")
 	flag.StringVar(&privatePyPIURL, "private-pypi-url", "", "URL of the private PyPI server")
@@ -29,6 +32,7 @@ func main() {
 	flag.BoolVar(&cacheEnabled, "cache-enabled", true, "Enable caching (default: true)")
 	flag.IntVar(&cacheSize, "cache-size", 0, "Cache size in entries (default: 20000)")
 	flag.IntVar(&cacheTTL, "cache-ttl-hours", 0, "Cache TTL in hours (default: 12)")
+	flag.StringVar(&lockFiles, "lock", "", "Comma-separated lock files (requirements.txt or pylock.toml) to serve only pinned artifacts from")
 	flag.Parse()
 
 	// Load configuration
@@ -56,9 +60,12 @@ func main() {
 	if cacheTTL != 0 {
 		cfg.CacheTTL = cacheTTL
 	}
+	if lockFiles != "" {
+		cfg.LockFiles = strings.Split(lockFiles, ",")
+	}
 
 	// Validate required fields
-	if cfg.PrivatePyPIURL == "" {
+	if cfg.PrivatePyPIURL == "" && len(cfg.Indexes) == 0 {
 		log.Fatal("private_pypi_url is required (set via config file, environment variable, or --private-pypi-url flag)")
 	}
 
@@ -73,12 +80,25 @@ func main() {
 
 	// Set up routes
 	router.HandleFunc("/", proxyInstance.HandleIndex).Methods("GET")
-	router.HandleFunc("/simple/", proxyInstance.HandleIndex).Methods("GET")
+	router.HandleFunc("/simple/", proxyInstance.HandleSimpleIndex).Methods("GET", "HEAD")
 	router.HandleFunc("/simple/{package}/", proxyInstance.HandlePackage).Methods("GET", "HEAD")
+	// Point-in-time snapshots of the index for reproducible builds
+	router.HandleFunc("/as-of/{timestamp}/simple/", proxyInstance.HandleSimpleIndex).Methods("GET", "HEAD")
+	router.HandleFunc("/as-of/{timestamp}/simple/{package}/", proxyInstance.HandlePackage).Methods("GET", "HEAD")
+	router.HandleFunc("/pypi/{package}/json", proxyInstance.HandleProjectJSON).Methods("GET", "HEAD")
+	router.HandleFunc("/pypi/{package}/{version}/json", proxyInstance.HandleProjectJSON).Methods("GET", "HEAD")
 	router.HandleFunc("/packages/{file:.*}", proxyInstance.HandleFile).Methods("GET", "HEAD")
+	// Legacy upload API, as used by twine
+	router.HandleFunc("/legacy/", proxyInstance.HandleUpload).Methods("POST")
 	// Handle direct file requests (for wheel files, etc.)
-	router.HandleFunc("/{file:[^/]+\\.(?:whl|tar\\.gz|zip)$}", proxyInstance.HandleFile).Methods("GET", "HEAD")
+	router.HandleFunc("/{file:[^/]+\\.(?:whl|tar\\.gz|tar\\.bz2|tar\\.xz|tgz|tar|zip)(?:\\.metadata)?$}", proxyInstance.HandleFile).Methods("GET", "HEAD")
 	router.HandleFunc("/health", proxyInstance.HandleHealth).Methods("GET")
+	router.HandleFunc("/admin/osv/reload", proxyInstance.HandleReloadVulnerabilities).Methods("POST")
+	router.HandleFunc("/admin/lock/report", proxyInstance.HandleLockReport).Methods("GET")
+	router.HandleFunc("/admin/yanks", proxyInstance.HandleYanks).Methods("GET", "POST", "DELETE")
+
+	// Reload the OSV database on its configured schedule
+	go proxyInstance.RefreshVulnerabilities(context.Background())
 
 	// Add middleware for logging
 	router.Use(loggingMiddleware)
@@ -86,15 +106,17 @@ func main() {
 	// Start server
 	addr := fmt.Sprintf(":%d", cfg.Port)
 	log.Printf("Starting PyPI proxy server on port %d", cfg.Port)
-	log.Printf("Public PyPI URL: %s", cfg.PublicPyPIURL)
-	log.Printf("Private PyPI URL: %s", cfg.PrivatePyPIURL)
+	for _, idx := range cfg.IndexChain() {
+		log.Printf("Index %s (priority %d, public: %v): %s", idx.Name, idx.Priority, idx.Public, idx.URL)
+	}
 	log.Printf("Cache enabled: %v", cfg.CacheEnabled)
 	if cfg.CacheEnabled {
 		log.Printf("Cache size: %d entries", cfg.CacheSize)
 		log.Printf("Cache TTL: %d hours", cfg.CacheTTL)
 	}
 
-	// Create server with timeouts to prevent DoS attacks
+	// Create server with timeouts to prevent DoS attacks. File downloads and
+	// uploads lift them, as large distributions take longer to transfer.
 	server := &http.Server{
 		Addr:         addr,
 		Handler:      router,
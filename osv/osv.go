@@ -0,0 +1,331 @@
+// Package osv loads offline OSV vulnerability databases and matches their
+// advisories against PyPI releases.
+package osv
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"python-index-proxy/pypi"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ecosystemPyPI is the OSV ecosystem of Python packages.
+const ecosystemPyPI = "PyPI"
+
+// Severity is the normalized severity of an advisory.
+type Severity string
+
+const (
+	// SeverityCritical is the highest severity.
+	SeverityCritical Severity = "critical"
+	// SeverityHigh is a high severity.
+	SeverityHigh Severity = "high"
+	// SeverityMedium is a medium severity, spelled "MODERATE" by GitHub advisories.
+	SeverityMedium Severity = "medium"
+	// SeverityLow is a low severity.
+	SeverityLow Severity = "low"
+	// SeverityUnknown is used for advisories that don't state a severity.
+	SeverityUnknown Severity = "unknown"
+)
+
+// Severities lists every severity, most severe first.
+var Severities = []Severity{SeverityCritical, SeverityHigh, SeverityMedium, SeverityLow, SeverityUnknown}
+
+// Advisory is a vulnerability affecting a PyPI package.
+type Advisory struct {
+	ID       string
+	Aliases  []string
+	Summary  string
+	Severity Severity
+}
+
+// entry is the part of an OSV record the proxy uses.
+type entry struct {
+	ID               string           `json:"id"`
+	Aliases          []string         `json:"aliases"`
+	Summary          string           `json:"summary"`
+	Withdrawn        string           `json:"withdrawn"`
+	Affected         []affected       `json:"affected"`
+	DatabaseSpecific databaseSpecific `json:"database_specific"`
+}
+
+// affected describes the affected versions of one package.
+type affected struct {
+	Package struct {
+		Ecosystem string `json:"ecosystem"`
+		Name      string `json:"name"`
+	} `json:"package"`
+	Ranges []struct {
+		Type   string  `json:"type"`
+		Events []event `json:"events"`
+	} `json:"ranges"`
+	Versions          []string         `json:"versions"`
+	DatabaseSpecific  databaseSpecific `json:"database_specific"`
+	EcosystemSpecific databaseSpecific `json:"ecosystem_specific"`
+}
+
+// event is a single event of an OSV version range.
+type event struct {
+	Introduced   string `json:"introduced"`
+	Fixed        string `json:"fixed"`
+	LastAffected string `json:"last_affected"`
+}
+
+// databaseSpecific holds the severity published by GitHub-style advisories.
+type databaseSpecific struct {
+	Severity string `json:"severity"`
+}
+
+// versionRange is a parsed interval of affected versions. A nil bound is open.
+type versionRange struct {
+	introduced *pypi.Version
+	fixed      *pypi.Version
+	// lastAffected is an inclusive upper bound.
+	lastAffected *pypi.Version
+}
+
+// contains reports whether a version lies in the range.
+func (r versionRange) contains(v pypi.Version) bool {
+	if r.introduced != nil && v.Compare(*r.introduced) < 0 {
+		return false
+	}
+	if r.fixed != nil && v.Compare(*r.fixed) >= 0 {
+		return false
+	}
+	if r.lastAffected != nil && v.Compare(*r.lastAffected) > 0 {
+		return false
+	}
+	return true
+}
+
+// packageAdvisory is an advisory with the versions of one package it affects.
+type packageAdvisory struct {
+	Advisory
+	ranges   []versionRange
+	versions map[string]bool
+}
+
+// affects reports whether the advisory applies to a version of the package.
+func (a packageAdvisory) affects(v pypi.Version) bool {
+	if a.versions[v.String()] {
+		return true
+	}
+	for _, r := range a.ranges {
+		if r.contains(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// Database indexes the PyPI advisories of an OSV dump by package.
+type Database struct {
+	// Source is the path the database was loaded from.
+	Source string
+	// Loaded is when the database was loaded.
+	Loaded     time.Time
+	advisories map[string][]packageAdvisory
+	count      int
+}
+
+// Count returns the number of PyPI advisories in the database.
+func (db *Database) Count() int {
+	return db.count
+}
+
+// Affecting returns the advisories that apply to a version of a package.
+func (db *Database) Affecting(packageName string, version pypi.Version) []Advisory {
+	var matches []Advisory
+	for _, a := range db.advisories[pypi.NormalizeName(packageName)] {
+		if a.affects(version) {
+			matches = append(matches, a.Advisory)
+		}
+	}
+	return matches
+}
+
+// Load reads an OSV dump from a directory of JSON records, searched
+// recursively, or from a zip archive such as the ones published per ecosystem.
+func Load(path string) (*Database, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening OSV database: %w", err)
+	}
+
+	db := &Database{Source: path, Loaded: time.Now(), advisories: make(map[string][]packageAdvisory)}
+	if info.IsDir() {
+		err = loadDir(db, path)
+	} else {
+		err = loadZip(db, path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for name := range db.advisories {
+		advisories := db.advisories[name]
+		sort.Slice(advisories, func(i, j int) bool { return advisories[i].ID < advisories[j].ID })
+	}
+	return db, nil
+}
+
+// loadDir adds every JSON record below a directory.
+func loadDir(db *Database, dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(d.Name(), ".json") {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("error reading OSV record: %w", err)
+		}
+		defer func() {
+			_ = f.Close() // read-only file, nothing to flush
+		}()
+		return db.add(f, path)
+	})
+}
+
+// loadZip adds every JSON record of a zip archive.
+func loadZip(db *Database, path string) error {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return fmt.Errorf("error opening OSV archive: %w", err)
+	}
+	defer func() {
+		_ = archive.Close() // read-only archive, nothing to flush
+	}()
+
+	for _, file := range archive.File {
+		if file.FileInfo().IsDir() || !strings.HasSuffix(file.Name, ".json") {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return fmt.Errorf("error reading OSV record %s: %w", file.Name, err)
+		}
+		err = db.add(rc, file.Name)
+		_ = rc.Close() // read-only entry, nothing to flush
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// add parses a single OSV record and indexes its PyPI advisories.
+func (db *Database) add(r io.Reader, name string) error {
+	var e entry
+	if err := json.NewDecoder(r).Decode(&e); err != nil {
+		return fmt.Errorf("error decoding OSV record %s: %w", name, err)
+	}
+	if e.ID == "" || e.Withdrawn != "" {
+		return nil
+	}
+
+	added := false
+	for _, a := range e.Affected {
+		if a.Package.Ecosystem != ecosystemPyPI || a.Package.Name == "" {
+			continue
+		}
+		advisory := packageAdvisory{
+			Advisory: Advisory{
+				ID:       e.ID,
+				Aliases:  e.Aliases,
+				Summary:  e.Summary,
+				Severity: severityOf(e.DatabaseSpecific, a.DatabaseSpecific, a.EcosystemSpecific),
+			},
+			ranges:   parseRanges(a),
+			versions: make(map[string]bool, len(a.Versions)),
+		}
+		for _, raw := range a.Versions {
+			if v, err := pypi.ParseVersion(raw); err == nil {
+				advisory.versions[v.String()] = true
+			}
+		}
+		pkg := pypi.NormalizeName(a.Package.Name)
+		db.advisories[pkg] = append(db.advisories[pkg], advisory)
+		added = true
+	}
+	if added {
+		db.count++
+	}
+	return nil
+}
+
+// parseRanges converts the ECOSYSTEM ranges of an affected package into
+// intervals. Events are applied in order: "introduced" opens an interval that
+// the next "fixed" or "last_affected" closes.
+func parseRanges(a affected) []versionRange {
+	var ranges []versionRange
+	for _, r := range a.Ranges {
+		if r.Type != "ECOSYSTEM" {
+			continue
+		}
+		var current *versionRange
+		for _, ev := range r.Events {
+			switch {
+			case ev.Introduced != "":
+				current = &versionRange{}
+				if ev.Introduced != "0" {
+					if v, err := pypi.ParseVersion(ev.Introduced); err == nil {
+						current.introduced = &v
+					}
+				}
+			case current != nil && ev.Fixed != "":
+				if v, err := pypi.ParseVersion(ev.Fixed); err == nil {
+					current.fixed = &v
+				}
+				ranges = append(ranges, *current)
+				current = nil
+			case current != nil && ev.LastAffected != "":
+				if v, err := pypi.ParseVersion(ev.LastAffected); err == nil {
+					current.lastAffected = &v
+				}
+				ranges = append(ranges, *current)
+				current = nil
+			}
+		}
+		if current != nil {
+			// An interval that is never closed affects every later version
+			ranges = append(ranges, *current)
+		}
+	}
+	return ranges
+}
+
+// severityOf returns the first severity stated by an advisory.
+func severityOf(sources ...databaseSpecific) Severity {
+	for _, s := range sources {
+		if severity := ParseSeverity(s.Severity); severity != SeverityUnknown {
+			return severity
+		}
+	}
+	return SeverityUnknown
+}
+
+// ParseSeverity normalizes a severity name; unrecognized names are unknown.
+func ParseSeverity(name string) Severity {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "critical":
+		return SeverityCritical
+	case "high":
+		return SeverityHigh
+	case "medium", "moderate":
+		return SeverityMedium
+	case "low":
+		return SeverityLow
+	default:
+		return SeverityUnknown
+	}
+}
@@ -0,0 +1,175 @@
+package osv
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"python-index-proxy/pypi"
+	"testing"
+)
+
+const requestsRecord = `{
+  "id": "GHSA-9wx4-h78v-vm56",
+  "aliases": ["CVE-2024-35195"],
+  "summary": "Requests session certificate verification bypass",
+  "database_specific": {"severity": "MODERATE"},
+  "affected": [{
+    "package": {"ecosystem": "PyPI", "name": "Requests"},
+    "ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "0"}, {"fixed": "2.32.0"}]}]
+  }]
+}`
+
+const jinjaRecord = `{
+  "id": "PYSEC-2019-217",
+  "affected": [{
+    "package": {"ecosystem": "PyPI", "name": "jinja2"},
+    "ranges": [{"type": "ECOSYSTEM", "events": [
+      {"introduced": "2.0"}, {"last_affected": "2.10.0"},
+      {"introduced": "3.0"}
+    ]}],
+    "versions": ["1.2"]
+  }]
+}`
+
+const withdrawnRecord = `{
+  "id": "GHSA-withdrawn",
+  "withdrawn": "2024-01-01T00:00:00Z",
+  "affected": [{"package": {"ecosystem": "PyPI", "name": "requests"}, "versions": ["2.32.0"]}]
+}`
+
+const npmRecord = `{
+  "id": "GHSA-npm",
+  "affected": [{"package": {"ecosystem": "npm", "name": "requests"}, "versions": ["2.32.0"]}]
+}`
+
+var records = map[string]string{
+	"GHSA-9wx4-h78v-vm56.json": requestsRecord,
+	"PYSEC-2019-217.json":      jinjaRecord,
+	"GHSA-withdrawn.json":      withdrawnRecord,
+	"GHSA-npm.json":            npmRecord,
+}
+
+func TestLoadDirectory(t *testing.T) {
+	dir := t.TempDir()
+	sub := filepath.Join(dir, "PyPI")
+	if err := os.MkdirAll(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range records {
+		if err := os.WriteFile(filepath.Join(sub, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("not a record"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	checkDatabase(t, db)
+}
+
+func TestLoadZip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "all.zip")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive := zip.NewWriter(f)
+	for name, content := range records {
+		w, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := Load(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	checkDatabase(t, db)
+}
+
+func TestLoadErrors(t *testing.T) {
+	if _, err := Load(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("Expected an error for a missing path")
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "bad.json"), []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(dir); err == nil {
+		t.Error("Expected an error for a malformed record")
+	}
+}
+
+// checkDatabase verifies the advisories loaded from the test records.
+func checkDatabase(t *testing.T, db *Database) {
+	t.Helper()
+
+	if db.Count() != 2 {
+		t.Errorf("Expected 2 PyPI advisories, got %d", db.Count())
+	}
+
+	tests := []struct {
+		pkg      string
+		version  string
+		expected string
+	}{
+		{"requests", "2.31.0", "GHSA-9wx4-h78v-vm56"},
+		{"Requests", "1.0", "GHSA-9wx4-h78v-vm56"},
+		{"requests", "2.32.0", ""},
+		{"jinja2", "1.2", "PYSEC-2019-217"},
+		{"Jinja2", "2.10", "PYSEC-2019-217"},
+		{"jinja2", "2.11.0", ""},
+		{"jinja2", "3.1.4", "PYSEC-2019-217"},
+		{"flask", "1.0", ""},
+	}
+	for _, tt := range tests {
+		version, err := pypi.ParseVersion(tt.version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		advisories := db.Affecting(tt.pkg, version)
+		switch {
+		case tt.expected == "" && len(advisories) != 0:
+			t.Errorf("Expected %s %s to be unaffected, got %v", tt.pkg, tt.version, advisories)
+		case tt.expected != "" && (len(advisories) != 1 || advisories[0].ID != tt.expected):
+			t.Errorf("Expected %s %s to be affected by %s, got %v", tt.pkg, tt.version, tt.expected, advisories)
+		}
+	}
+
+	version, _ := pypi.ParseVersion("2.0")
+	if advisories := db.Affecting("requests", version); len(advisories) == 1 && advisories[0].Severity != SeverityMedium {
+		t.Errorf("Expected MODERATE to map to medium, got %s", advisories[0].Severity)
+	}
+}
+
+func TestParseSeverity(t *testing.T) {
+	tests := map[string]Severity{
+		"CRITICAL": SeverityCritical,
+		"high":     SeverityHigh,
+		"Moderate": SeverityMedium,
+		"medium":   SeverityMedium,
+		"LOW":      SeverityLow,
+		"":         SeverityUnknown,
+		"severe":   SeverityUnknown,
+	}
+	for name, expected := range tests {
+		if result := ParseSeverity(name); result != expected {
+			t.Errorf("Expected %q to parse as %s, got %s", name, expected, result)
+		}
+	}
+}
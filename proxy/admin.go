@@ -0,0 +1,19 @@
+package proxy
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// authorizeAdmin checks that a request carries the configured admin token as
+// a bearer token, and refuses it otherwise. Admin endpoints are disabled when
+// no token is configured.
+func (p *Proxy) authorizeAdmin(w http.ResponseWriter, r *http.Request) bool {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if p.config.AdminToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(p.config.AdminToken)) != 1 {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,85 @@
+package proxy
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"python-index-proxy/config"
+	"python-index-proxy/pypi"
+	"strings"
+	"time"
+)
+
+// asOfPrefix starts the path of requests for an as-of index snapshot, e.g.
+// "/as-of/2026-09-01T00:00:00Z/simple/requests/".
+const asOfPrefix = "/as-of/"
+
+// asOfKey is the context key of the snapshot timestamp of a request.
+type asOfKey struct{}
+
+// withAsOf returns a context that serves the index snapshot at the given time.
+func withAsOf(ctx context.Context, asOf time.Time) context.Context {
+	return context.WithValue(ctx, asOfKey{}, asOf)
+}
+
+// asOfFromContext returns the snapshot timestamp of a request, if any.
+func asOfFromContext(ctx context.Context) (time.Time, bool) {
+	asOf, ok := ctx.Value(asOfKey{}).(time.Time)
+	return asOf, ok && !asOf.IsZero()
+}
+
+// splitAsOfPath splits an as-of prefix off a request path. It returns the
+// remaining path, the prefix and the raw timestamp; the prefix is empty for
+// paths without one.
+func splitAsOfPath(path string) (rest, prefix, timestamp string) {
+	if !strings.HasPrefix(path, asOfPrefix) {
+		return path, "", ""
+	}
+	timestamp, rest, found := strings.Cut(strings.TrimPrefix(path, asOfPrefix), "/")
+	if !found {
+		return path, "", ""
+	}
+	return "/" + rest, asOfPrefix + timestamp, timestamp
+}
+
+// requestAsOf returns the snapshot timestamp a request asks for: the path
+// prefix wins over the request header, which wins over the configured default.
+// The zero time means the current state of the indexes.
+func (p *Proxy) requestAsOf(r *http.Request) (time.Time, error) {
+	if _, _, timestamp := splitAsOfPath(r.URL.Path); timestamp != "" {
+		return config.ParseAsOf(timestamp)
+	}
+	if header := r.Header.Get(pypi.HeaderAsOf); header != "" {
+		return config.ParseAsOf(header)
+	}
+	return p.config.AsOfTime()
+}
+
+// snapshotFiles returns the files of an index page that were uploaded at or
+// before the snapshot timestamp of the request. Public files of unknown age
+// are hidden; private ones follow as_of_untimed_private_files.
+func (p *Proxy) snapshotFiles(ctx context.Context, source config.IndexConfig, packageName string, files []pypi.File) []pypi.File {
+	asOf, ok := asOfFromContext(ctx)
+	if !ok || len(files) == 0 {
+		return files
+	}
+
+	times := p.uploadTimes(ctx, source, packageName, files)
+	kept := files[:0:0]
+	for _, f := range files {
+		uploaded, known := times[f.Filename]
+		if !known {
+			if !source.Public && p.config.IncludesUntimedPrivateFiles() {
+				kept = append(kept, f)
+				continue
+			}
+			log.Printf("AS-OF: /simple/%s/ - hiding %s from %s: no known upload time", packageName, f.Filename, source.Name)
+			continue
+		}
+		if uploaded.After(asOf) {
+			continue
+		}
+		kept = append(kept, f)
+	}
+	return kept
+}
@@ -0,0 +1,37 @@
+package proxy
+
+import (
+	"fmt"
+	"log"
+	"python-index-proxy/pypi"
+	"time"
+)
+
+// blockReason explains why a file is on the blocklist, or returns an empty
+// string when no active entry applies to it.
+func (p *Proxy) blockReason(packageName, fileName string) string {
+	now := time.Now()
+	for _, b := range p.blocklist {
+		if b.Active(now) && b.Blocks(packageName, fileName) {
+			return fmt.Sprintf("%s is blocklisted: %s", fileName, b.Reason)
+		}
+	}
+	return ""
+}
+
+// unblockedFiles returns the files of an index page that are not on the blocklist.
+func (p *Proxy) unblockedFiles(packageName string, files []pypi.File) []pypi.File {
+	if len(p.blocklist) == 0 {
+		return files
+	}
+
+	kept := files[:0:0]
+	for _, f := range files {
+		if reason := p.blockReason(packageName, f.Filename); reason != "" {
+			log.Printf("BLOCKLIST: /simple/%s/ - hiding %s", packageName, reason)
+			continue
+		}
+		kept = append(kept, f)
+	}
+	return kept
+}
@@ -0,0 +1,88 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// pageVersionCacheSize bounds the number of served pages whose version is remembered.
+const pageVersionCacheSize = 100000
+
+// pageVersion is the ETag of a rendered page and the time it was first served.
+type pageVersion struct {
+	etag  string
+	since time.Time
+}
+
+// pageETag returns a strong ETag over the rendered, filtered page.
+func pageETag(content []byte) string {
+	sum := sha256.Sum256(content)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// pageLastModified returns the time the rendered page served under a key last
+// changed. Filtering changes pages without any upstream change, so the time
+// follows the served content rather than the upstream validators.
+func (p *Proxy) pageLastModified(key, etag string) time.Time {
+	if v, ok := p.pageVersions.Get(key); ok && v.etag == etag {
+		return v.since
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	p.pageVersions.Add(key, pageVersion{etag: etag, since: now})
+	return now
+}
+
+// etagMatches reports whether an If-None-Match header lists an ETag, using
+// the weak comparison RFC 9110 prescribes for it.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified reports whether a conditional request is satisfied by the
+// current page. If-None-Match takes precedence over If-Modified-Since.
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if header := r.Header.Get("If-None-Match"); header != "" {
+		return etagMatches(header, etag)
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	return !lastModified.After(since)
+}
+
+// pageCacheControl returns the Cache-Control header of package pages.
+func (p *Proxy) pageCacheControl() string {
+	maxAge := int(p.config.PageMaxAge / time.Second)
+	if maxAge <= 0 {
+		return "no-cache"
+	}
+	return fmt.Sprintf("max-age=%d", maxAge)
+}
+
+// writePageValidators sets the validators and caching headers of a rendered
+// page and reports whether the request is answered with 304 Not Modified.
+func (p *Proxy) writePageValidators(w http.ResponseWriter, r *http.Request, key string, content []byte) bool {
+	etag := pageETag(content)
+	lastModified := p.pageLastModified(key, etag)
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", lastModified.Format(http.TimeFormat))
+	w.Header().Set("Cache-Control", p.pageCacheControl())
+
+	if !notModified(r, etag, lastModified) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
@@ -0,0 +1,68 @@
+package proxy
+
+import (
+	"log"
+	"python-index-proxy/config"
+	"python-index-proxy/pypi"
+)
+
+// fileDigestCacheSize bounds the number of served files whose SHA-256 digest is remembered.
+const fileDigestCacheSize = 100000
+
+// digestKey identifies a file listed by an index.
+func digestKey(idx config.IndexConfig, fileName string) string {
+	return idx.Name + "/" + fileName
+}
+
+// rememberDigest records the SHA-256 digest published for a file on a page
+// served from an index, so its download can be verified.
+func (p *Proxy) rememberDigest(idx config.IndexConfig, f pypi.File) {
+	if digest := f.SHA256(); digest != "" {
+		p.fileDigests.Add(digestKey(idx, f.Filename), digest)
+	}
+}
+
+// fileDigest returns the SHA-256 digest a file of an index is expected to
+// have: the one named by the lock files, or else the one published on the
+// page that listed it. It returns an empty string when neither is known. Core
+// metadata files are not verified, as pages publish their digest separately.
+func (p *Proxy) fileDigest(idx config.IndexConfig, packageName, fileName string) string {
+	if isMetadataFile(fileName) {
+		return ""
+	}
+	if p.lock != nil {
+		if digest := p.lock.LockedDigest(packageName, fileName); digest != "" {
+			return digest
+		}
+	}
+	digest, _ := p.fileDigests.Get(digestKey(idx, fileName))
+	return digest
+}
+
+// hashedFiles drops files without a SHA-256 hash fragment when hashes are
+// required, so clients using --require-hashes are never offered files the
+// proxy can't verify.
+func (p *Proxy) hashedFiles(packageName string, files []pypi.File) []pypi.File {
+	if !p.config.RequireHashes {
+		return files
+	}
+
+	kept := files[:0:0]
+	for _, f := range files {
+		if f.SHA256() == "" {
+			log.Printf("HASHES: /simple/%s/ - hiding %s, published without a sha256 hash", packageName, f.Filename)
+			continue
+		}
+		kept = append(kept, f)
+	}
+	return kept
+}
+
+// hashRefusal returns why a file hidden by hashedFiles may not be downloaded,
+// or an empty string when it may. Core metadata follows its distribution file.
+func (p *Proxy) hashRefusal(source config.IndexConfig, packageName, fileName string) string {
+	if !p.config.RequireHashes || p.fileDigest(source, packageName, parentFileName(fileName)) != "" {
+		return ""
+	}
+	return "published without a sha256 hash"
+}
@@ -0,0 +1,372 @@
+package proxy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"python-index-proxy/pypi"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fileCache stores verified downloads on disk, addressed by their SHA-256
+// digest, so repeated and ranged downloads are served without upstream.
+type fileCache struct {
+	dir string
+
+	mu sync.Mutex
+	// fills holds the downloads into the cache in progress, by digest.
+	fills map[string]*cacheFill
+}
+
+// newFileCache opens the file cache in a directory, or returns nil when no
+// directory is configured.
+func newFileCache(dir string) (*fileCache, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &fileCache{dir: dir, fills: make(map[string]*cacheFill)}, nil
+}
+
+// cacheableDigest reports whether a digest can address a cached file.
+func cacheableDigest(digest string) bool {
+	if len(digest) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(digest)
+	return err == nil
+}
+
+// path returns where the file with a digest is stored.
+func (fc *fileCache) path(digest string) string {
+	digest = strings.ToLower(digest)
+	return filepath.Join(fc.dir, digest[:2], digest)
+}
+
+// serve serves a cached file, including ranges and conditional ranges, and
+// reports whether the file was cached.
+func (fc *fileCache) serve(w http.ResponseWriter, r *http.Request, fileName, digest string) bool {
+	if fc == nil || !cacheableDigest(digest) {
+		return false
+	}
+	f, err := os.Open(fc.path(digest))
+	if err != nil {
+		return false
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	log.Printf("FILE CACHE: %s → CACHED (sha256 %s)", fileName, digest)
+	serveVerifiedFile(w, r, fileName, digest, f)
+	return true
+}
+
+// serveVerifiedFile serves a file with a known digest, including ranges and
+// conditional ranges. The ETag is the one proxied downloads carry, so an
+// If-Range from either matches the other.
+func serveVerifiedFile(w http.ResponseWriter, r *http.Request, fileName, digest string, content io.ReadSeeker) {
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("ETag", pypi.FileETag(digest))
+	http.ServeContent(w, r, fileName, time.Time{}, content)
+}
+
+// fillable reports whether a request is served from a fill of the cache
+// rather than proxied: GET requests for files the cache can address.
+func (fc *fileCache) fillable(r *http.Request, digest string) bool {
+	return fc != nil && cacheableDigest(digest) && r.Method == http.MethodGet
+}
+
+// cacheFill is a download of a file into the cache. Every request for the
+// file while it is downloaded reads from the same fill, as far as it got.
+type cacheFill struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+	// path is the file being written, and the cached file once it is verified.
+	path string
+	// size is the size upstream announced, or -1 until it is known.
+	size    int64
+	written int64
+	done    bool
+	err     error
+
+	// readers counts the requests reading the fill; the download is
+	// cancelled when all of them went away before it completed.
+	readers int
+	cancel  context.CancelFunc
+}
+
+// join returns the fill of the file with a digest, starting its download
+// with fetch unless another request already did. Each join must be followed
+// by a leave.
+func (fc *fileCache) join(ctx context.Context, fileName, digest string, fetch func(ctx context.Context, dst io.Writer) error) (*cacheFill, error) {
+	digest = strings.ToLower(digest)
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	if f, ok := fc.fills[digest]; ok {
+		f.readers++
+		log.Printf("FILE CACHE: %s → JOINING download in progress (sha256 %s)", fileName, digest)
+		return f, nil
+	}
+
+	f := &cacheFill{path: fc.path(digest), size: -1, readers: 1}
+	f.cond = sync.NewCond(&f.mu)
+
+	// A fill that completed since the cache was checked left the file behind
+	if info, err := os.Stat(f.path); err == nil {
+		f.size, f.written, f.done = info.Size(), info.Size(), true
+		return f, nil
+	}
+
+	dir := filepath.Dir(f.path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp(dir, ".download-*")
+	if err != nil {
+		return nil, err
+	}
+	f.path = tmp.Name()
+
+	// The download outlives the request that started it, as long as any
+	// request still reads from it
+	fillCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	f.cancel = cancel
+	fc.fills[digest] = f
+
+	log.Printf("FILE CACHE: %s → FILLING (sha256 %s)", fileName, digest)
+	go fc.run(fillCtx, digest, f, tmp, fetch)
+	return f, nil
+}
+
+// leave records that a request stopped reading a fill, and cancels its
+// download when no request reads it anymore before it was received whole.
+func (fc *fileCache) leave(digest string, f *cacheFill) {
+	digest = strings.ToLower(digest)
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	f.readers--
+	if f.readers > 0 || f.cancel == nil {
+		return
+	}
+	f.mu.Lock()
+	received := f.done || f.written == f.size
+	f.mu.Unlock()
+	if received {
+		return
+	}
+	f.cancel()
+	if fc.fills[digest] == f {
+		delete(fc.fills, digest)
+	}
+}
+
+// run downloads a file into a fill and moves it into the cache once it is
+// complete and matches its digest.
+func (fc *fileCache) run(ctx context.Context, digest string, f *cacheFill, tmp *os.File, fetch func(ctx context.Context, dst io.Writer) error) {
+	hasher := sha256.New()
+	err := fetch(ctx, &fillWriter{fill: f, file: tmp, hash: hasher})
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	// Only files matching their address enter the cache, whatever fetch checked
+	if actual := hex.EncodeToString(hasher.Sum(nil)); err == nil && actual != digest {
+		err = fmt.Errorf("%w: expected sha256 %s, got %s", pypi.ErrDigestMismatch, digest, actual)
+	}
+
+	f.mu.Lock()
+	if err == nil {
+		err = os.Rename(f.path, fc.path(digest))
+	}
+	if err == nil {
+		f.path = fc.path(digest)
+	} else {
+		_ = os.Remove(f.path)
+	}
+	if f.size < 0 || err != nil {
+		f.size = f.written
+	}
+	f.done, f.err = true, err
+	f.cond.Broadcast()
+	f.mu.Unlock()
+
+	fc.mu.Lock()
+	if fc.fills[digest] == f {
+		delete(fc.fills, digest)
+	}
+	fc.mu.Unlock()
+	f.cancel()
+}
+
+// fillWriter writes a download to the file of its fill, waking up the
+// requests waiting for its bytes.
+type fillWriter struct {
+	fill *cacheFill
+	file *os.File
+	hash hash.Hash
+}
+
+// Write implements io.Writer.
+func (w *fillWriter) Write(b []byte) (int, error) {
+	n, err := w.file.Write(b)
+	w.hash.Write(b[:n])
+
+	w.fill.mu.Lock()
+	w.fill.written += int64(n)
+	w.fill.cond.Broadcast()
+	w.fill.mu.Unlock()
+	return n, err
+}
+
+// SetSize implements pypi.SizeWriter, so ranges can be served before the
+// download completes.
+func (w *fillWriter) SetSize(size int64) {
+	w.fill.mu.Lock()
+	w.fill.size = size
+	w.fill.cond.Broadcast()
+	w.fill.mu.Unlock()
+}
+
+// wait blocks until the fill reaches a condition, the fill fails, or the
+// request goes away.
+func (f *cacheFill) wait(ctx context.Context, reached func() bool) error {
+	stop := context.AfterFunc(ctx, func() {
+		f.mu.Lock()
+		f.cond.Broadcast()
+		f.mu.Unlock()
+	})
+	defer stop()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for !reached() && !f.done && ctx.Err() == nil {
+		f.cond.Wait()
+	}
+	if f.err != nil {
+		return f.err
+	}
+	return ctx.Err()
+}
+
+// open waits until the size of the file is known and opens it for reading.
+func (f *cacheFill) open(ctx context.Context) (*fillReader, error) {
+	if err := f.wait(ctx, func() bool { return f.size >= 0 }); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, err := os.Open(f.path)
+	if err != nil {
+		return nil, err
+	}
+	return &fillReader{ctx: ctx, fill: f, file: file, size: f.size}, nil
+}
+
+// fillReader reads a file from its fill, waiting for bytes that weren't
+// downloaded yet. It serves http.ServeContent, which only needs the size
+// upfront.
+type fillReader struct {
+	ctx    context.Context
+	fill   *cacheFill
+	file   *os.File
+	size   int64
+	offset int64
+	// err is the error the fill failed with while it was read.
+	err error
+}
+
+// Read implements io.Reader.
+func (r *fillReader) Read(b []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+	var written int64
+	err := r.fill.wait(r.ctx, func() bool {
+		written = r.fill.written
+		return written > r.offset
+	})
+	if err == nil && written <= r.offset {
+		// The download ended short of the announced size
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		r.err = err
+		return 0, err
+	}
+
+	if available := written - r.offset; int64(len(b)) > available {
+		b = b[:available]
+	}
+	n, err := r.file.ReadAt(b, r.offset)
+	r.offset += int64(n)
+	if errors.Is(err, io.EOF) && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+// Seek implements io.Seeker.
+func (r *fillReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, fmt.Errorf("invalid whence %d", whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("negative position %d", offset)
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+// Close closes the file.
+func (r *fillReader) Close() error {
+	return r.file.Close()
+}
+
+// serveFill serves a file, or the requested range of it, from the fill of
+// its digest, downloading it into the cache unless another request already
+// is. Bytes are served as soon as they are downloaded: a part of a file
+// can't be verified on its own, and the whole file only enters the cache once
+// it is. It reports whether the response was started when it fails.
+func (p *Proxy) serveFill(ctx context.Context, w http.ResponseWriter, r *http.Request, fileURL, fileName, digest string) (started bool, err error) {
+	f, err := p.files.join(ctx, fileName, digest, func(ctx context.Context, dst io.Writer) error {
+		return p.client.FetchFile(ctx, fileURL, dst, digest)
+	})
+	if err != nil {
+		return false, err
+	}
+	defer p.files.leave(digest, f)
+
+	reader, err := f.open(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		_ = reader.Close() // read-only file, nothing to flush
+	}()
+
+	serveVerifiedFile(w, r, fileName, digest, reader)
+	if reader.err != nil && ctx.Err() == nil {
+		return true, reader.err
+	}
+	return true, nil
+}
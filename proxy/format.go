@@ -0,0 +1,81 @@
+package proxy
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"python-index-proxy/pypi"
+	"strconv"
+	"strings"
+)
+
+// pageFormat is a representation of a Simple API project page.
+type pageFormat struct {
+	// contentType is the media type sent in the Content-Type header.
+	contentType string
+	// json reports whether the page is rendered as PEP 691 JSON.
+	json bool
+}
+
+var (
+	formatLegacyHTML = pageFormat{contentType: pypi.ContentTypeLegacyHTML + "; charset=utf-8"}
+	formatHTML       = pageFormat{contentType: pypi.ContentTypeHTML}
+	formatJSON       = pageFormat{contentType: pypi.ContentTypeJSON, json: true}
+)
+
+// negotiateFormat selects the page format for a request from the format query
+// parameter or the Accept header, as PyPI does. Requests without a supported
+// preference get the legacy HTML page.
+func negotiateFormat(r *http.Request) pageFormat {
+	accept := r.URL.Query().Get("format")
+	if accept == "" {
+		accept = r.Header.Get("Accept")
+	}
+
+	best, bestQ := formatLegacyHTML, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+
+		format, ok := formatForMediaType(mediaType)
+		if !ok || q <= bestQ {
+			continue
+		}
+		best, bestQ = format, q
+	}
+	return best
+}
+
+// formatForMediaType maps an Accept media type to a page format.
+func formatForMediaType(mediaType string) (pageFormat, bool) {
+	switch mediaType {
+	case pypi.ContentTypeJSON, "application/vnd.pypi.simple.latest+json":
+		return formatJSON, true
+	case pypi.ContentTypeHTML, "application/vnd.pypi.simple.latest+html":
+		return formatHTML, true
+	case pypi.ContentTypeLegacyHTML, "text/*", "*/*":
+		return formatLegacyHTML, true
+	}
+	return pageFormat{}, false
+}
+
+// renderFiles renders a list of files as a project page in the given format.
+func renderFiles(packageName string, files []pypi.File, format pageFormat) ([]byte, error) {
+	if format.json {
+		page, err := pypi.RenderProjectJSON(packageName, files)
+		if err != nil {
+			return nil, fmt.Errorf("error rendering page: %w", err)
+		}
+		return page, nil
+	}
+	return pypi.RenderProjectPage(packageName, files), nil
+}
@@ -0,0 +1,447 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"python-index-proxy/config"
+	"python-index-proxy/pypi"
+	"strings"
+	"time"
+)
+
+// jsonAPIPrefix is the first path segment of the PyPI JSON API.
+const jsonAPIPrefix = "pypi"
+
+// errNoJSONAPI is returned when a package routes to an index without a JSON API.
+var errNoJSONAPI = errors.New("index has no JSON API")
+
+// jsonAPIFile is a file listed by a JSON API response. Fields the proxy
+// doesn't look at are passed through as served by the index.
+type jsonAPIFile map[string]json.RawMessage
+
+// stringField returns a string field of a file, or an empty string.
+func (f jsonAPIFile) stringField(name string) string {
+	var value string
+	if raw, ok := f[name]; ok {
+		_ = json.Unmarshal(raw, &value)
+	}
+	return value
+}
+
+// file converts a JSON API file to a file record, with the SHA-256 digest
+// as hash fragment and the yank reason as data-yanked, so the serving
+// policies of index pages apply to it unchanged.
+func (f jsonAPIFile) file() pypi.File {
+	file := pypi.File{
+		Filename:   f.stringField("filename"),
+		URL:        f.stringField("url"),
+		Attrs:      make(map[string]string),
+		UploadTime: f.stringField("upload_time_iso_8601"),
+	}
+
+	var digests map[string]string
+	if raw, ok := f["digests"]; ok && json.Unmarshal(raw, &digests) == nil && digests["sha256"] != "" {
+		file.URL = strings.SplitN(file.URL, "#", 2)[0] + "#sha256=" + digests["sha256"]
+	}
+
+	var yanked bool
+	if raw, ok := f["yanked"]; ok && json.Unmarshal(raw, &yanked) == nil && yanked {
+		file.Attrs["data-yanked"] = f.stringField("yanked_reason")
+	}
+	return file
+}
+
+// update writes the link and yank status of a served file record back to the
+// JSON API file. Links are made absolute against the proxy base URL.
+func (f jsonAPIFile) update(file pypi.File, baseURL string) error {
+	link := strings.SplitN(file.URL, "#", 2)[0]
+	if strings.HasPrefix(link, "/") {
+		link = baseURL + link
+	}
+	fields := map[string]any{"url": link}
+	if reason, yanked := file.Yanked(); yanked {
+		fields["yanked"] = true
+		fields["yanked_reason"] = nil
+		if reason != "" {
+			fields["yanked_reason"] = reason
+		}
+	}
+
+	for name, value := range fields {
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		f[name] = raw
+	}
+	return nil
+}
+
+// proxyBaseURL returns the scheme and host clients reach the proxy at.
+func proxyBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// getProjectJSON returns the JSON API response of a project or release of an
+// index, from cache when available.
+func (p *Proxy) getProjectJSON(ctx context.Context, source config.IndexConfig, packageName, version string) ([]byte, error) {
+	key := packageName
+	if version != "" {
+		key += "/" + version
+	}
+
+	if cached, ok := p.cache.GetProjectJSON(source.Name, key); ok {
+		log.Printf("ROUTING: /pypi/%s/json → CACHED (from %s)", key, source.Name)
+		return cached.Data, nil
+	}
+
+	apiURL := source.JSONAPIBaseURL()
+	log.Printf("ROUTING: /pypi/%s/json → FETCHING (from %s)", key, apiURL)
+	data, err := p.client.GetProjectJSON(ctx, apiURL, packageName, version)
+	if err != nil {
+		return nil, err
+	}
+
+	p.cache.SetProjectJSON(source.Name, key, data)
+	return data, nil
+}
+
+// filterProjectJSON applies the serving policies of index pages to the urls
+// and releases of a JSON API response. Releases whose files are all hidden
+// are dropped; file links point at the proxy.
+func (p *Proxy) filterProjectJSON(ctx context.Context, source config.IndexConfig, packageName string, data []byte, baseURL string) ([]byte, error) {
+	var project map[string]json.RawMessage
+	if err := json.Unmarshal(data, &project); err != nil {
+		return nil, fmt.Errorf("error decoding JSON API response: %w", err)
+	}
+
+	var urls []jsonAPIFile
+	if raw, ok := project["urls"]; ok {
+		if err := json.Unmarshal(raw, &urls); err != nil {
+			return nil, fmt.Errorf("error decoding urls: %w", err)
+		}
+	}
+	var releases map[string][]jsonAPIFile
+	if raw, ok := project["releases"]; ok {
+		if err := json.Unmarshal(raw, &releases); err != nil {
+			return nil, fmt.Errorf("error decoding releases: %w", err)
+		}
+	}
+
+	// Every file goes through the policies once, however often it is listed
+	var files []pypi.File
+	listed := make(map[string]bool)
+	collect := func(entries []jsonAPIFile) {
+		for _, entry := range entries {
+			f := entry.file()
+			if f.Filename == "" || listed[f.Filename] {
+				continue
+			}
+			listed[f.Filename] = true
+			files = append(files, f)
+		}
+	}
+	collect(urls)
+	for _, entries := range releases {
+		collect(entries)
+	}
+
+	served := make(map[string]pypi.File, len(files))
+	for _, f := range p.servedFiles(ctx, source, packageName, files) {
+		p.rewriteFile(source, packageName, &f)
+		served[f.Filename] = f
+	}
+
+	keep := func(entries []jsonAPIFile) ([]jsonAPIFile, error) {
+		kept := make([]jsonAPIFile, 0, len(entries))
+		for _, entry := range entries {
+			f, ok := served[entry.stringField("filename")]
+			if !ok {
+				continue
+			}
+			if err := entry.update(f, baseURL); err != nil {
+				return nil, err
+			}
+			kept = append(kept, entry)
+		}
+		return kept, nil
+	}
+
+	var err error
+	if urls != nil {
+		if urls, err = keep(urls); err != nil {
+			return nil, err
+		}
+		if project["urls"], err = json.Marshal(urls); err != nil {
+			return nil, err
+		}
+	}
+	if releases != nil {
+		for version, entries := range releases {
+			kept, err := keep(entries)
+			if err != nil {
+				return nil, err
+			}
+			if len(kept) == 0 && len(entries) > 0 {
+				delete(releases, version)
+				continue
+			}
+			releases[version] = kept
+		}
+		if project["releases"], err = json.Marshal(releases); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(project)
+}
+
+// indexProjectJSON returns the filtered JSON API response of the index a
+// package routes to, along with the value of the source header.
+func (p *Proxy) indexProjectJSON(ctx context.Context, packageName, version string, exists map[string]bool, baseURL string) (sourceHeader string, body []byte, found bool, err error) {
+	source, found, err := p.selectIndex(packageName, exists)
+	if err != nil || !found {
+		return "", nil, false, err
+	}
+
+	// Never fall back to another index: the package routes to this one
+	if source.JSONAPIBaseURL() == "" {
+		log.Printf("ROUTING: /pypi/%s/json - index %s has no JSON API", packageName, source.Name)
+		return "", nil, false, errNoJSONAPI
+	}
+
+	data, err := p.getProjectJSON(ctx, source, packageName, version)
+	if err != nil {
+		return "", nil, false, err
+	}
+	body, err = p.filterProjectJSON(ctx, source, packageName, data, baseURL)
+	if err != nil {
+		return "", nil, false, fmt.Errorf("error filtering JSON API response: %w", err)
+	}
+	return source.URL, body, true, nil
+}
+
+// mergeProjectJSON combines the filtered JSON API responses of every eligible
+// index that has a merged package, like mergePackagePage combines their
+// pages: private files win on filename collisions. The info of the first
+// index is kept, and the urls of a project response list the files of the
+// version that info names.
+func (p *Proxy) mergeProjectJSON(ctx context.Context, packageName, version string, exists map[string]bool, baseURL string) (sourceHeader string, body []byte, found bool, err error) {
+	candidates, err := p.candidateIndexes(packageName, exists)
+	if err != nil || len(candidates) == 0 {
+		return "", nil, false, err
+	}
+	mergeOrder(candidates)
+
+	var merged map[string]json.RawMessage
+	var urls []jsonAPIFile
+	releases := make(map[string][]jsonAPIFile)
+	owners := make(map[string]string)
+	sources := make([]string, 0, len(candidates))
+
+	// owns reports whether a file is served from an index, claiming it for the
+	// index when no earlier index listed it
+	owns := func(idx config.IndexConfig, entry jsonAPIFile) bool {
+		fileName := entry.stringField("filename")
+		owner, ok := owners[fileName]
+		if !ok {
+			owners[fileName] = idx.Name
+			return true
+		}
+		if owner != idx.Name {
+			log.Printf("MERGE: /pypi/%s/json - %s from %s shadowed by an earlier index", packageName, fileName, idx.Name)
+		}
+		return owner == idx.Name
+	}
+
+	for _, idx := range candidates {
+		if idx.JSONAPIBaseURL() == "" {
+			log.Printf("MERGE: /pypi/%s/json - index %s has no JSON API", packageName, idx.Name)
+			continue
+		}
+		data, err := p.getProjectJSON(ctx, idx, packageName, version)
+		if errors.Is(err, pypi.ErrNotFound) {
+			// A release may only exist on some of the indexes
+			continue
+		}
+		if err != nil {
+			return "", nil, false, err
+		}
+		filtered, err := p.filterProjectJSON(ctx, idx, packageName, data, baseURL)
+		if err != nil {
+			return "", nil, false, fmt.Errorf("error filtering JSON API response from %s: %w", idx.Name, err)
+		}
+
+		var project map[string]json.RawMessage
+		var indexURLs []jsonAPIFile
+		var indexReleases map[string][]jsonAPIFile
+		if err := json.Unmarshal(filtered, &project); err != nil {
+			return "", nil, false, fmt.Errorf("error decoding JSON API response from %s: %w", idx.Name, err)
+		}
+		if raw, ok := project["urls"]; ok {
+			if err := json.Unmarshal(raw, &indexURLs); err != nil {
+				return "", nil, false, fmt.Errorf("error decoding urls from %s: %w", idx.Name, err)
+			}
+		}
+		if raw, ok := project["releases"]; ok {
+			if err := json.Unmarshal(raw, &indexReleases); err != nil {
+				return "", nil, false, fmt.Errorf("error decoding releases from %s: %w", idx.Name, err)
+			}
+		}
+		if merged == nil {
+			merged = project
+		}
+		sources = append(sources, idx.URL)
+
+		for _, entry := range indexURLs {
+			if owns(idx, entry) {
+				urls = append(urls, entry)
+			}
+		}
+		for release, entries := range indexReleases {
+			kept, ok := releases[release]
+			if !ok {
+				kept = []jsonAPIFile{}
+			}
+			for _, entry := range entries {
+				if owns(idx, entry) {
+					kept = append(kept, entry)
+				}
+			}
+			releases[release] = kept
+		}
+	}
+	if merged == nil {
+		return "", nil, false, nil
+	}
+
+	if _, ok := merged["releases"]; ok {
+		// The urls of a project response are the files of its latest release
+		var info struct {
+			Version string `json:"version"`
+		}
+		if raw, ok := merged["info"]; ok {
+			_ = json.Unmarshal(raw, &info)
+		}
+		urls = releases[info.Version]
+		if merged["releases"], err = json.Marshal(releases); err != nil {
+			return "", nil, false, err
+		}
+	}
+	if urls == nil {
+		urls = []jsonAPIFile{}
+	}
+	if merged["urls"], err = json.Marshal(urls); err != nil {
+		return "", nil, false, err
+	}
+
+	body, err = json.Marshal(merged)
+	if err != nil {
+		return "", nil, false, err
+	}
+	log.Printf("ROUTING: /pypi/%s/json → MERGED (%s)", packageName, strings.Join(sources, ", "))
+	return strings.Join(sources, ", "), body, true, nil
+}
+
+// HandleProjectJSON serves the PyPI JSON API of a project, at
+// /pypi/{project}/json, or of one of its releases, at
+// /pypi/{project}/{version}/json. The response comes from the index the
+// package routes to, or from every index for merged packages, filtered by the
+// same policies as its index page.
+func (p *Proxy) HandleProjectJSON(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) < 3 || len(pathParts) > 4 || pathParts[0] != jsonAPIPrefix || pathParts[len(pathParts)-1] != "json" {
+		http.Error(w, "Invalid JSON API path", http.StatusBadRequest)
+		return
+	}
+
+	packageName, version := pathParts[1], ""
+	if len(pathParts) == 4 {
+		version = pathParts[2]
+	}
+	if packageName == "" || (len(pathParts) == 4 && version == "") {
+		http.Error(w, "Package name is required", http.StatusBadRequest)
+		return
+	}
+
+	// Redirect non-canonical names to their normalized URL, as PyPI does
+	if normalizedName := pypi.NormalizeName(packageName); normalizedName != packageName {
+		target := "/" + jsonAPIPrefix + "/" + normalizedName + "/"
+		if version != "" {
+			target += version + "/"
+		}
+		target += "json"
+		if r.URL.RawQuery != "" {
+			target += "?" + r.URL.RawQuery
+		}
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+		return
+	}
+
+	// Snapshots hide the same files from the JSON API as from index pages
+	asOf, err := p.requestAsOf(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !asOf.IsZero() {
+		ctx = withAsOf(ctx, asOf)
+		w.Header().Set(pypi.HeaderAsOf, asOf.UTC().Format(time.RFC3339))
+	}
+
+	if p.lockMissRefusal(w, r, packageName) {
+		return
+	}
+
+	exists, err := p.CheckPackageExists(ctx, packageName)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error checking package existence: %v", err), p.existenceErrorStatus(packageName))
+		return
+	}
+
+	var sourceHeader string
+	var body []byte
+	var found bool
+	if p.isMergePackage(packageName) {
+		sourceHeader, body, found, err = p.mergeProjectJSON(ctx, packageName, version, exists, proxyBaseURL(r))
+	} else {
+		sourceHeader, body, found, err = p.indexProjectJSON(ctx, packageName, version, exists, proxyBaseURL(r))
+	}
+	switch {
+	case errors.Is(err, errPackageBlocked):
+		http.Error(w, "Package blocked by routing rule", http.StatusForbidden)
+		return
+	case errors.Is(err, errNoJSONAPI):
+		http.Error(w, "Package not found: index has no JSON API", http.StatusNotFound)
+		return
+	case errors.Is(err, errPrivateNamespaceMiss), errors.Is(err, pypi.ErrNotFound), err == nil && !found:
+		http.Error(w, "Package not found", http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, fmt.Sprintf("Error retrieving JSON API response: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set(pypi.ResponseHeaderSource, sourceHeader)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Vary", pypi.HeaderAsOf)
+
+	// For HEAD requests, only send headers, not body
+	if r.Method == "HEAD" {
+		return
+	}
+
+	if _, err := w.Write(body); err != nil {
+		http.Error(w, fmt.Sprintf("Error writing response: %v", err), http.StatusInternalServerError)
+		return
+	}
+}
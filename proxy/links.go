@@ -0,0 +1,212 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"python-index-proxy/config"
+	"python-index-proxy/pypi"
+	"strings"
+)
+
+// fileURLCacheSize bounds the number of rewritten file links whose upstream URL is remembered.
+const fileURLCacheSize = 100000
+
+// fileOriginCacheSize bounds the number of served file names whose upstream URL is remembered.
+const fileOriginCacheSize = 100000
+
+// originKey keys the upstream location of a file name listed by an index.
+// Indexes may list the same file name, so the index is part of the key.
+func originKey(index, fileName string) string {
+	return index + "\x00" + fileName
+}
+
+// errFileNotListed is returned when a file isn't listed on the package page of an index.
+var errFileNotListed = errors.New("file not listed on package page")
+
+// fileLocation is the upstream location of a file served under a rewritten link.
+type fileLocation struct {
+	// Index is the name of the index that listed the file.
+	Index string
+	// URL is the absolute upstream URL of the file, without fragment.
+	URL string
+}
+
+// pageURL returns the URL of a package page on an index, which relative file
+// links are resolved against.
+func pageURL(idx config.IndexConfig, packageName string) string {
+	return strings.TrimSuffix(idx.URL, "/") + "/" + packageName + "/"
+}
+
+// upstreamFileURL resolves a file link listed on a package page of an index
+// to its absolute upstream URL, returning the hash fragment separately.
+func upstreamFileURL(idx config.IndexConfig, packageName, href string) (*url.URL, string, error) {
+	base, err := url.Parse(pageURL(idx, packageName))
+	if err != nil {
+		return nil, "", err
+	}
+	ref, err := url.Parse(href)
+	if err != nil {
+		return nil, "", err
+	}
+
+	upstream := base.ResolveReference(ref)
+	frag := upstream.EscapedFragment()
+	upstream.Fragment, upstream.RawFragment = "", ""
+	return upstream, frag, nil
+}
+
+// rewriteFile turns the link of a file listed by an index into a
+// proxy-relative URL of the form /packages/{index}/{upstream path}, keeping
+// the hash fragment. The upstream URL is remembered under both the rewritten
+// path and the index and file name, so downloads never have to guess the file
+// host.
+func (p *Proxy) rewriteFile(idx config.IndexConfig, packageName string, f *pypi.File) {
+	p.rememberDigest(idx, *f)
+
+	upstream, frag, err := upstreamFileURL(idx, packageName, f.URL)
+	if err != nil {
+		return
+	}
+
+	loc := fileLocation{Index: idx.Name, URL: upstream.String()}
+	proxyPath := "/" + packagesPath + "/" + url.PathEscape(idx.Name) + upstream.EscapedPath()
+	p.fileURLs.Add(proxyPath, loc)
+	p.fileOrigins.Add(originKey(idx.Name, f.Filename), loc)
+
+	f.URL = proxyPath
+	if frag != "" {
+		f.URL += "#" + frag
+	}
+}
+
+// rewriteFiles rewrites the links of files listed by an index.
+func (p *Proxy) rewriteFiles(idx config.IndexConfig, packageName string, files []pypi.File) {
+	for i := range files {
+		p.rewriteFile(idx, packageName, &files[i])
+	}
+}
+
+// lookupFileLocation resolves a requested file to the index that listed it
+// and its upstream URL, as remembered when the page was served: by rewritten
+// path, or else by file name on the index the path names. Bare file names are
+// looked up on the indexes the package may be served from, private ones
+// first, as on merged pages. Core metadata files resolve through their
+// distribution file.
+func (p *Proxy) lookupFileLocation(requestPath, packageName, fileName string) (config.IndexConfig, string, bool) {
+	parentPath, suffix := requestPath, ""
+	if isMetadataFile(requestPath) {
+		parentPath, suffix = parentFileName(requestPath), metadataSuffix
+	}
+
+	loc, ok := p.fileURLs.Get(parentPath)
+	if !ok {
+		loc, ok = p.lookupFileOrigin(requestPath, packageName, parentFileName(fileName))
+	}
+	if !ok {
+		return config.IndexConfig{}, "", false
+	}
+	idx, found := p.indexByName(loc.Index)
+	if !found {
+		return config.IndexConfig{}, "", false
+	}
+	return idx, loc.URL + suffix, true
+}
+
+// lookupFileOrigin finds the remembered upstream location of a distribution
+// file by name, on the index a rewritten path names or, for bare file names,
+// on the first eligible index that listed it.
+func (p *Proxy) lookupFileOrigin(requestPath, packageName, distName string) (fileLocation, bool) {
+	if idx, ok := p.pathIndex(requestPath); ok {
+		return p.fileOrigins.Get(originKey(idx.Name, distName))
+	}
+
+	target, _, _ := p.resolveTarget(packageName)
+	for _, public := range []bool{false, true} {
+		for _, idx := range p.indexes {
+			if idx.Public != public || !eligible(idx, target) {
+				continue
+			}
+			if loc, ok := p.fileOrigins.Get(originKey(idx.Name, distName)); ok {
+				return loc, true
+			}
+		}
+	}
+	return fileLocation{}, false
+}
+
+// pathIndex returns the index named by a rewritten file path of the form
+// /packages/{index}/{upstream path}.
+func (p *Proxy) pathIndex(requestPath string) (config.IndexConfig, bool) {
+	rest, ok := strings.CutPrefix(requestPath, "/"+packagesPath+"/")
+	if !ok {
+		return config.IndexConfig{}, false
+	}
+	name, upstreamPath, ok := strings.Cut(rest, "/")
+	if !ok || upstreamPath == "" {
+		return config.IndexConfig{}, false
+	}
+	idxName, err := url.PathUnescape(name)
+	if err != nil {
+		return config.IndexConfig{}, false
+	}
+	return p.indexByName(idxName)
+}
+
+// locateFile finds the upstream URL of a file that isn't remembered by
+// fetching the package page of an index, and remembers it. Core metadata
+// files resolve through their distribution file.
+func (p *Proxy) locateFile(ctx context.Context, idx config.IndexConfig, packageName, fileName string) (string, error) {
+	page, err := p.getPackagePage(ctx, idx, packageName)
+	if err != nil {
+		return "", err
+	}
+	files, err := parsePage(page)
+	if err != nil {
+		return "", fmt.Errorf("error parsing package page: %w", err)
+	}
+
+	distName, suffix := fileName, ""
+	if isMetadataFile(fileName) {
+		distName, suffix = parentFileName(fileName), metadataSuffix
+	}
+	for _, f := range files {
+		if f.Filename != distName {
+			continue
+		}
+		p.rewriteFile(idx, packageName, &f)
+		loc, _ := p.fileOrigins.Get(originKey(idx.Name, distName))
+		log.Printf("ROUTING: %s → not remembered, found on %s page (%s)", fileName, idx.Name, loc.URL)
+		return loc.URL + suffix, nil
+	}
+	return "", errFileNotListed
+}
+
+// indexByName returns the index with the given name.
+func (p *Proxy) indexByName(name string) (config.IndexConfig, bool) {
+	for _, idx := range p.indexes {
+		if idx.Name == name {
+			return idx, true
+		}
+	}
+	return config.IndexConfig{}, false
+}
+
+// checkFileIndex verifies that a package may still be served from the index a
+// rewritten link points at, so crafted paths can't bypass routing.
+func (p *Proxy) checkFileIndex(w http.ResponseWriter, packageName string, idx config.IndexConfig) bool {
+	target, _ := p.routeTarget(packageName)
+	if target == config.TargetBlock {
+		http.Error(w, "Package blocked by routing rule", http.StatusForbidden)
+		return false
+	}
+	if !eligible(idx, target) {
+		log.Printf("SECURITY: refusing %s from %s index (routing target %q)", packageName, idx.Name, target)
+		http.Error(w, "File not found", http.StatusNotFound)
+		return false
+	}
+	return true
+}
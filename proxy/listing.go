@@ -0,0 +1,180 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"python-index-proxy/config"
+	"python-index-proxy/pypi"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rootListingTimeout bounds a fetch of the root listings. The public listing
+// is large, so it is not bound by the client timeout of other requests.
+const rootListingTimeout = 5 * time.Minute
+
+// rootListing caches the merged project list served at /simple/. The lock only
+// guards the cached result; listings are fetched without holding it.
+type rootListing struct {
+	mu       sync.Mutex
+	projects []string
+	sources  []string
+	fetched  time.Time
+	// pending is the fetch in flight, shared by every request that misses the cache.
+	pending *rootFetch
+	// generation counts resets, so fetches started before one aren't cached.
+	generation int
+}
+
+// rootFetch is a fetch of the root listings and, once done is closed, its result.
+type rootFetch struct {
+	done       chan struct{}
+	generation int
+	projects   []string
+	sources    []string
+	err        error
+}
+
+// reset drops the cached listing after a project was added, so the next
+// request fetches it again rather than joining a fetch that predates it.
+func (l *rootListing) reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.projects, l.sources, l.pending = nil, nil, nil
+	l.generation++
+}
+
+// listingIndexes returns the indexes whose root listings are merged into /simple/.
+func (p *Proxy) listingIndexes() []config.IndexConfig {
+	var indexes []config.IndexConfig
+	for _, idx := range p.indexes {
+		if idx.Public && !p.config.RootIncludePublic {
+			continue
+		}
+		indexes = append(indexes, idx)
+	}
+	return indexes
+}
+
+// listable reports whether a project listed by an index may appear in the root
+// listing, i.e. it is not blocked and the index may serve it.
+func (p *Proxy) listable(packageName string, idx config.IndexConfig) bool {
+	// Resolved without logging, as this runs for every listed project
+	target, _, _ := p.resolveTarget(packageName)
+	return target != config.TargetBlock && eligible(idx, target)
+}
+
+// rootProjects returns the sorted union of the root listings of the listing
+// indexes, from cache while it is fresh. Concurrent requests share a single
+// fetch. Partial listings are not cached.
+func (p *Proxy) rootProjects(ctx context.Context) (projects, sources []string, err error) {
+	ttl := time.Duration(p.config.RootListingTTL) * time.Minute
+
+	p.root.mu.Lock()
+	if p.root.projects != nil && time.Since(p.root.fetched) < ttl {
+		projects, sources = p.root.projects, p.root.sources
+		p.root.mu.Unlock()
+		log.Printf("ROUTING: /simple/ → CACHED (%d projects)", len(projects))
+		return projects, sources, nil
+	}
+	fetch := p.root.pending
+	if fetch == nil {
+		fetch = &rootFetch{done: make(chan struct{}), generation: p.root.generation}
+		p.root.pending = fetch
+		// The fetch outlives the request that started it, as others wait on it
+		go p.fetchRootProjects(context.WithoutCancel(ctx), fetch, ttl)
+	}
+	p.root.mu.Unlock()
+
+	select {
+	case <-fetch.done:
+		return fetch.projects, fetch.sources, fetch.err
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+}
+
+// fetchRootProjects fetches the root listings for a pending fetch and caches
+// the complete result.
+func (p *Proxy) fetchRootProjects(ctx context.Context, fetch *rootFetch, ttl time.Duration) {
+	ctx, cancel := context.WithTimeout(ctx, rootListingTimeout)
+	defer cancel()
+
+	seen := make(map[string]bool)
+	projects := []string{}
+	var sources []string
+	var firstErr error
+
+	for _, idx := range p.listingIndexes() {
+		log.Printf("ROUTING: /simple/ → FETCHING (from %s)", idx.URL)
+		err := p.client.ListProjects(ctx, idx.URL, func(name string) error {
+			normalizedName := pypi.NormalizeName(name)
+			if seen[normalizedName] || !p.listable(normalizedName, idx) {
+				return nil
+			}
+			seen[normalizedName] = true
+			projects = append(projects, normalizedName)
+			return nil
+		})
+		if err != nil {
+			log.Printf("ROUTING: /simple/ → ERROR (from %s): %v", idx.URL, err)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("error listing %s index: %w", idx.Name, err)
+			}
+			continue
+		}
+		sources = append(sources, idx.URL)
+	}
+	sort.Strings(projects)
+
+	if len(sources) == 0 && firstErr != nil {
+		fetch.err = firstErr
+	} else {
+		fetch.projects, fetch.sources = projects, sources
+	}
+
+	p.root.mu.Lock()
+	current := fetch.generation == p.root.generation
+	if current && firstErr == nil && p.config.CacheEnabled && ttl > 0 {
+		p.root.projects, p.root.sources, p.root.fetched = projects, sources, time.Now()
+	}
+	if p.root.pending == fetch {
+		p.root.pending = nil
+	}
+	p.root.mu.Unlock()
+	close(fetch.done)
+}
+
+// HandleSimpleIndex serves the PEP 503/691 root listing of every project
+// available through the proxy.
+func (p *Proxy) HandleSimpleIndex(w http.ResponseWriter, r *http.Request) {
+	projects, sources, err := p.rootProjects(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error listing projects: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	format := negotiateFormat(r)
+	w.Header().Set(pypi.ResponseHeaderSource, strings.Join(sources, ", "))
+	w.Header().Set("Content-Type", format.contentType)
+	w.Header().Set("Vary", "Accept")
+
+	// For HEAD requests, only send headers, not body
+	if r.Method == "HEAD" {
+		return
+	}
+
+	// The listing is written as it is rendered rather than buffered
+	if format.json {
+		err = pypi.WriteRootJSON(w, projects)
+	} else {
+		err = pypi.WriteRootPage(w, projects)
+	}
+	if err != nil {
+		log.Printf("ROUTING: /simple/ → error writing response: %v", err)
+	}
+}
@@ -0,0 +1,179 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"python-index-proxy/config"
+	"python-index-proxy/lock"
+	"python-index-proxy/pypi"
+	"sort"
+	"sync"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+)
+
+// lockReportSize bounds the number of distinct violations kept in the report,
+// as package names come from clients.
+const lockReportSize = 10000
+
+// lockViolation is a request for an artifact the lock files don't allow.
+type lockViolation struct {
+	Package   string    `json:"package"`
+	File      string    `json:"file,omitempty"`
+	Reason    string    `json:"reason"`
+	Client    string    `json:"client"`
+	Count     int       `json:"count"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// lockReport collects lock violations, counting repeated requests once. The
+// least recently seen violations are dropped once the report is full.
+type lockReport struct {
+	mu         sync.Mutex
+	violations *lru.Cache[string, *lockViolation]
+	// dropped counts the violations dropped from the full report.
+	dropped int
+}
+
+// newLockReport creates a report that keeps up to size violations.
+func newLockReport(size int) (*lockReport, error) {
+	violations, err := lru.New[string, *lockViolation](size)
+	if err != nil {
+		return nil, err
+	}
+	return &lockReport{violations: violations}, nil
+}
+
+// record adds a violation to the report.
+func (lr *lockReport) record(packageName, fileName, reason, client string) {
+	now := time.Now()
+	key := packageName + "\x00" + fileName + "\x00" + reason
+
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	if v, ok := lr.violations.Get(key); ok {
+		v.Count++
+		v.LastSeen = now
+		v.Client = client
+		return
+	}
+	evicted := lr.violations.Add(key, &lockViolation{
+		Package:   packageName,
+		File:      fileName,
+		Reason:    reason,
+		Client:    client,
+		Count:     1,
+		FirstSeen: now,
+		LastSeen:  now,
+	})
+	if evicted {
+		lr.dropped++
+	}
+}
+
+// snapshot returns the violations in the order they were first seen and the
+// number of violations dropped from the full report.
+func (lr *lockReport) snapshot() ([]lockViolation, int) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	violations := make([]lockViolation, 0, lr.violations.Len())
+	for _, v := range lr.violations.Values() {
+		violations = append(violations, *v)
+	}
+	sort.Slice(violations, func(i, j int) bool {
+		return violations[i].FirstSeen.Before(violations[j].FirstSeen)
+	})
+	return violations, lr.dropped
+}
+
+// loadLock loads the configured lock files, or returns nil when lock mode is off.
+func loadLock(cfg *config.Config) (*lock.Lock, error) {
+	if len(cfg.LockFiles) == 0 {
+		return nil, nil
+	}
+	pins, err := lock.Load(cfg.LockFiles)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("LOCK: serving only artifacts pinned in %s", pins.Name())
+	return pins, nil
+}
+
+// lockMissRefusal refuses a package page that the lock files don't pin,
+// recording the violation. It returns false when the package is pinned.
+func (p *Proxy) lockMissRefusal(w http.ResponseWriter, r *http.Request, packageName string) bool {
+	if p.lock == nil || p.lock.Has(packageName) {
+		return false
+	}
+
+	reason := fmt.Sprintf("%s is not in %s", packageName, p.lock.Name())
+	log.Printf("LOCK: /simple/%s/ refused for client %s: %s", packageName, clientAddr(r), reason)
+	p.lockReport.record(packageName, "", reason, clientAddr(r))
+	http.Error(w, "Package not found: "+reason, http.StatusNotFound)
+	return true
+}
+
+// lockedFiles returns the files of an index page that the lock files allow.
+func (p *Proxy) lockedFiles(packageName string, files []pypi.File) []pypi.File {
+	if p.lock == nil {
+		return files
+	}
+
+	kept := files[:0:0]
+	for _, f := range files {
+		if reason := p.lock.Refusal(packageName, f.Filename, f.SHA256()); reason != "" {
+			continue
+		}
+		kept = append(kept, f)
+	}
+	return kept
+}
+
+// lockRefusal explains why a file of an index is not allowed by the lock
+// files, or returns an empty string when it may be served.
+func (p *Proxy) lockRefusal(source config.IndexConfig, packageName, fileName string) string {
+	if p.lock == nil {
+		return ""
+	}
+	return p.lock.Refusal(packageName, fileName, p.fileDigest(source, packageName, fileName))
+}
+
+// HandleLockReport reports the requests refused by the lock files, so build
+// owners can see what their resolver tried to pull.
+func (p *Proxy) HandleLockReport(w http.ResponseWriter, r *http.Request) {
+	if !p.authorizeAdmin(w, r) {
+		return
+	}
+	if p.lock == nil {
+		http.Error(w, "Lock mode is not enabled", http.StatusNotFound)
+		return
+	}
+
+	violations, dropped := p.lockReport.snapshot()
+	report := struct {
+		Locks      []string        `json:"locks"`
+		Violations []lockViolation `json:"violations"`
+		Dropped    int             `json:"dropped"`
+	}{
+		Locks:      p.lock.Sources,
+		Violations: violations,
+		Dropped:    dropped,
+	}
+	body, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error encoding report: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set(pypi.ResponseHeaderSource, "proxy")
+	if _, err := w.Write(body); err != nil {
+		http.Error(w, fmt.Sprintf("Error writing response: %v", err), http.StatusInternalServerError)
+		return
+	}
+}
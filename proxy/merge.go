@@ -0,0 +1,75 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"python-index-proxy/config"
+	"python-index-proxy/pypi"
+	"sort"
+	"strings"
+)
+
+// isMergePackage reports whether a package is served in merge mode.
+func (p *Proxy) isMergePackage(packageName string) bool {
+	_, ok := p.merge.Match(packageName)
+	return ok
+}
+
+// mergeOrder sorts the indexes a package is merged from so that private
+// indexes take precedence over public ones, each in priority order.
+func mergeOrder(candidates []config.IndexConfig) {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return !candidates[i].Public && candidates[j].Public
+	})
+}
+
+// mergePackagePage combines the pages of every eligible index that has the
+// package into a single page. Private files win on filename collisions and
+// wheels listed by public indexes are dropped. The upstream URL of each file
+// is remembered so HandleFile knows where to fetch it.
+func (p *Proxy) mergePackagePage(ctx context.Context, packageName string, exists map[string]bool, format pageFormat) (sourceHeader string, page []byte, found bool, err error) {
+	candidates, err := p.candidateIndexes(packageName, exists)
+	if err != nil || len(candidates) == 0 {
+		return "", nil, false, err
+	}
+
+	mergeOrder(candidates)
+
+	seen := make(map[string]bool)
+	var files []pypi.File
+	sources := make([]string, 0, len(candidates))
+
+	for _, idx := range candidates {
+		indexPage, err := p.getPackagePage(ctx, idx, packageName)
+		if err != nil {
+			return "", nil, false, err
+		}
+		sources = append(sources, idx.URL)
+
+		indexFiles, err := parsePage(indexPage)
+		if err != nil {
+			return "", nil, false, fmt.Errorf("error parsing package page from %s: %w", idx.Name, err)
+		}
+		// Files the policies hide never shadow files of later indexes
+		indexFiles = p.servedFiles(ctx, idx, packageName, indexFiles)
+
+		for _, f := range indexFiles {
+			if seen[f.Filename] {
+				log.Printf("MERGE: /simple/%s/ - %s from %s shadowed by an earlier index", packageName, f.Filename, idx.Name)
+				continue
+			}
+			seen[f.Filename] = true
+			f.Origin = idx.Name
+			p.rewriteFile(idx, packageName, &f)
+			files = append(files, f)
+		}
+	}
+
+	log.Printf("ROUTING: /simple/%s/ → MERGED (%s)", packageName, strings.Join(sources, ", "))
+	page, err = renderFiles(packageName, files, format)
+	if err != nil {
+		return "", nil, false, err
+	}
+	return strings.Join(sources, ", "), page, true, nil
+}
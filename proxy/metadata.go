@@ -0,0 +1,63 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"python-index-proxy/config"
+	"strings"
+)
+
+// metadataSuffix is appended to a distribution file name to address its
+// PEP 658 core metadata file.
+const metadataSuffix = ".metadata"
+
+// isMetadataFile reports whether a file name addresses a core metadata file.
+func isMetadataFile(fileName string) bool {
+	return strings.HasSuffix(fileName, metadataSuffix)
+}
+
+// parentFileName returns the distribution file a core metadata file belongs to.
+func parentFileName(fileName string) string {
+	return strings.TrimSuffix(fileName, metadataSuffix)
+}
+
+// serveMetadata serves the core metadata of a distribution file from the
+// index that serves the file itself, caching it per index.
+func (p *Proxy) serveMetadata(ctx context.Context, w http.ResponseWriter, r *http.Request, source config.IndexConfig, fileURL, fileName string) {
+	metadata, err := p.getMetadata(ctx, source, fileURL, fileName)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error proxying file: %v", err), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", len(metadata)))
+
+	// For HEAD requests, only send headers, not body
+	if r.Method == "HEAD" {
+		return
+	}
+
+	if _, err := w.Write(metadata); err != nil {
+		log.Printf("METADATA: error writing %s: %v", fileName, err)
+	}
+}
+
+// getMetadata returns the core metadata of a file, from cache when available.
+func (p *Proxy) getMetadata(ctx context.Context, source config.IndexConfig, fileURL, fileName string) ([]byte, error) {
+	if cached, ok := p.cache.GetMetadata(source.Name, fileName); ok {
+		log.Printf("METADATA: %s → CACHED (from %s)", fileName, source.URL)
+		return cached.Data, nil
+	}
+
+	log.Printf("METADATA: %s → FETCHING (from %s)", fileName, fileURL)
+	metadata, err := p.client.GetPackageFile(ctx, fileURL)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving metadata: %w", err)
+	}
+
+	p.cache.SetMetadata(source.Name, fileName, metadata)
+	return metadata, nil
+}
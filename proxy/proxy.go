@@ -4,26 +4,57 @@ package proxy
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"python-index-proxy/cache"
 	"python-index-proxy/config"
+	"python-index-proxy/lock"
 	"python-index-proxy/pypi"
-	"regexp"
 	"strings"
-)
+	"time"
 
-const (
-	publicPyPIFileBaseURL = "https://files.pythonhosted.org"
-	packagesPath          = "packages"
+	lru "github.com/hashicorp/golang-lru/v2"
 )
 
+const packagesPath = "packages"
+
 // Proxy represents the PyPI proxy server.
 type Proxy struct {
-	config *config.Config
-	cache  *cache.Cache
-	client pypi.PyPIClient
+	config     *config.Config
+	cache      *cache.Cache
+	client     pypi.PyPIClient
+	indexes    []config.IndexConfig
+	rules      []config.Rule
+	namespaces *config.PackageMatcher
+	merge      *config.PackageMatcher
+	// wheelPolicies decide which public wheels are served, in configured order.
+	wheelPolicies []config.CompiledWheelPolicy
+	// releaseAgeOverrides set per-package minimum release ages, in configured order.
+	releaseAgeOverrides []config.CompiledReleaseAgeOverride
+	// blocklist bans releases and files from every index.
+	blocklist []config.CompiledBlockEntry
+	// yanks marks files yanked locally, from configuration and the admin API.
+	yanks *yankOverlay
+	// vulns is the offline OSV database, or nil when none is configured.
+	vulns *vulnerabilityDB
+	// lock restricts serving to the artifacts pinned by lock files, or is nil.
+	lock *lock.Lock
+	// lockReport collects the requests the lock files refused.
+	lockReport *lockReport
+	// fileOrigins maps the file names served per index to their upstream location.
+	fileOrigins *lru.Cache[string, fileLocation]
+	// fileURLs maps rewritten file links to their upstream location.
+	fileURLs *lru.Cache[string, fileLocation]
+	// fileDigests maps files served on package pages to their SHA-256 digest.
+	fileDigests *lru.Cache[string, string]
+	// pageVersions remembers when each served package page last changed.
+	pageVersions *lru.Cache[string, pageVersion]
+	// root caches the project list served at /simple/.
+	root rootListing
+	// files caches verified downloads on disk, or is nil.
+	files *fileCache
 }
 
 // NewProxy creates a new proxy instance.
@@ -33,104 +64,211 @@ func NewProxy(cfg *config.Config) (*Proxy, error) {
 		return nil, fmt.Errorf("error creating cache: %w", err)
 	}
 
+	rules, err := cfg.CompileRules()
+	if err != nil {
+		return nil, fmt.Errorf("error compiling routing rules: %w", err)
+	}
+
+	namespaces, err := cfg.PrivateNamespaceMatcher()
+	if err != nil {
+		return nil, fmt.Errorf("error compiling private namespaces: %w", err)
+	}
+
+	merge, err := config.NewPackageMatcher(cfg.MergePackages)
+	if err != nil {
+		return nil, fmt.Errorf("error compiling merge packages: %w", err)
+	}
+
+	fileOrigins, err := lru.New[string, fileLocation](fileOriginCacheSize)
+	if err != nil {
+		return nil, fmt.Errorf("error creating file origin cache: %w", err)
+	}
+
+	wheelPolicies, err := cfg.CompileWheelPolicies()
+	if err != nil {
+		return nil, fmt.Errorf("error compiling wheel policies: %w", err)
+	}
+
+	releaseAgeOverrides, err := cfg.CompileReleaseAgeOverrides()
+	if err != nil {
+		return nil, fmt.Errorf("error compiling release age overrides: %w", err)
+	}
+
+	blocklist, err := cfg.CompileBlocklist()
+	if err != nil {
+		return nil, fmt.Errorf("error compiling blocklist: %w", err)
+	}
+
+	yanks, err := cfg.CompileYanks()
+	if err != nil {
+		return nil, fmt.Errorf("error compiling yanks: %w", err)
+	}
+
+	vulns, err := newVulnerabilityDB(cfg.OSV)
+	if err != nil {
+		return nil, fmt.Errorf("error loading OSV database: %w", err)
+	}
+
+	pins, err := loadLock(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("error loading lock files: %w", err)
+	}
+
+	fileURLs, err := lru.New[string, fileLocation](fileURLCacheSize)
+	if err != nil {
+		return nil, fmt.Errorf("error creating file URL cache: %w", err)
+	}
+
+	fileDigests, err := lru.New[string, string](fileDigestCacheSize)
+	if err != nil {
+		return nil, fmt.Errorf("error creating file digest cache: %w", err)
+	}
+
+	pageVersions, err := lru.New[string, pageVersion](pageVersionCacheSize)
+	if err != nil {
+		return nil, fmt.Errorf("error creating page version cache: %w", err)
+	}
+
+	lockReport, err := newLockReport(lockReportSize)
+	if err != nil {
+		return nil, fmt.Errorf("error creating lock report: %w", err)
+	}
+
+	files, err := newFileCache(cfg.FileCacheDir)
+	if err != nil {
+		return nil, fmt.Errorf("error creating file cache: %w", err)
+	}
+
+	indexes := cfg.IndexChain()
+	if cfg.Upload.Enabled() && !isPrivateIndex(indexes, cfg.Upload.IndexName()) {
+		return nil, fmt.Errorf("upload index %q must be a private index of the chain", cfg.Upload.IndexName())
+	}
+	if cfg.Upload.Enabled() && cfg.Upload.URL == "" && uploadDir(indexes, cfg.Upload.IndexName()) == "" {
+		return nil, fmt.Errorf("upload index %q must be a local index when no upload url is set", cfg.Upload.IndexName())
+	}
+
 	return &Proxy{
-		config: cfg,
-		cache:  cache,
-		client: pypi.NewClient(),
+		config:              cfg,
+		cache:               cache,
+		client:              pypi.NewClient(),
+		indexes:             indexes,
+		files:               files,
+		pageVersions:        pageVersions,
+		rules:               rules,
+		namespaces:          namespaces,
+		merge:               merge,
+		fileOrigins:         fileOrigins,
+		fileURLs:            fileURLs,
+		fileDigests:         fileDigests,
+		wheelPolicies:       wheelPolicies,
+		releaseAgeOverrides: releaseAgeOverrides,
+		blocklist:           blocklist,
+		yanks:               &yankOverlay{entries: yanks},
+		vulns:               vulns,
+		lock:                pins,
+		lockReport:          lockReport,
 	}, nil
 }
 
-// filterWheelFiles removes wheel file links from HTML content.
-// This ensures that only source distributions are served from public PyPI.
-func (p *Proxy) filterWheelFiles(htmlContent []byte) []byte {
-	content := string(htmlContent)
+// determineSource determines which index to serve from and gets cached content if available.
+func (p *Proxy) determineSource(ctx context.Context, packageName string, exists map[string]bool) (source config.IndexConfig, packagePage []byte, found bool, err error) {
+	// Log the routing decision
+	log.Printf("ROUTING: /simple/%s/ - exists=%v", packageName, exists)
 
-	// Regular expression to match wheel file links
-	// Matches <a href="...">...whl</a> patterns with any additional attributes
-	wheelPattern := regexp.MustCompile(`<a[^>]*href="[^"]*\.whl[^"]*"[^>]*>.*?\.whl</a>\s*<br\s*/>?\s*`)
+	source, found, err = p.selectIndex(packageName, exists)
+	if err != nil {
+		log.Printf("ROUTING: /simple/%s/ → BLOCKED", packageName)
+		return config.IndexConfig{}, nil, false, err
+	}
+	if !found {
+		// Package doesn't exist in any eligible index
+		return config.IndexConfig{}, nil, false, nil
+	}
+	log.Printf("ROUTING: /simple/%s/ → %s (%s)", packageName, source.Name, source.URL)
 
-	// Remove wheel file links
-	filteredContent := wheelPattern.ReplaceAllString(content, "")
+	packagePage, err = p.getPackagePage(ctx, source, packageName)
+	if err != nil {
+		return config.IndexConfig{}, nil, false, err
+	}
 
-	return []byte(filteredContent)
+	return source, packagePage, true, nil
 }
 
-// determineSource determines which index to serve from and gets cached content if available.
-func (p *Proxy) determineSource(ctx context.Context, packageName string, publicExists, privateExists bool) (sourceIndex, baseURL string, packagePage []byte, exists bool, err error) {
-	var cachedPage cache.PackagePageInfo
-	var found bool
+// getPackagePage returns the package page of an index, from cache when available.
+func (p *Proxy) getPackagePage(ctx context.Context, source config.IndexConfig, packageName string) ([]byte, error) {
+	// Check cache for the package page of the selected index
+	if cachedPage, ok := p.cache.GetPackagePage(source.Name, packageName); ok {
+		log.Printf("ROUTING: /simple/%s/ → CACHED (from %s)", packageName, source.URL)
+		return cachedPage.HTML, nil
+	}
 
-	// Log the routing decision
-	log.Printf("ROUTING: /simple/%s/ - publicExists=%v, privateExists=%v", packageName, publicExists, privateExists)
-
-	// Check if this package should always use the public index
-	if p.config.IsPublicOnlyPackage(packageName) {
-		if publicExists {
-			sourceIndex = p.config.PublicPyPIURL
-			baseURL = p.config.PublicPyPIURL
-			log.Printf("ROUTING: /simple/%s/ → PUBLIC_PYPI (public-only package) (%s)", packageName, p.config.PublicPyPIURL)
-
-			// Check cache for public package page
-			if p.cache.IsEnabled() {
-				cachedPage, found = p.cache.GetPublicPackagePage(packageName)
-			}
-		} else {
-			// Package doesn't exist in public index
-			return "", "", nil, false, nil
-		}
-	} else {
-		switch {
-		case privateExists:
-			// If package exists in private index, serve from there
-			sourceIndex = p.config.PrivatePyPIURL
-			baseURL = p.config.PrivatePyPIURL
-			log.Printf("ROUTING: /simple/%s/ → LOCAL_PYPI (%s)", packageName, p.config.PrivatePyPIURL)
-
-			// Check cache for private package page
-			if p.cache.IsEnabled() {
-				cachedPage, found = p.cache.GetPrivatePackagePage(packageName)
-			}
-		case publicExists:
-			// If package only exists in public index, serve from there
-			sourceIndex = p.config.PublicPyPIURL
-			baseURL = p.config.PublicPyPIURL
-			log.Printf("ROUTING: /simple/%s/ → PUBLIC_PYPI (%s)", packageName, p.config.PublicPyPIURL)
-
-			// Check cache for public package page
-			if p.cache.IsEnabled() {
-				cachedPage, found = p.cache.GetPublicPackagePage(packageName)
-			}
-		default:
-			// Package doesn't exist in either index
-			return "", "", nil, false, nil
-		}
+	// Get package page from the determined source, conditionally when an
+	// expired copy with validators is cached
+	stale, _ := p.cache.GetStalePackagePage(source.Name, packageName)
+	cached := pypi.PageValidators{ETag: stale.ETag, LastModified: stale.LastModified}
+	log.Printf("ROUTING: /simple/%s/ → FETCHING (from %s)", packageName, source.URL)
+	packagePage, validators, err := p.client.RevalidatePackagePage(ctx, source.URL, packageName, cached)
+	if errors.Is(err, pypi.ErrNotModified) {
+		log.Printf("ROUTING: /simple/%s/ → NOT MODIFIED (from %s)", packageName, source.URL)
+		packagePage, validators, err = stale.HTML, cached, nil
+	}
+	if err != nil {
+		log.Printf("ROUTING: /simple/%s/ → ERROR (from %s): %v", packageName, source.URL, err)
+		return nil, fmt.Errorf("error retrieving package page: %w", err)
 	}
 
-	// If found in cache, use cached content
-	if found {
-		log.Printf("ROUTING: /simple/%s/ → CACHED (from %s)", packageName, sourceIndex)
-		packagePage = cachedPage.HTML
-	} else {
-		// Get package page from the determined source
-		log.Printf("ROUTING: /simple/%s/ → FETCHING (from %s)", packageName, sourceIndex)
-		packagePage, err = p.client.GetPackagePage(ctx, baseURL, packageName)
-		if err != nil {
-			log.Printf("ROUTING: /simple/%s/ → ERROR (from %s): %v", packageName, sourceIndex, err)
-			return "", "", nil, false, fmt.Errorf("error retrieving package page: %w", err)
-		}
+	// Cache the package page for future requests
+	p.cache.SetPackagePageInfo(source.Name, packageName, cache.PackagePageInfo{
+		HTML:         packagePage,
+		ETag:         validators.ETag,
+		LastModified: validators.LastModified,
+	})
 
-		// Cache the package page for future requests
-		if p.cache.IsEnabled() {
-			if privateExists {
-				p.cache.SetPrivatePackagePage(packageName, packagePage)
-			} else {
-				p.cache.SetPublicPackagePage(packageName, packagePage)
-			}
-		}
+	return packagePage, nil
+}
+
+// servedFiles applies the serving policies to the files an index lists for a
+// package: it drops the files clients may not see and marks local yanks.
+func (p *Proxy) servedFiles(ctx context.Context, source config.IndexConfig, packageName string, files []pypi.File) []pypi.File {
+	// Filter wheel files and recent uploads only when serving from a public index
+	if source.Public {
+		files = p.allowedPublicFiles(packageName, files)
+		files = p.quarantineFiles(ctx, source, packageName, files)
+	}
+	files = p.unblockedFiles(packageName, files)
+	files = p.screenVulnerableFiles(packageName, files)
+	files = p.hashedFiles(packageName, files)
+	files = p.lockedFiles(packageName, files)
+	p.applyYanks(packageName, files)
+	return p.snapshotFiles(ctx, source, packageName, files)
+}
+
+// buildPackagePage routes a package and returns the page to serve in the
+// requested format along with the value of the source header.
+func (p *Proxy) buildPackagePage(ctx context.Context, packageName string, exists map[string]bool, format pageFormat) (sourceHeader string, content []byte, found bool, err error) {
+	if p.isMergePackage(packageName) {
+		return p.mergePackagePage(ctx, packageName, exists, format)
 	}
 
-	exists = true
-	return sourceIndex, baseURL, packagePage, exists, nil
+	source, packagePage, found, err := p.determineSource(ctx, packageName, exists)
+	if err != nil || !found {
+		return "", nil, found, err
+	}
+
+	// Pages are parsed into file records and re-rendered in the requested
+	// format, with wheels filtered and links pointing at the proxy
+	files, err := parsePage(packagePage)
+	if err != nil {
+		return "", nil, false, fmt.Errorf("error parsing package page: %w", err)
+	}
+	files = p.servedFiles(ctx, source, packageName, files)
+	p.rewriteFiles(source, packageName, files)
+
+	content, err = renderFiles(packageName, files, format)
+	if err != nil {
+		return "", nil, false, err
+	}
+	return source.URL, content, true, nil
 }
 
 // HandlePackage handles requests for package information.
@@ -138,8 +276,9 @@ func (p *Proxy) HandlePackage(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
 	// Extract package name from URL path
-	// Expected format: /simple/{package_name}/
-	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	// Expected format: /simple/{package_name}/, optionally behind an as-of prefix
+	path, prefix, _ := splitAsOfPath(r.URL.Path)
+	pathParts := strings.Split(strings.Trim(path, "/"), "/")
 	if len(pathParts) < 2 || pathParts[0] != "simple" {
 		http.Error(w, "Invalid package path", http.StatusBadRequest)
 		return
@@ -151,38 +290,72 @@ func (p *Proxy) HandlePackage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if package exists in both indexes
-	publicExists, privateExists, err := p.CheckPackageExists(ctx, packageName)
+	// Redirect non-canonical names to their PEP 503 normalized URL, as PyPI does
+	if normalizedName := pypi.NormalizeName(packageName); normalizedName != packageName {
+		target := prefix + "/simple/" + normalizedName + "/"
+		if r.URL.RawQuery != "" {
+			target += "?" + r.URL.RawQuery
+		}
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+		return
+	}
+
+	// Serve an index snapshot when the request or the configuration asks for one
+	asOf, err := p.requestAsOf(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !asOf.IsZero() {
+		ctx = withAsOf(ctx, asOf)
+		w.Header().Set(pypi.HeaderAsOf, asOf.UTC().Format(time.RFC3339))
+	}
+
+	// In lock mode, packages the lock files don't pin are never looked up
+	if p.lockMissRefusal(w, r, packageName) {
+		return
+	}
+
+	// Check which indexes the package exists in
+	exists, err := p.CheckPackageExists(ctx, packageName)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error checking package existence: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Error checking package existence: %v", err), p.existenceErrorStatus(packageName))
 		return
 	}
 
 	// Determine which index to serve from and get content
-	sourceIndex, _, packagePage, exists, err := p.determineSource(ctx, packageName, publicExists, privateExists)
+	format := negotiateFormat(r)
+	sourceHeader, finalContent, found, err := p.buildPackagePage(ctx, packageName, exists, format)
+	if errors.Is(err, errPackageBlocked) {
+		http.Error(w, "Package blocked by routing rule", http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, errPrivateNamespaceMiss) {
+		http.Error(w, "Package not found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error determining source: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	if !exists {
-		// Package doesn't exist in either index
+	if !found {
+		// Package doesn't exist in any index
 		http.Error(w, "Package not found", http.StatusNotFound)
 		return
 	}
 
 	// Add source header
-	w.Header().Set(pypi.ResponseHeaderSource, sourceIndex)
+	w.Header().Set(pypi.ResponseHeaderSource, sourceHeader)
 
-	// Set content type
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	// Set content type; the representation depends on the Accept header
+	w.Header().Set("Content-Type", format.contentType)
+	w.Header().Set("Vary", "Accept")
+	w.Header().Add("Vary", pypi.HeaderAsOf)
 
-	// Filter wheel files only when serving from public PyPI
-	var finalContent []byte
-	if sourceIndex == p.config.PublicPyPIURL {
-		finalContent = p.filterWheelFiles(packagePage)
-	} else {
-		finalContent = packagePage
+	// Answer revalidations of an unchanged page without a body
+	if p.writePageValidators(w, r, packageName+"\x00"+format.contentType+"\x00"+asOf.String(), finalContent) {
+		return
 	}
 
 	// For HEAD requests, only send headers, not body
@@ -201,8 +374,11 @@ func (p *Proxy) HandlePackage(w http.ResponseWriter, r *http.Request) {
 func (p *Proxy) HandleFile(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	filePath, err := p.extractFilePath(r)
-	if err != nil {
+	// Large files take longer to send than the server write timeout allows;
+	// a download ends when the client goes away instead
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	if _, err := p.extractFilePath(r); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
@@ -215,32 +391,160 @@ func (p *Proxy) HandleFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if package exists in both indexes
-	publicExists, privateExists, err := p.CheckPackageExists(ctx, packageName)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Error checking package existence: %v", err), http.StatusInternalServerError)
+	// Files listed on served pages are fetched from the exact upstream URL
+	// the page linked to; core metadata files follow their distribution file.
+	// Links are remembered by escaped path, so %2B in local versions matches
+	if source, fileURL, ok := p.lookupFileLocation(r.URL.EscapedPath(), packageName, fileName); ok {
+		if p.checkFileIndex(w, packageName, source) {
+			p.serveFile(ctx, w, r, source, fileURL, packageName, fileName)
+		}
 		return
 	}
 
-	sourceIndex, fileBaseURL, err := p.determineFileSource(packageName, publicExists, privateExists)
+	// Otherwise the file is looked up on the package page of the index the
+	// rewritten link names, or of the index the package routes to
+	source, ok := p.pathIndex(r.URL.EscapedPath())
+	if ok {
+		if !p.checkFileIndex(w, packageName, source) {
+			return
+		}
+	} else {
+		exists, err := p.CheckPackageExists(ctx, packageName)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error checking package existence: %v", err), p.existenceErrorStatus(packageName))
+			return
+		}
+
+		var found bool
+		source, found, err = p.selectIndex(packageName, exists)
+		if errors.Is(err, errPackageBlocked) {
+			http.Error(w, "Package blocked by routing rule", http.StatusForbidden)
+			return
+		}
+		if err != nil || !found {
+			http.Error(w, "package not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	fileURL, err := p.locateFile(ctx, source, packageName, fileName)
+	if errors.Is(err, errFileNotListed) {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		http.Error(w, fmt.Sprintf("Error locating file: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	p.serveFile(ctx, w, r, source, fileURL, packageName, fileName)
+}
+
+// serveFile proxies a file or its core metadata from the upstream URL of the given index.
+func (p *Proxy) serveFile(ctx context.Context, w http.ResponseWriter, r *http.Request, source config.IndexConfig, fileURL, packageName, fileName string) {
+	// Blocklisted files are refused from every index
+	if reason := p.blockReason(packageName, parentFileName(fileName)); reason != "" {
+		refuseDownload(w, r, "BLOCKLIST", source, packageName, fileName, reason)
+		return
+	}
+
+	// In lock mode, only the pinned artifacts are served
+	if reason := p.lockRefusal(source, packageName, parentFileName(fileName)); reason != "" {
+		p.lockReport.record(packageName, fileName, reason, clientAddr(r))
+		refuseDownload(w, r, "LOCK", source, packageName, fileName, reason)
+		return
+	}
+
+	// Files hidden for known vulnerabilities are refused from every index
+	if reason := p.vulnerabilityRefusal(packageName, parentFileName(fileName)); reason != "" {
+		refuseDownload(w, r, "OSV", source, packageName, fileName, reason)
 		return
 	}
 
+	// Files that can't be verified are refused when hashes are required
+	if reason := p.hashRefusal(source, packageName, fileName); reason != "" {
+		refuseDownload(w, r, "HASHES", source, packageName, fileName, reason)
+		return
+	}
+
+	// Files filtered out of public pages are refused, and their metadata is not served
+	if source.Public {
+		if reason := p.publicFileRefusal(packageName, parentFileName(fileName)); reason != "" {
+			refuseDownload(w, r, "SECURITY", source, packageName, fileName, reason)
+			return
+		}
+		if reason := p.quarantineRefusal(ctx, source, packageName, parentFileName(fileName)); reason != "" {
+			refuseDownload(w, r, "QUARANTINE", source, packageName, fileName, reason)
+			return
+		}
+	}
+
 	// Add source header
-	w.Header().Set(pypi.ResponseHeaderSource, sourceIndex)
+	w.Header().Set(pypi.ResponseHeaderSource, source.URL)
+
+	if isMetadataFile(fileName) {
+		p.serveMetadata(ctx, w, r, source, fileURL, fileName)
+		return
+	}
 
-	// Construct the full file URL
-	fileURL := p.constructFileURL(fileBaseURL, r.URL.Path, filePath)
+	// Verified downloads are served from the file cache, ranges included
+	digest := p.fileDigest(source, packageName, fileName)
+	if p.files.serve(w, r, fileName, digest) {
+		return
+	}
 
-	// Proxy the file
-	if err := p.client.ProxyFile(ctx, fileURL, w, r.Method); err != nil {
+	// Downloads the cache can hold are served from its fill, ranges included
+	if p.files.fillable(r, digest) {
+		started, err := p.serveFill(ctx, w, r, fileURL, fileName, digest)
+		if errors.Is(err, pypi.ErrDigestMismatch) {
+			logTamper(r, source, fileURL, packageName, fileName, err)
+		}
+		switch {
+		case err == nil:
+		case started:
+			// The body is already partly sent, so the connection is dropped
+			panic(http.ErrAbortHandler)
+		case errors.Is(err, pypi.ErrDigestMismatch):
+			http.Error(w, "File failed integrity verification", http.StatusBadGateway)
+		default:
+			http.Error(w, fmt.Sprintf("Error proxying file: %v", err), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// Proxy the file, verifying it against the digest of the page it was
+	// listed on; ranges are forwarded upstream
+	err := p.client.ProxyFile(ctx, fileURL, w, r, digest)
+	if errors.Is(err, pypi.ErrDigestMismatch) {
+		logTamper(r, source, fileURL, packageName, fileName, err)
+		// The body is already partly sent, so the connection is dropped
+		panic(http.ErrAbortHandler)
+	}
+	if err != nil {
 		http.Error(w, fmt.Sprintf("Error proxying file: %v", err), http.StatusInternalServerError)
 		return
 	}
 }
 
+// logTamper logs a download that didn't match the digest it was expected to have.
+func logTamper(r *http.Request, source config.IndexConfig, fileURL, packageName, fileName string, err error) {
+	log.Printf("TAMPER: %s (package %s, index %s) for client %s from %s: %v",
+		fileName, packageName, source.Name, clientAddr(r), fileURL, err)
+}
+
+// refuseDownload refuses a file hidden by a serving policy with 403 and logs
+// the refusal under the policy's log prefix. Core metadata of a hidden file is
+// reported as not found instead.
+func refuseDownload(w http.ResponseWriter, r *http.Request, policy string, source config.IndexConfig, packageName, fileName, reason string) {
+	if isMetadataFile(fileName) {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	log.Printf("%s: refused download of %s (package %s, index %s) for client %s: %s",
+		policy, fileName, packageName, source.Name, clientAddr(r), reason)
+	http.Error(w, "Download refused: "+reason, http.StatusForbidden)
+}
+
 // HandleIndex handles requests for the index page.
 func (p *Proxy) HandleIndex(w http.ResponseWriter, _ *http.Request) {
 	// Return a simple index page
@@ -274,7 +578,7 @@ func (p *Proxy) HandleHealth(w http.ResponseWriter, _ *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set(pypi.ResponseHeaderSource, "proxy")
 
-	publicLen, privateLen, publicPageLen, privatePageLen := p.cache.GetStats()
+	publicLen, privateLen, publicPageLen, privatePageLen := p.cacheStats()
 
 	response := fmt.Sprintf(`{
         "status": "healthy",
@@ -294,65 +598,92 @@ func (p *Proxy) HandleHealth(w http.ResponseWriter, _ *http.Request) {
 	}
 }
 
-// CheckPackageExists checks if a package exists in both indexes using cache when possible.
-func (p *Proxy) CheckPackageExists(ctx context.Context, packageName string) (publicExists, privateExists bool, err error) {
-	var publicErr, privateErr error
-
-	var publicFound, privateFound bool
-
-	// Check cache first
-	if p.cache.IsEnabled() {
-		if info, found := p.cache.GetPublicPackage(packageName); found {
-			publicExists = info.Exists
-			publicFound = true
-		}
-		if info, found := p.cache.GetPrivatePackage(packageName); found {
-			privateExists = info.Exists
-			privateFound = true
+// cacheStats sums the cache statistics of the public and private indexes in the chain.
+func (p *Proxy) cacheStats() (publicCount, privateCount, publicPageCount, privatePageCount int) {
+	stats := p.cache.IndexStats()
+	for _, idx := range p.indexes {
+		s := stats[idx.Name]
+		if idx.Public {
+			publicCount += s.Packages
+			publicPageCount += s.Pages
+		} else {
+			privateCount += s.Packages
+			privatePageCount += s.Pages
 		}
 	}
+	return publicCount, privateCount, publicPageCount, privatePageCount
+}
 
-	// If not in cache or cache disabled, check indexes
-	if !p.cache.IsEnabled() || !publicFound {
-		publicExists, publicErr = p.client.PackageExists(ctx, p.config.PublicPyPIURL, packageName)
-		if publicErr == nil && p.cache.IsEnabled() {
-			p.cache.SetPublicPackage(packageName, publicExists)
-		}
+// CheckPackageExists checks which indexes in the chain contain a package, using
+// cache when possible. The result is keyed by index name. Indexes are checked
+// in priority order up to the first that has the package, or all of them for
+// merged packages. An error is only returned when an index ahead of that one
+// can't be checked, so an outage of a lower-priority index doesn't break
+// packages a higher-priority index serves.
+func (p *Proxy) CheckPackageExists(ctx context.Context, packageName string) (map[string]bool, error) {
+	exists := make(map[string]bool, len(p.indexes))
+
+	// Only query indexes the package can be routed to, so names in a private
+	// namespace are never sent to a public index
+	target, _ := p.routeTarget(packageName)
+	if target == config.TargetBlock {
+		return exists, nil
 	}
+	merged := p.isMergePackage(packageName)
 
-	if !p.cache.IsEnabled() || !privateFound {
-		privateExists, privateErr = p.client.PackageExists(ctx, p.config.PrivatePyPIURL, packageName)
-		if privateErr == nil && p.cache.IsEnabled() {
-			p.cache.SetPrivatePackage(packageName, privateExists)
+	found := false
+	for _, idx := range p.indexes {
+		if !eligible(idx, target) {
+			continue
 		}
-	}
 
-	// Return errors if any occurred
-	if publicErr != nil {
-		return false, false, fmt.Errorf("error checking public index: %w", publicErr)
-	}
-	if privateErr != nil {
-		return false, false, fmt.Errorf("error checking private index: %w", privateErr)
+		indexExists, err := p.indexHasPackage(ctx, idx, packageName)
+		if err != nil {
+			if !found {
+				return nil, fmt.Errorf("error checking %s index: %w", idx.Name, err)
+			}
+			// Only merged pages look past the first hit; they go without this index
+			log.Printf("ROUTING: /simple/%s/ - skipping %s index: %v", packageName, idx.Name, err)
+			continue
+		}
+		exists[idx.Name] = indexExists
+		if indexExists {
+			if !merged {
+				break
+			}
+			found = true
+		}
 	}
 
-	return publicExists, privateExists, nil
+	return exists, nil
 }
 
-// extractPackageNameFromFileName extracts package name from a file name.
-// Example: "pydantic-2.5.0-py3-none-any.whl" -> "pydantic".
-func (p *Proxy) extractPackageNameFromFileName(fileName string) string {
-	// Remove file extension
-	fileName = strings.TrimSuffix(fileName, ".whl")
-	fileName = strings.TrimSuffix(fileName, ".tar.gz")
-	fileName = strings.TrimSuffix(fileName, ".zip")
+// indexHasPackage checks whether an index contains a package, using cache when
+// possible.
+func (p *Proxy) indexHasPackage(ctx context.Context, idx config.IndexConfig, packageName string) (bool, error) {
+	// Check cache first
+	if info, found := p.cache.GetPackage(idx.Name, packageName); found {
+		return info.Exists, nil
+	}
 
-	// Split by dash and take the first part
-	parts := strings.Split(fileName, "-")
-	if len(parts) > 0 {
-		return parts[0]
+	// If not in cache or cache disabled, check the index
+	exists, err := p.client.PackageExists(ctx, idx.URL, packageName)
+	if err != nil {
+		return false, err
 	}
+	p.cache.SetPackage(idx.Name, packageName, exists)
+	return exists, nil
+}
 
-	return ""
+// extractPackageNameFromFileName extracts the normalized project name from a distribution
+// file name or the name of its core metadata file.
+// Example: "Python_Dateutil-2.9.0.tar.gz" -> "python-dateutil".
+func (p *Proxy) extractPackageNameFromFileName(fileName string) string {
+	dist, err := pypi.ParseDistributionFilename(parentFileName(fileName))
+	if err != nil {
+		return ""
+	}
+	return dist.ProjectName()
 }
 
 // extractFilePath extracts and validates the file path from the request.
@@ -389,41 +720,6 @@ func (p *Proxy) extractFileNameFromPath(path string) string {
 	return pathParts[len(pathParts)-1]
 }
 
-// determineFileSource determines which source to serve the file from.
-func (p *Proxy) determineFileSource(packageName string, publicExists, privateExists bool) (sourceIndex, fileBaseURL string, err error) {
-	// Check if this package should always use the public index
-	if p.config.IsPublicOnlyPackage(packageName) {
-		if publicExists {
-			return p.config.PublicPyPIURL, publicPyPIFileBaseURL, nil
-		}
-		return "", "", fmt.Errorf("package not found")
-	}
-
-	switch {
-	case privateExists:
-		// If package exists in private index, serve from there
-		return p.config.PrivatePyPIURL, strings.TrimSuffix(strings.TrimSuffix(p.config.PrivatePyPIURL, "/simple/"), "/simple"), nil
-	case publicExists:
-		// If package only exists in public index, serve from there
-		return p.config.PublicPyPIURL, publicPyPIFileBaseURL, nil
-	default:
-		// Package doesn't exist in either index
-		return "", "", fmt.Errorf("package not found")
-	}
-}
-
-// constructFileURL constructs the full file URL based on the request path.
-func (p *Proxy) constructFileURL(fileBaseURL, requestPath, filePath string) string {
-	if strings.HasPrefix(requestPath, "/"+packagesPath+"/") {
-		// For /packages/ paths, use the original logic
-		fileURL := fileBaseURL + requestPath
-		// Fix double slashes
-		return strings.Replace(fileURL, "//"+packagesPath+"/", "/"+packagesPath+"/", 1)
-	}
-	// For direct file requests, construct the URL properly
-	return fileBaseURL + "/" + filePath
-}
-
 // GetCache returns the cache instance for testing purposes.
 func (p *Proxy) GetCache() *cache.Cache {
 	return p.cache
@@ -1,13 +1,23 @@
 package proxy
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"python-index-proxy/config"
 	"python-index-proxy/pypi"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -18,15 +28,70 @@ type MockPyPIClient struct {
 	privateCalls  map[string]int
 	publicExists  map[string]bool
 	privateExists map[string]bool
-	shouldError   bool
+	// indexExists overrides existence per index base URL for chains with more than two indexes.
+	indexExists map[string]map[string]bool
+	// pages overrides the package page returned per index base URL and package.
+	pages       map[string]map[string]string
+	shouldError bool
+	// privateShouldError fails existence checks against private indexes only.
+	privateShouldError bool
+	// fileCalls counts GetPackageFile calls per file URL.
+	fileCalls map[string]int
+	// projects holds the root listing per index base URL.
+	projects map[string][]string
+	// listCalls counts ListProjects calls per index base URL.
+	listCalls map[string]int
+	// proxiedURLs records the upstream URLs passed to ProxyFile.
+	proxiedURLs []string
+	// fetchedURLs records the upstream URLs passed to FetchFile.
+	fetchedURLs []string
+	// proxiedDigests records the expected digests passed to ProxyFile.
+	proxiedDigests []string
+	// tampered marks upstream URLs whose content doesn't match the expected digest.
+	tampered map[string]bool
+	// fetchGate, when set, pauses FetchFile after the first bytes of the file:
+	// it sends on the channel when it pauses and resumes on a receive.
+	fetchGate chan struct{}
+	// uploadTimes holds the JSON API upload times per package.
+	uploadTimes map[string]map[string]time.Time
+	// uploadTimeCalls counts GetUploadTimes calls per package.
+	uploadTimeCalls map[string]int
+	// projectJSON holds the JSON API responses per API URL and project or
+	// "project/version" key.
+	projectJSON map[string]map[string]string
+	// projectJSONCalls counts GetProjectJSON calls per API URL and key.
+	projectJSONCalls map[string]int
+	// uploads records the file names forwarded to Upload.
+	uploads []string
+	// uploadErr is returned by Upload when set.
+	uploadErr error
+	// pageETags sets the ETag of package pages per index base URL and package.
+	pageETags map[string]map[string]string
+	// revalidations counts package pages found unchanged upstream.
+	revalidations int
+	// listGate, when set, holds ListProjects until it is closed.
+	listGate chan struct{}
+	// failingIndexes fails existence checks per index base URL.
+	failingIndexes map[string]bool
 }
 
 func NewMockPyPIClient() *MockPyPIClient {
 	return &MockPyPIClient{
-		publicCalls:   make(map[string]int),
-		privateCalls:  make(map[string]int),
-		publicExists:  make(map[string]bool),
-		privateExists: make(map[string]bool),
+		publicCalls:      make(map[string]int),
+		privateCalls:     make(map[string]int),
+		publicExists:     make(map[string]bool),
+		privateExists:    make(map[string]bool),
+		indexExists:      make(map[string]map[string]bool),
+		pages:            make(map[string]map[string]string),
+		fileCalls:        make(map[string]int),
+		projects:         make(map[string][]string),
+		listCalls:        make(map[string]int),
+		uploadTimes:      make(map[string]map[string]time.Time),
+		uploadTimeCalls:  make(map[string]int),
+		projectJSON:      make(map[string]map[string]string),
+		projectJSONCalls: make(map[string]int),
+		pageETags:        make(map[string]map[string]string),
+		failingIndexes:   make(map[string]bool),
 	}
 }
 
@@ -37,6 +102,13 @@ func (m *MockPyPIClient) PackageExists(_ context.Context, baseURL, packageName s
 	if m.shouldError {
 		return false, fmt.Errorf("mock error")
 	}
+	if m.failingIndexes[baseURL] {
+		return false, fmt.Errorf("mock error from %s", baseURL)
+	}
+
+	if packages, ok := m.indexExists[baseURL]; ok {
+		return packages[packageName], nil
+	}
 
 	// Track the call
 	if strings.Contains(baseURL, "pypi.org") {
@@ -48,6 +120,9 @@ func (m *MockPyPIClient) PackageExists(_ context.Context, baseURL, packageName s
 		return exists, nil
 	}
 	m.privateCalls[packageName]++
+	if m.privateShouldError {
+		return false, fmt.Errorf("mock private error")
+	}
 	exists, found := m.privateExists[packageName]
 	if !found {
 		return false, nil
@@ -62,7 +137,9 @@ func (m *MockPyPIClient) GetPackagePage(_ context.Context, baseURL, packageName
 
 	// Check if package exists in the appropriate index
 	var exists bool
-	if strings.Contains(baseURL, "pypi.org") {
+	if packages, ok := m.indexExists[baseURL]; ok {
+		exists = packages[packageName]
+	} else if strings.Contains(baseURL, "pypi.org") {
 		exists = m.publicExists[packageName]
 	} else {
 		exists = m.privateExists[packageName]
@@ -72,26 +149,126 @@ func (m *MockPyPIClient) GetPackagePage(_ context.Context, baseURL, packageName
 		return nil, fmt.Errorf("package not found")
 	}
 
+	if page, ok := m.pages[baseURL][packageName]; ok {
+		return []byte(page), nil
+	}
+
 	return []byte(fmt.Sprintf("<html><body>Package %s</body></html>", packageName)), nil
 }
 
-func (m *MockPyPIClient) GetPackageFile(_ context.Context, _ string) ([]byte, error) {
+func (m *MockPyPIClient) RevalidatePackagePage(ctx context.Context, baseURL, packageName string, cached pypi.PageValidators) ([]byte, pypi.PageValidators, error) {
+	etag := m.pageETags[baseURL][packageName]
+	if etag != "" && cached.ETag == etag {
+		m.revalidations++
+		return nil, cached, pypi.ErrNotModified
+	}
+	page, err := m.GetPackagePage(ctx, baseURL, packageName)
+	return page, pypi.PageValidators{ETag: etag}, err
+}
+
+func (m *MockPyPIClient) GetPackageFile(_ context.Context, fileURL string) ([]byte, error) {
 	if m.shouldError {
 		return nil, fmt.Errorf("mock error")
 	}
+	m.fileCalls[fileURL]++
 	return []byte("mock file content"), nil
 }
 
-func (m *MockPyPIClient) ProxyFile(_ context.Context, _ string, w http.ResponseWriter, _ string) error {
+func (m *MockPyPIClient) ProxyFile(_ context.Context, fileURL string, w http.ResponseWriter, _ *http.Request, expectedSHA256 string) error {
+	m.proxiedURLs = append(m.proxiedURLs, fileURL)
+	m.proxiedDigests = append(m.proxiedDigests, expectedSHA256)
 	if m.shouldError {
 		return fmt.Errorf("mock error")
 	}
+	if expectedSHA256 != "" && m.tampered[fileURL] {
+		return fmt.Errorf("%w: mock file content", pypi.ErrDigestMismatch)
+	}
 	if _, err := w.Write([]byte("mock file content")); err != nil {
 		return fmt.Errorf("mock write error: %w", err)
 	}
 	return nil
 }
 
+func (m *MockPyPIClient) FetchFile(_ context.Context, fileURL string, dst io.Writer, expectedSHA256 string) error {
+	m.fetchedURLs = append(m.fetchedURLs, fileURL)
+	if m.shouldError {
+		return fmt.Errorf("mock error")
+	}
+	if expectedSHA256 != "" && m.tampered[fileURL] {
+		return fmt.Errorf("%w: mock file content", pypi.ErrDigestMismatch)
+	}
+	content := []byte("mock file content")
+	if sw, ok := dst.(pypi.SizeWriter); ok {
+		sw.SetSize(int64(len(content)))
+	}
+	if m.fetchGate != nil {
+		if _, err := dst.Write(content[:4]); err != nil {
+			return fmt.Errorf("mock write error: %w", err)
+		}
+		content = content[4:]
+		m.fetchGate <- struct{}{}
+		<-m.fetchGate
+	}
+	if _, err := dst.Write(content); err != nil {
+		return fmt.Errorf("mock write error: %w", err)
+	}
+	return nil
+}
+
+func (m *MockPyPIClient) GetUploadTimes(_ context.Context, _, packageName string) (map[string]time.Time, error) {
+	m.uploadTimeCalls[packageName]++
+	if m.shouldError {
+		return nil, fmt.Errorf("mock error")
+	}
+	times, ok := m.uploadTimes[packageName]
+	if !ok {
+		return nil, fmt.Errorf("package not found: %s", packageName)
+	}
+	return times, nil
+}
+
+func (m *MockPyPIClient) GetProjectJSON(_ context.Context, jsonAPIURL, packageName, version string) ([]byte, error) {
+	key := packageName
+	if version != "" {
+		key += "/" + version
+	}
+	m.projectJSONCalls[jsonAPIURL+key]++
+	if m.shouldError {
+		return nil, fmt.Errorf("mock error")
+	}
+	body, ok := m.projectJSON[jsonAPIURL][key]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", pypi.ErrNotFound, key)
+	}
+	return []byte(body), nil
+}
+
+func (m *MockPyPIClient) Upload(_ context.Context, _, _, _ string, form *multipart.Form) error {
+	if m.uploadErr != nil {
+		return m.uploadErr
+	}
+	for _, fh := range form.File["content"] {
+		m.uploads = append(m.uploads, fh.Filename)
+	}
+	return nil
+}
+
+func (m *MockPyPIClient) ListProjects(_ context.Context, baseURL string, fn func(name string) error) error {
+	if m.listGate != nil {
+		<-m.listGate
+	}
+	m.listCalls[baseURL]++
+	if m.shouldError {
+		return fmt.Errorf("mock error")
+	}
+	for _, name := range m.projects[baseURL] {
+		if err := fn(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // TestProxyCachingWithCacheEnabled tests that caching reduces network calls.
 func TestProxyCachingWithCacheEnabled(t *testing.T) {
 	// Create test configuration with cache enabled
@@ -119,15 +296,15 @@ func TestProxyCachingWithCacheEnabled(t *testing.T) {
 	mockClient.privateExists["test"] = false
 
 	// First request - should make network calls
-	publicExists, privateExists, err := proxyInstance.CheckPackageExists(context.Background(), "test")
+	exists, err := proxyInstance.CheckPackageExists(context.Background(), "test")
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
 
-	if !publicExists {
+	if !exists[config.PublicIndexName] {
 		t.Error("Expected public package to exist")
 	}
-	if privateExists {
+	if exists[config.PrivateIndexName] {
 		t.Error("Expected private package to not exist")
 	}
 
@@ -140,15 +317,15 @@ func TestProxyCachingWithCacheEnabled(t *testing.T) {
 	}
 
 	// Second request for the same package - should use cache, no network calls
-	publicExists2, privateExists2, err := proxyInstance.CheckPackageExists(context.Background(), "test")
+	exists2, err := proxyInstance.CheckPackageExists(context.Background(), "test")
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
 
-	if !publicExists2 {
+	if !exists2[config.PublicIndexName] {
 		t.Error("Expected public package to exist")
 	}
-	if privateExists2 {
+	if exists2[config.PrivateIndexName] {
 		t.Error("Expected private package to not exist")
 	}
 
@@ -203,13 +380,13 @@ func TestProxyCachingWithCacheDisabled(t *testing.T) {
 	mockClient.privateExists["test"] = false
 
 	// First request
-	_, _, err = proxyInstance.CheckPackageExists(context.Background(), "test")
+	_, err = proxyInstance.CheckPackageExists(context.Background(), "test")
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
 
 	// Second request for the same package - should make network calls again
-	_, _, err = proxyInstance.CheckPackageExists(context.Background(), "test")
+	_, err = proxyInstance.CheckPackageExists(context.Background(), "test")
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -265,7 +442,7 @@ func TestProxyCachingPartialCache(t *testing.T) {
 	mockClient.privateExists["test"] = false
 
 	// First request - should make network calls
-	_, _, err = proxyInstance.CheckPackageExists(context.Background(), "test")
+	_, err = proxyInstance.CheckPackageExists(context.Background(), "test")
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -282,7 +459,7 @@ func TestProxyCachingPartialCache(t *testing.T) {
 	proxyInstance.GetCache().ClearPrivateOnly()
 
 	// Second request - should use public cache, make private network call
-	_, _, err = proxyInstance.CheckPackageExists(context.Background(), "test")
+	_, err = proxyInstance.CheckPackageExists(context.Background(), "test")
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -323,7 +500,7 @@ func TestProxyCachingExpiration(t *testing.T) {
 	mockClient.privateExists["test"] = false
 
 	// First request - should make network calls
-	_, _, err = proxyInstance.CheckPackageExists(context.Background(), "test")
+	_, err = proxyInstance.CheckPackageExists(context.Background(), "test")
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -340,7 +517,7 @@ func TestProxyCachingExpiration(t *testing.T) {
 	time.Sleep(10 * time.Millisecond)
 
 	// Second request - should make network calls again due to expiration
-	_, _, err = proxyInstance.CheckPackageExists(context.Background(), "test")
+	_, err = proxyInstance.CheckPackageExists(context.Background(), "test")
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -451,6 +628,9 @@ func TestProxyHTTPHandlers(t *testing.T) {
 	// Set up mock responses
 	mockClient.publicExists["test"] = true
 	mockClient.privateExists["test"] = false
+	mockClient.pages[cfg.PublicPyPIURL] = map[string]string{
+		"test": `<a href="https://files.pythonhosted.org/packages/source/p/test/test-1.0.0.tar.gz">test-1.0.0.tar.gz</a>`,
+	}
 
 	// Test index page
 	req, err := http.NewRequest("GET", "/", http.NoBody)
@@ -822,14 +1002,14 @@ func TestProxyDetermineSourceError(t *testing.T) {
 	proxyInstance.client = mockClient
 
 	// Test determineSource with package that doesn't exist
-	sourceIndex, baseURL, packagePage, exists, err := proxyInstance.determineSource(context.Background(), "non-existent-package", false, false)
+	source, packagePage, found, err := proxyInstance.determineSource(context.Background(), "non-existent-package", map[string]bool{})
 	if err != nil {
 		t.Errorf("Expected no error, got %v", err)
 	}
-	if exists {
+	if found {
 		t.Error("Expected package to not exist")
 	}
-	if sourceIndex != "" || baseURL != "" || packagePage != nil {
+	if source.URL != "" || packagePage != nil {
 		t.Error("Expected empty values for non-existent package")
 	}
 
@@ -838,7 +1018,7 @@ func TestProxyDetermineSourceError(t *testing.T) {
 	mockClient.publicExists["test-package"] = true
 	mockClient.privateExists["test-package"] = false
 
-	_, _, _, _, _ = proxyInstance.determineSource(context.Background(), "test-package", true, false)
+	_, _, _, _ = proxyInstance.determineSource(context.Background(), "test-package", map[string]bool{config.PublicIndexName: true})
 }
 
 // TestExtractPackageNameFromFileName tests the extractPackageNameFromFileName function.
@@ -867,8 +1047,14 @@ func TestExtractPackageNameFromFileName(t *testing.T) {
 		{"pydantic-2.5.0-py3-none-any.whl", "pydantic"},
 		{"requests-2.31.0.tar.gz", "requests"},
 		{"flask-3.0.0.zip", "flask"},
-		{"simple-package-1.0.0-py3-none-any.whl", "simple"},
-		{"complex_package_name-1.0.0.tar.gz", "complex_package_name"},
+		{"simple-package-1.0.0-py3-none-any.whl", "simple-package"},
+		{"complex_package_name-1.0.0.tar.gz", "complex-package-name"},
+		{"python-dateutil-2.9.0.tar.gz", "python-dateutil"},
+		{"python_dateutil-2.9.0.post0-py2.py3-none-any.whl", "python-dateutil"},
+		{"zope.interface-6.1-cp312-cp312-manylinux_2_17_x86_64.whl", "zope-interface"},
+		{"zope.interface-6.1-cp312-cp312-manylinux_2_17_x86_64.whl.metadata", "zope-interface"},
+		{"not-a-distribution.txt", ""},
+		{".tar.gz", ""},
 	}
 
 	for _, tc := range testCases {
@@ -992,6 +1178,2638 @@ func TestProxyPublicOnlyPackages(t *testing.T) {
 	}
 }
 
+// TestProxyIndexChain tests that packages are served from the highest-priority index that contains them.
+func TestProxyIndexChain(t *testing.T) {
+	cfg := &config.Config{
+		Indexes: []config.IndexConfig{
+			{Name: "pypi", URL: "https://pypi.org/simple/", Priority: 100, Public: true},
+			{Name: "cuda", URL: "https://cuda.example.com/simple/", Priority: 30},
+			{Name: "team", URL: "https://team.example.com/simple/", Priority: 10},
+			{Name: "org", URL: "https://org.example.com/simple/", Priority: 20},
+		},
+		Port:         8080,
+		CacheEnabled: true,
+		CacheSize:    100,
+		CacheTTL:     1,
+	}
+
+	proxyInstance, err := NewProxy(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	mockClient := NewMockPyPIClient()
+	proxyInstance.client = mockClient
+	mockClient.indexExists["https://team.example.com/simple/"] = map[string]bool{"teamlib": true}
+	mockClient.indexExists["https://org.example.com/simple/"] = map[string]bool{"teamlib": true, "torch": true}
+	mockClient.indexExists["https://cuda.example.com/simple/"] = map[string]bool{"torch": true, "nvidia-cublas": true}
+	mockClient.publicExists["teamlib"] = true
+	mockClient.publicExists["torch"] = true
+	mockClient.publicExists["nvidia-cublas"] = true
+	mockClient.publicExists["requests"] = true
+
+	testCases := []struct {
+		packageName string
+		source      string
+	}{
+		{"teamlib", "https://team.example.com/simple/"},
+		{"torch", "https://org.example.com/simple/"},
+		{"nvidia-cublas", "https://cuda.example.com/simple/"},
+		{"requests", "https://pypi.org/simple/"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.packageName, func(t *testing.T) {
+			req, err := http.NewRequest("GET", "/simple/"+tc.packageName+"/", http.NoBody)
+			if err != nil {
+				t.Fatalf("Failed to create request: %v", err)
+			}
+			rr := httptest.NewRecorder()
+			proxyInstance.HandlePackage(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Errorf("Expected status 200, got %d", rr.Code)
+			}
+			if source := rr.Header().Get("X-PyPI-Source"); source != tc.source {
+				t.Errorf("Expected source header %s, got %s", tc.source, source)
+			}
+		})
+	}
+
+	// Existence and pages are cached per index name, and indexes behind the
+	// first that has a package aren't asked for it
+	stats := proxyInstance.GetCache().IndexStats()
+	if stats["team"].Packages != 4 || stats["org"].Packages != 3 || stats["cuda"].Packages != 2 || stats["pypi"].Packages != 1 {
+		t.Errorf("Expected existence checks to stop at the first hit, got %+v", stats)
+	}
+	if stats["org"].Pages != 1 || stats["pypi"].Pages != 1 {
+		t.Errorf("Expected 1 cached page for org and pypi, got %+v", stats)
+	}
+}
+
+// TestProxyIndexChainOutage tests that an unavailable index only fails packages
+// it could have served.
+func TestProxyIndexChainOutage(t *testing.T) {
+	cfg := &config.Config{
+		Indexes: []config.IndexConfig{
+			{Name: "team", URL: "https://team.example.com/simple/", Priority: 10},
+			{Name: "cuda", URL: "https://cuda.example.com/simple/", Priority: 30},
+			{Name: "pypi", URL: "https://pypi.org/simple/", Priority: 100, Public: true},
+		},
+		Port:         8080,
+		CacheEnabled: true,
+		CacheSize:    100,
+		CacheTTL:     1,
+	}
+
+	proxyInstance, err := NewProxy(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	mockClient := NewMockPyPIClient()
+	proxyInstance.client = mockClient
+	mockClient.indexExists["https://team.example.com/simple/"] = map[string]bool{"teamlib": true}
+	mockClient.failingIndexes["https://cuda.example.com/simple/"] = true
+	mockClient.publicExists["requests"] = true
+
+	testCases := []struct {
+		packageName string
+		status      int
+	}{
+		// Served by an index ahead of the failing one
+		{"teamlib", http.StatusOK},
+		// The failing index might have served it
+		{"requests", http.StatusInternalServerError},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.packageName, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/simple/"+tc.packageName+"/", http.NoBody)
+			rr := httptest.NewRecorder()
+			proxyInstance.HandlePackage(rr, req)
+
+			if rr.Code != tc.status {
+				t.Errorf("Expected status %d, got %d: %s", tc.status, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
+// TestProxyRoutingRules tests that routing rules are evaluated in order for pages and files.
+func TestProxyRoutingRules(t *testing.T) {
+	cfg := &config.Config{
+		PublicPyPIURL:  "https://pypi.org/simple/",
+		PrivatePyPIURL: "https://console.redhat.com/api/pulp-content/public-calunga/mypypi/simple",
+		Port:           8080,
+		CacheEnabled:   false,
+		CacheSize:      100,
+		CacheTTL:       1,
+		Rules: []config.RoutingRule{
+			{Name: "blocked", Patterns: []string{"azure-evil", "evilpkg"}, Target: config.TargetBlock},
+			{Name: "azure", Patterns: []string{"azure-*"}, Target: config.TargetPrivate},
+			{Name: "stubs", Patterns: []string{"regex:^types-.+$"}, Target: config.TargetPublic},
+		},
+	}
+
+	proxyInstance, err := NewProxy(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	mockClient := NewMockPyPIClient()
+	proxyInstance.client = mockClient
+	for _, name := range []string{"types-requests", "azure-core", "azure-storage", "azure-evil"} {
+		mockClient.publicExists[name] = true
+	}
+	mockClient.privateExists["types-requests"] = true
+	mockClient.privateExists["azure-core"] = true
+	mockClient.privateExists["azure-evil"] = true
+	mockClient.privateExists["evilpkg"] = true
+
+	testCases := []struct {
+		path   string
+		status int
+		source string
+	}{
+		{"/simple/types-requests/", http.StatusOK, "https://pypi.org/simple/"},
+		{"/simple/azure-core/", http.StatusOK, cfg.PrivatePyPIURL},
+		{"/simple/azure-storage/", http.StatusNotFound, ""},
+		{"/simple/azure-evil/", http.StatusForbidden, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			req, err := http.NewRequest("GET", tc.path, http.NoBody)
+			if err != nil {
+				t.Fatalf("Failed to create request: %v", err)
+			}
+			rr := httptest.NewRecorder()
+			proxyInstance.HandlePackage(rr, req)
+
+			if rr.Code != tc.status {
+				t.Errorf("Expected status %d, got %d", tc.status, rr.Code)
+			}
+			if source := rr.Header().Get("X-PyPI-Source"); source != tc.source {
+				t.Errorf("Expected source header %q, got %q", tc.source, source)
+			}
+		})
+	}
+
+	// File routing follows the same rules
+	req, err := http.NewRequest("GET", "/packages/source/e/evilpkg/evilpkg-1.0.0.tar.gz", http.NoBody)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	rr := httptest.NewRecorder()
+	proxyInstance.HandleFile(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for blocked file, got %d", rr.Code)
+	}
+}
+
+// TestProxyPrivateNamespaces tests that private namespace packages never fall back to public indexes.
+func TestProxyPrivateNamespaces(t *testing.T) {
+	cfg := &config.Config{
+		PublicPyPIURL:      "https://pypi.org/simple/",
+		PrivatePyPIURL:     "https://console.redhat.com/api/pulp-content/public-calunga/mypypi/simple",
+		Port:               8080,
+		CacheEnabled:       false,
+		CacheSize:          100,
+		CacheTTL:           1,
+		PublicOnlyPackages: []string{"acme-tools"},
+		PrivateNamespaces:  []string{"acme-", "regex:^corp[0-9]+$"},
+	}
+
+	proxyInstance, err := NewProxy(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	mockClient := NewMockPyPIClient()
+	proxyInstance.client = mockClient
+	mockClient.publicExists["acme-utils"] = true
+	mockClient.publicExists["acme-tools"] = true
+	mockClient.privateExists["acme-tools"] = true
+	mockClient.publicExists["corp42"] = true
+
+	testCases := []struct {
+		packageName string
+		status      int
+		source      string
+	}{
+		// Only the attacker's public package exists
+		{"acme-utils", http.StatusNotFound, ""},
+		{"corp42", http.StatusNotFound, ""},
+		// A public-only entry can't pull a namespace package from public
+		{"acme-tools", http.StatusOK, cfg.PrivatePyPIURL},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.packageName, func(t *testing.T) {
+			req, err := http.NewRequest("GET", "/simple/"+tc.packageName+"/", http.NoBody)
+			if err != nil {
+				t.Fatalf("Failed to create request: %v", err)
+			}
+			rr := httptest.NewRecorder()
+			proxyInstance.HandlePackage(rr, req)
+
+			if rr.Code != tc.status {
+				t.Errorf("Expected status %d, got %d", tc.status, rr.Code)
+			}
+			if source := rr.Header().Get("X-PyPI-Source"); source != tc.source {
+				t.Errorf("Expected source header %q, got %q", tc.source, source)
+			}
+			if mockClient.publicCalls[tc.packageName] != 0 {
+				t.Errorf("Expected no public lookups for a private namespace package, got %d", mockClient.publicCalls[tc.packageName])
+			}
+		})
+	}
+
+	// A private outage returns 503 instead of falling back
+	mockClient.privateShouldError = true
+	req, err := http.NewRequest("GET", "/simple/acme-utils/", http.NoBody)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	rr := httptest.NewRecorder()
+	proxyInstance.HandlePackage(rr, req)
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503, got %d", rr.Code)
+	}
+
+	req, err = http.NewRequest("GET", "/packages/source/c/corp42/corp42-1.0.0.tar.gz", http.NoBody)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	rr = httptest.NewRecorder()
+	proxyInstance.HandleFile(rr, req)
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 for file, got %d", rr.Code)
+	}
+}
+
+// TestProxyPrivateIndexOutage tests that server errors from a private index are
+// reported as an outage and not cached as a missing package.
+func TestProxyPrivateIndexOutage(t *testing.T) {
+	var mu sync.Mutex
+	status := http.StatusInternalServerError
+	private := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	defer private.Close()
+	public := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`<a href="https://files.example.com/acme-utils-1.0.tar.gz">acme-utils-1.0.tar.gz</a>`))
+	}))
+	defer public.Close()
+
+	cfg := &config.Config{
+		PublicPyPIURL:     public.URL + "/simple/",
+		PrivatePyPIURL:    private.URL + "/simple/",
+		Port:              8080,
+		CacheEnabled:      true,
+		CacheSize:         100,
+		CacheTTL:          12,
+		PrivateNamespaces: []string{"acme-"},
+	}
+
+	proxyInstance, err := NewProxy(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	get := func(packageName string) int {
+		rr := httptest.NewRecorder()
+		proxyInstance.HandlePackage(rr, httptest.NewRequest("GET", "/simple/"+packageName+"/", http.NoBody))
+		return rr.Code
+	}
+
+	// Neither a namespace package nor any other falls back to public PyPI
+	if code := get("acme-utils"); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 for a private namespace package, got %d", code)
+	}
+	if code := get("requests"); code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500 for a package the private index might have, got %d", code)
+	}
+
+	// The failure wasn't cached as a missing package
+	mu.Lock()
+	status = http.StatusNotFound
+	mu.Unlock()
+	if code := get("acme-utils"); code != http.StatusNotFound {
+		t.Errorf("Expected status 404 once the private index answers, got %d", code)
+	}
+	if code := get("requests"); code != http.StatusOK {
+		t.Errorf("Expected the public page once the private index answers, got %d", code)
+	}
+}
+
+// TestProxyMergePackages tests that merge mode combines files from private and public pages.
+func TestProxyMergePackages(t *testing.T) {
+	cfg := &config.Config{
+		PublicPyPIURL:  "https://pypi.org/simple/",
+		PrivatePyPIURL: "https://private.example.com/simple/",
+		Port:           8080,
+		CacheEnabled:   false,
+		CacheSize:      100,
+		CacheTTL:       1,
+		MergePackages:  []string{"demo"},
+	}
+
+	proxyInstance, err := NewProxy(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	mockClient := NewMockPyPIClient()
+	proxyInstance.client = mockClient
+	mockClient.publicExists["demo"] = true
+	mockClient.privateExists["demo"] = true
+	mockClient.pages[cfg.PrivatePyPIURL] = map[string]string{"demo": `<html><body>
+<a href="../../packages/demo-1.0.tar.gz#sha256=private">demo-1.0.tar.gz</a><br/>
+<a href="../../packages/demo-1.0-cp312-cp312-linux_x86_64.whl#sha256=privwheel">demo-1.0-cp312-cp312-linux_x86_64.whl</a><br/>
+</body></html>`}
+	mockClient.pages[cfg.PublicPyPIURL] = map[string]string{"demo": `<html><body>
+<a href="https://files.pythonhosted.org/packages/aa/demo-1.0.tar.gz#sha256=public">demo-1.0.tar.gz</a><br/>
+<a href="https://files.pythonhosted.org/packages/bb/demo-2.0.tar.gz#sha256=newer" data-requires-python="&gt;=3.9">demo-2.0.tar.gz</a><br/>
+<a href="https://files.pythonhosted.org/packages/cc/demo-2.0-py3-none-any.whl#sha256=pubwheel">demo-2.0-py3-none-any.whl</a><br/>
+</body></html>`}
+
+	req, err := http.NewRequest("GET", "/simple/demo/", http.NoBody)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	rr := httptest.NewRecorder()
+	proxyInstance.HandlePackage(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	if source := rr.Header().Get("X-PyPI-Source"); source != cfg.PrivatePyPIURL+", "+cfg.PublicPyPIURL {
+		t.Errorf("Expected both indexes in source header, got %s", source)
+	}
+
+	body := rr.Body.String()
+	for _, want := range []string{"sha256=private", "sha256=privwheel", "sha256=newer", `data-requires-python="&gt;=3.9"`,
+		`data-origin="private">demo-1.0.tar.gz</a>`, `data-origin="public" data-requires-python="&gt;=3.9">demo-2.0.tar.gz</a>`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected merged page to contain %s", want)
+		}
+	}
+	for _, unwanted := range []string{"sha256=public", "sha256=pubwheel"} {
+		if strings.Contains(body, unwanted) {
+			t.Errorf("Expected merged page not to contain %s", unwanted)
+		}
+	}
+
+	// Files are fetched from the index that listed them
+	if origin, ok := proxyInstance.fileOrigins.Get(originKey(config.PublicIndexName, "demo-2.0.tar.gz")); !ok || origin.Index != config.PublicIndexName {
+		t.Errorf("Expected demo-2.0.tar.gz to originate from public, got %+v", origin)
+	}
+	if origin, ok := proxyInstance.fileOrigins.Get(originKey(config.PrivateIndexName, "demo-1.0.tar.gz")); !ok || origin.Index != config.PrivateIndexName {
+		t.Errorf("Expected demo-1.0.tar.gz to originate from private, got %+v", origin)
+	}
+
+	// Another index listing the same file name later doesn't redirect downloads
+	publicFile := pypi.File{Filename: "demo-1.0.tar.gz", URL: "https://files.pythonhosted.org/packages/aa/demo-1.0.tar.gz"}
+	proxyInstance.rewriteFile(proxyInstance.indexes[1], "demo", &publicFile)
+	downloads := []struct {
+		path     string
+		upstream string
+	}{
+		{"/demo-1.0.tar.gz", "https://private.example.com/packages/demo-1.0.tar.gz"},
+		{"/packages/private/packages/demo-1.0.tar.gz", "https://private.example.com/packages/demo-1.0.tar.gz"},
+		{"/packages/public/packages/aa/demo-1.0.tar.gz", "https://files.pythonhosted.org/packages/aa/demo-1.0.tar.gz"},
+	}
+	for _, tt := range downloads {
+		rr := httptest.NewRecorder()
+		proxyInstance.HandleFile(rr, httptest.NewRequest("GET", tt.path, http.NoBody))
+		if rr.Code != http.StatusOK {
+			t.Errorf("%s: expected status 200, got %d: %s", tt.path, rr.Code, rr.Body.String())
+			continue
+		}
+		if got := mockClient.proxiedURLs[len(mockClient.proxiedURLs)-1]; got != tt.upstream {
+			t.Errorf("%s: expected download from %s, got %s", tt.path, tt.upstream, got)
+		}
+	}
+}
+
+// TestProxyFileRoutingDashedName tests that files of projects with dashes in their name are routed by the full project name.
+func TestProxyFileRoutingDashedName(t *testing.T) {
+	cfg := &config.Config{
+		PublicPyPIURL:  "https://pypi.org/simple/",
+		PrivatePyPIURL: "https://private.example.com/simple/",
+		Port:           8080,
+		CacheEnabled:   false,
+		CacheSize:      100,
+		CacheTTL:       1,
+	}
+
+	proxyInstance, err := NewProxy(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	mockClient := NewMockPyPIClient()
+	proxyInstance.client = mockClient
+	mockClient.privateExists["python-dateutil"] = true
+	mockClient.pages[cfg.PrivatePyPIURL] = map[string]string{
+		"python-dateutil": `<a href="../../packages/python-dateutil-2.9.0.tar.gz">python-dateutil-2.9.0.tar.gz</a>`,
+	}
+
+	req, err := http.NewRequest("GET", "/packages/python-dateutil-2.9.0.tar.gz", http.NoBody)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	rr := httptest.NewRecorder()
+	proxyInstance.HandleFile(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", rr.Code)
+	}
+	if source := rr.Header().Get("X-PyPI-Source"); source != cfg.PrivatePyPIURL {
+		t.Errorf("Expected source header %s, got %s", cfg.PrivatePyPIURL, source)
+	}
+	if mockClient.privateCalls["python"] != 0 || mockClient.privateCalls["python-dateutil"] != 1 {
+		t.Errorf("Expected existence check for python-dateutil only, got %v", mockClient.privateCalls)
+	}
+}
+
+// TestProxyNameNormalization tests that package names are handled in their PEP 503 normalized form.
+func TestProxyNameNormalization(t *testing.T) {
+	cfg := &config.Config{
+		PublicPyPIURL:      "https://pypi.org/simple/",
+		PrivatePyPIURL:     "https://private.example.com/simple/",
+		Port:               8080,
+		CacheEnabled:       true,
+		CacheSize:          100,
+		CacheTTL:           1,
+		PublicOnlyPackages: []string{"Zope.Interface"},
+	}
+
+	proxyInstance, err := NewProxy(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	mockClient := NewMockPyPIClient()
+	proxyInstance.client = mockClient
+	mockClient.publicExists["zope-interface"] = true
+	mockClient.privateExists["zope-interface"] = true
+	mockClient.privateExists["python-dateutil"] = true
+	mockClient.pages[cfg.PrivatePyPIURL] = map[string]string{
+		"python-dateutil": `<a href="../../packages/Python_Dateutil-2.9.0.tar.gz">Python_Dateutil-2.9.0.tar.gz</a>`,
+	}
+
+	t.Run("non-canonical name redirects", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/simple/Zope_Interface/?format=html", http.NoBody)
+		rr := httptest.NewRecorder()
+		proxyInstance.HandlePackage(rr, req)
+
+		if rr.Code != http.StatusMovedPermanently {
+			t.Fatalf("Expected status 301, got %d", rr.Code)
+		}
+		if location := rr.Header().Get("Location"); location != "/simple/zope-interface/?format=html" {
+			t.Errorf("Expected redirect to /simple/zope-interface/?format=html, got %s", location)
+		}
+		if len(mockClient.publicCalls)+len(mockClient.privateCalls) != 0 {
+			t.Errorf("Expected no upstream calls before redirect, got public=%v private=%v", mockClient.publicCalls, mockClient.privateCalls)
+		}
+	})
+
+	t.Run("canonical name honours public_only_packages", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/simple/zope-interface/", http.NoBody)
+		rr := httptest.NewRecorder()
+		proxyInstance.HandlePackage(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", rr.Code)
+		}
+		if source := rr.Header().Get("X-PyPI-Source"); source != cfg.PublicPyPIURL {
+			t.Errorf("Expected source header %s, got %s", cfg.PublicPyPIURL, source)
+		}
+	})
+
+	t.Run("file names are normalized", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/packages/Python_Dateutil-2.9.0.tar.gz", http.NoBody)
+		rr := httptest.NewRecorder()
+		proxyInstance.HandleFile(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", rr.Code)
+		}
+		if mockClient.privateCalls["python-dateutil"] != 1 {
+			t.Errorf("Expected existence check for python-dateutil, got %v", mockClient.privateCalls)
+		}
+		if _, ok := proxyInstance.cache.GetPrivatePackage("python-dateutil"); !ok {
+			t.Error("Expected cache entry under the normalized name")
+		}
+	})
+}
+
+// TestProxyContentNegotiation tests that project pages are served as PEP 691 JSON or HTML depending on the Accept header.
+func TestProxyContentNegotiation(t *testing.T) {
+	cfg := &config.Config{
+		PublicPyPIURL:  "https://pypi.org/simple/",
+		PrivatePyPIURL: "https://private.example.com/simple/",
+		Port:           8080,
+		CacheEnabled:   false,
+		CacheSize:      100,
+		CacheTTL:       1,
+	}
+
+	proxyInstance, err := NewProxy(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	mockClient := NewMockPyPIClient()
+	proxyInstance.client = mockClient
+	mockClient.publicExists["demo"] = true
+	mockClient.privateExists["internal"] = true
+	mockClient.pages[cfg.PublicPyPIURL] = map[string]string{
+		"demo": `{"meta": {"api-version": "1.1"}, "name": "demo", "files": [
+			{"filename": "demo-1.0.tar.gz", "url": "https://files.pythonhosted.org/packages/demo-1.0.tar.gz", "hashes": {"sha256": "abc"}},
+			{"filename": "demo-1.0-py3-none-any.whl", "url": "https://files.pythonhosted.org/packages/demo-1.0-py3-none-any.whl", "hashes": {}}
+		]}`,
+	}
+	mockClient.pages[cfg.PrivatePyPIURL] = map[string]string{
+		"internal": `<html><body><a href="/packages/internal-1.0-py3-none-any.whl#sha256=def" data-requires-python="&gt;=3.9">internal-1.0-py3-none-any.whl</a><br/></body></html>`,
+	}
+
+	tests := []struct {
+		name        string
+		url         string
+		accept      string
+		contentType string
+		contains    []string
+		excludes    []string
+		source      string
+	}{
+		{
+			name:        "JSON upstream served as JSON without public wheels",
+			url:         "/simple/demo/",
+			accept:      "application/vnd.pypi.simple.v1+json",
+			contentType: pypi.ContentTypeJSON,
+			contains:    []string{`"filename":"demo-1.0.tar.gz"`, `"sha256":"abc"`},
+			excludes:    []string{"demo-1.0-py3-none-any.whl"},
+			source:      cfg.PublicPyPIURL,
+		},
+		{
+			name:        "JSON upstream served as HTML by default",
+			url:         "/simple/demo/",
+			contentType: "text/html; charset=utf-8",
+			contains:    []string{`href="/packages/public/packages/demo-1.0.tar.gz#sha256=abc"`},
+			excludes:    []string{"demo-1.0-py3-none-any.whl"},
+			source:      cfg.PublicPyPIURL,
+		},
+		{
+			name:        "HTML upstream served as JSON",
+			url:         "/simple/internal/",
+			accept:      "application/vnd.pypi.simple.v1+html;q=0.2, application/vnd.pypi.simple.v1+json",
+			contentType: pypi.ContentTypeJSON,
+			contains:    []string{`"filename":"internal-1.0-py3-none-any.whl"`, `"requires-python":"\u003e=3.9"`},
+			source:      cfg.PrivatePyPIURL,
+		},
+		{
+			name:        "format query parameter overrides Accept",
+			url:         "/simple/internal/?format=application/vnd.pypi.simple.v1%2Bjson",
+			accept:      "text/html",
+			contentType: pypi.ContentTypeJSON,
+			contains:    []string{`"name":"internal"`},
+			source:      cfg.PrivatePyPIURL,
+		},
+		{
+			name:        "PEP 691 HTML media type",
+			url:         "/simple/internal/",
+			accept:      "application/vnd.pypi.simple.v1+html",
+			contentType: pypi.ContentTypeHTML,
+			contains:    []string{"internal-1.0-py3-none-any.whl"},
+			source:      cfg.PrivatePyPIURL,
+		},
+		{
+			name:        "unsupported media type falls back to HTML",
+			url:         "/simple/internal/",
+			accept:      "application/xml",
+			contentType: "text/html; charset=utf-8",
+			contains:    []string{"internal-1.0-py3-none-any.whl"},
+			source:      cfg.PrivatePyPIURL,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.url, http.NoBody)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rr := httptest.NewRecorder()
+			proxyInstance.HandlePackage(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+			}
+			if contentType := rr.Header().Get("Content-Type"); contentType != tt.contentType {
+				t.Errorf("Expected Content-Type %s, got %s", tt.contentType, contentType)
+			}
+			if vary := rr.Header().Get("Vary"); vary != "Accept" {
+				t.Errorf("Expected Vary: Accept, got %q", vary)
+			}
+			if source := rr.Header().Get("X-PyPI-Source"); source != tt.source {
+				t.Errorf("Expected source header %s, got %s", tt.source, source)
+			}
+			body := rr.Body.String()
+			for _, want := range tt.contains {
+				if !strings.Contains(body, want) {
+					t.Errorf("Expected body to contain %s, got %s", want, body)
+				}
+			}
+			for _, unwanted := range tt.excludes {
+				if strings.Contains(body, unwanted) {
+					t.Errorf("Expected body not to contain %s, got %s", unwanted, body)
+				}
+			}
+		})
+	}
+}
+
+// TestProxyCoreMetadata tests that PEP 658 metadata files are routed like their distribution files and cached.
+func TestProxyCoreMetadata(t *testing.T) {
+	cfg := &config.Config{
+		PublicPyPIURL:  "https://pypi.org/simple/",
+		PrivatePyPIURL: "https://private.example.com/simple/",
+		Port:           8080,
+		CacheEnabled:   true,
+		CacheSize:      100,
+		CacheTTL:       1,
+	}
+
+	proxyInstance, err := NewProxy(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	mockClient := NewMockPyPIClient()
+	proxyInstance.client = mockClient
+	mockClient.privateExists["internal"] = true
+	mockClient.publicExists["demo"] = true
+	mockClient.pages[cfg.PublicPyPIURL] = map[string]string{
+		"demo": `<a href="https://files.pythonhosted.org/packages/demo-1.0.tar.gz#sha256=abc" data-core-metadata="sha256=meta" data-dist-info-metadata="sha256=meta">demo-1.0.tar.gz</a><br/>
+<a href="https://files.pythonhosted.org/packages/demo-1.0-py3-none-any.whl#sha256=def" data-core-metadata="sha256=wheelmeta">demo-1.0-py3-none-any.whl</a><br/>`,
+	}
+	mockClient.pages[cfg.PrivatePyPIURL] = map[string]string{
+		"internal": `<a href="../../packages/internal-1.0-py3-none-any.whl" data-core-metadata="true">internal-1.0-py3-none-any.whl</a>`,
+	}
+
+	t.Run("page keeps metadata attributes of served files only", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/simple/demo/", http.NoBody)
+		rr := httptest.NewRecorder()
+		proxyInstance.HandlePackage(rr, req)
+
+		body := rr.Body.String()
+		if !strings.Contains(body, `data-core-metadata="sha256=meta"`) || !strings.Contains(body, `data-dist-info-metadata="sha256=meta"`) {
+			t.Errorf("Expected metadata attributes on the sdist, got %s", body)
+		}
+		if strings.Contains(body, "wheelmeta") {
+			t.Errorf("Expected metadata of filtered wheels to be removed, got %s", body)
+		}
+	})
+
+	t.Run("private wheel metadata is served and cached", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			req := httptest.NewRequest("GET", "/packages/internal-1.0-py3-none-any.whl.metadata", http.NoBody)
+			rr := httptest.NewRecorder()
+			proxyInstance.HandleFile(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d", rr.Code)
+			}
+			if source := rr.Header().Get("X-PyPI-Source"); source != cfg.PrivatePyPIURL {
+				t.Errorf("Expected source header %s, got %s", cfg.PrivatePyPIURL, source)
+			}
+			if rr.Body.String() != "mock file content" {
+				t.Errorf("Expected metadata content, got %s", rr.Body.String())
+			}
+		}
+
+		fileURL := "https://private.example.com/packages/internal-1.0-py3-none-any.whl.metadata"
+		if mockClient.fileCalls[fileURL] != 1 {
+			t.Errorf("Expected a single upstream fetch of %s, got %v", fileURL, mockClient.fileCalls)
+		}
+	})
+
+	t.Run("public sdist metadata is served", func(t *testing.T) {
+		req := httptest.NewRequest("HEAD", "/packages/demo-1.0.tar.gz.metadata", http.NoBody)
+		rr := httptest.NewRecorder()
+		proxyInstance.HandleFile(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("Expected status 200, got %d", rr.Code)
+		}
+		if rr.Body.Len() != 0 {
+			t.Errorf("Expected empty body for HEAD, got %s", rr.Body.String())
+		}
+	})
+
+	t.Run("public wheel metadata is filtered", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/packages/demo-1.0-py3-none-any.whl.metadata", http.NoBody)
+		rr := httptest.NewRecorder()
+		proxyInstance.HandleFile(rr, req)
+
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404, got %d", rr.Code)
+		}
+	})
+}
+
+// TestProxyRootListing tests that /simple/ lists the union of the index root listings.
+func TestProxyRootListing(t *testing.T) {
+	newProxy := func(t *testing.T, includePublic bool) (*Proxy, *MockPyPIClient, *config.Config) {
+		t.Helper()
+		cfg := &config.Config{
+			PublicPyPIURL:     "https://pypi.org/simple/",
+			PrivatePyPIURL:    "https://private.example.com/simple/",
+			Port:              8080,
+			CacheEnabled:      true,
+			CacheSize:         100,
+			CacheTTL:          1,
+			RootIncludePublic: includePublic,
+			RootListingTTL:    60,
+			PrivateNamespaces: []string{"acme-"},
+			Rules: []config.RoutingRule{
+				{Name: "no-evil", Patterns: []string{"evil*"}, Target: config.TargetBlock},
+			},
+		}
+		proxyInstance, err := NewProxy(cfg)
+		if err != nil {
+			t.Fatalf("Failed to create proxy: %v", err)
+		}
+		mockClient := NewMockPyPIClient()
+		proxyInstance.client = mockClient
+		mockClient.projects[cfg.PrivatePyPIURL] = []string{"Acme_Tools", "shared", "evil-internal"}
+		mockClient.projects[cfg.PublicPyPIURL] = []string{"requests", "Shared", "acme-squatted", "evilpkg"}
+		return proxyInstance, mockClient, cfg
+	}
+
+	t.Run("private listing only by default", func(t *testing.T) {
+		proxyInstance, mockClient, cfg := newProxy(t, false)
+
+		req := httptest.NewRequest("GET", "/simple/", http.NoBody)
+		rr := httptest.NewRecorder()
+		proxyInstance.HandleSimpleIndex(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", rr.Code)
+		}
+		body := rr.Body.String()
+		if !strings.Contains(body, `<a href="acme-tools/">acme-tools</a>`) || !strings.Contains(body, `<a href="shared/">shared</a>`) {
+			t.Errorf("Expected private projects, got %s", body)
+		}
+		if strings.Contains(body, "requests") || strings.Contains(body, "evil-internal") {
+			t.Errorf("Expected public and blocked projects to be hidden, got %s", body)
+		}
+		if mockClient.listCalls[cfg.PublicPyPIURL] != 0 {
+			t.Error("Expected public listing not to be fetched")
+		}
+		if source := rr.Header().Get("X-PyPI-Source"); source != cfg.PrivatePyPIURL {
+			t.Errorf("Expected source header %s, got %s", cfg.PrivatePyPIURL, source)
+		}
+	})
+
+	t.Run("union with public listing as JSON", func(t *testing.T) {
+		proxyInstance, mockClient, cfg := newProxy(t, true)
+
+		for i := 0; i < 2; i++ {
+			req := httptest.NewRequest("GET", "/simple/", http.NoBody)
+			req.Header.Set("Accept", pypi.ContentTypeJSON)
+			rr := httptest.NewRecorder()
+			proxyInstance.HandleSimpleIndex(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d", rr.Code)
+			}
+			if contentType := rr.Header().Get("Content-Type"); contentType != pypi.ContentTypeJSON {
+				t.Errorf("Expected JSON content type, got %s", contentType)
+			}
+			expected := `{"meta":{"api-version":"1.0"},"projects":[{"name":"acme-tools"},{"name":"requests"},{"name":"shared"}]}`
+			if body := strings.TrimSpace(rr.Body.String()); body != expected {
+				t.Errorf("Expected %s, got %s", expected, body)
+			}
+		}
+
+		if mockClient.listCalls[cfg.PublicPyPIURL] != 1 || mockClient.listCalls[cfg.PrivatePyPIURL] != 1 {
+			t.Errorf("Expected the listing to be cached, got %v", mockClient.listCalls)
+		}
+	})
+
+	t.Run("concurrent requests share one fetch", func(t *testing.T) {
+		proxyInstance, mockClient, cfg := newProxy(t, false)
+		mockClient.listGate = make(chan struct{})
+
+		// A request that gives up doesn't wait for the fetch in flight
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, _, err := proxyInstance.rootProjects(ctx); !errors.Is(err, context.Canceled) {
+			t.Fatalf("Expected the canceled request to return, got %v", err)
+		}
+
+		var wg sync.WaitGroup
+		codes := make([]int, 4)
+		for i := range codes {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				rr := httptest.NewRecorder()
+				proxyInstance.HandleSimpleIndex(rr, httptest.NewRequest("GET", "/simple/", http.NoBody))
+				codes[i] = rr.Code
+			}(i)
+		}
+		close(mockClient.listGate)
+		wg.Wait()
+
+		for i, code := range codes {
+			if code != http.StatusOK {
+				t.Errorf("Request %d: expected status 200, got %d", i, code)
+			}
+		}
+		if mockClient.listCalls[cfg.PrivatePyPIURL] != 1 {
+			t.Errorf("Expected a single shared fetch, got %v", mockClient.listCalls)
+		}
+	})
+
+	t.Run("upstream failure", func(t *testing.T) {
+		proxyInstance, mockClient, _ := newProxy(t, false)
+		mockClient.shouldError = true
+
+		req := httptest.NewRequest("GET", "/simple/", http.NoBody)
+		rr := httptest.NewRecorder()
+		proxyInstance.HandleSimpleIndex(rr, req)
+
+		if rr.Code != http.StatusInternalServerError {
+			t.Errorf("Expected status 500, got %d", rr.Code)
+		}
+	})
+}
+
+// TestProxyFileLinkRewriting tests that file links point at the proxy and downloads reach the original upstream URL.
+func TestProxyFileLinkRewriting(t *testing.T) {
+	cfg := &config.Config{
+		PublicPyPIURL:     "https://pypi.org/simple/",
+		PrivatePyPIURL:    "https://private.example.com/simple/",
+		Port:              8080,
+		CacheEnabled:      false,
+		CacheSize:         100,
+		CacheTTL:          1,
+		PrivateNamespaces: []string{"acme-"},
+	}
+
+	proxyInstance, err := NewProxy(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	mockClient := NewMockPyPIClient()
+	proxyInstance.client = mockClient
+	mockClient.privateExists["internal"] = true
+	mockClient.publicExists["demo"] = true
+	mockClient.pages[cfg.PrivatePyPIURL] = map[string]string{
+		"internal": `<a href='../../packages/internal-1.0.tar.gz#sha256=abc' data-requires-python='&gt;=3.8'>internal-1.0.tar.gz</a>
+<a href="https://artifacts.example.com/api/pypi/files/internal-2.0.tar.gz" data-yanked="">internal-2.0.tar.gz</a>`,
+	}
+	mockClient.pages[cfg.PublicPyPIURL] = map[string]string{
+		"demo": `<a href="https://files.pythonhosted.org/packages/aa/bb/demo-1.0.tar.gz#sha256=def" data-gpg-sig="false">demo-1.0.tar.gz</a><br/>`,
+	}
+
+	getPage := func(t *testing.T, name string) string {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/simple/"+name+"/", http.NoBody)
+		rr := httptest.NewRecorder()
+		proxyInstance.HandlePackage(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", rr.Code)
+		}
+		return rr.Body.String()
+	}
+	getFile := func(t *testing.T, path string) *httptest.ResponseRecorder {
+		t.Helper()
+		mockClient.proxiedURLs = nil
+		req := httptest.NewRequest("GET", path, http.NoBody)
+		rr := httptest.NewRecorder()
+		proxyInstance.HandleFile(rr, req)
+		return rr
+	}
+
+	page := getPage(t, "internal")
+	for _, want := range []string{
+		`href="/packages/private/packages/internal-1.0.tar.gz#sha256=abc" data-requires-python="&gt;=3.8"`,
+		`href="/packages/private/api/pypi/files/internal-2.0.tar.gz" data-yanked=""`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("Expected page to contain %s, got %s", want, page)
+		}
+	}
+	page = getPage(t, "demo")
+	if !strings.Contains(page, `href="/packages/public/packages/aa/bb/demo-1.0.tar.gz#sha256=def" data-gpg-sig="false"`) {
+		t.Errorf("Expected rewritten public link, got %s", page)
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		status   int
+		upstream string
+	}{
+		{"relative link", "/packages/private/packages/internal-1.0.tar.gz", http.StatusOK, "https://private.example.com/packages/internal-1.0.tar.gz"},
+		{"file on another host", "/packages/private/api/pypi/files/internal-2.0.tar.gz", http.StatusOK, "https://artifacts.example.com/api/pypi/files/internal-2.0.tar.gz"},
+		{"public link", "/packages/public/packages/aa/bb/demo-1.0.tar.gz", http.StatusOK, "https://files.pythonhosted.org/packages/aa/bb/demo-1.0.tar.gz"},
+		{"link not on the page", "/packages/public/packages/cc/dd/demo-0.9.tar.gz", http.StatusNotFound, ""},
+		{"private namespace from public index", "/packages/public/packages/aa/bb/acme-tools-1.0.tar.gz", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := getFile(t, tt.path)
+			if rr.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d", tt.status, rr.Code)
+			}
+			if tt.upstream == "" {
+				if len(mockClient.proxiedURLs) != 0 {
+					t.Errorf("Expected no upstream request, got %v", mockClient.proxiedURLs)
+				}
+				return
+			}
+			if len(mockClient.proxiedURLs) != 1 || mockClient.proxiedURLs[0] != tt.upstream {
+				t.Errorf("Expected upstream %s, got %v", tt.upstream, mockClient.proxiedURLs)
+			}
+		})
+	}
+}
+
+// TestProxyFileOrigins tests that downloads use the exact upstream URL listed on package pages.
+func TestProxyFileOrigins(t *testing.T) {
+	cfg := &config.Config{
+		PublicPyPIURL:  "https://pypi.org/simple/",
+		PrivatePyPIURL: "https://console.example.com/api/pulp-content/team/simple/",
+		Port:           8080,
+		CacheEnabled:   false,
+		CacheSize:      100,
+		CacheTTL:       1,
+	}
+
+	proxyInstance, err := NewProxy(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	mockClient := NewMockPyPIClient()
+	proxyInstance.client = mockClient
+	mockClient.privateExists["internal"] = true
+	mockClient.pages[cfg.PrivatePyPIURL] = map[string]string{
+		"internal": `<a href="https://cdn.example.com/pulp/content/team/internal-1.0.tar.gz#sha256=abc">internal-1.0.tar.gz</a>` +
+			`<a href="../../../artifacts/internal-1.1.tar.gz">internal-1.1.tar.gz</a>`,
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		status   int
+		upstream string
+	}{
+		{"legacy path found on the page", "/internal-1.0.tar.gz", http.StatusOK, "https://cdn.example.com/pulp/content/team/internal-1.0.tar.gz"},
+		{"rewritten link found on the page", "/packages/private/api/pulp-content/artifacts/internal-1.1.tar.gz", http.StatusOK, "https://console.example.com/api/pulp-content/artifacts/internal-1.1.tar.gz"},
+		{"remembered file name", "/packages/aa/bb/internal-1.0.tar.gz", http.StatusOK, "https://cdn.example.com/pulp/content/team/internal-1.0.tar.gz"},
+		{"file not on the page", "/internal-0.9.tar.gz", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient.proxiedURLs = nil
+			req := httptest.NewRequest("GET", tt.path, http.NoBody)
+			rr := httptest.NewRecorder()
+			proxyInstance.HandleFile(rr, req)
+
+			if rr.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, rr.Code, rr.Body.String())
+			}
+			if tt.upstream == "" {
+				if len(mockClient.proxiedURLs) != 0 {
+					t.Errorf("Expected no upstream request, got %v", mockClient.proxiedURLs)
+				}
+				return
+			}
+			if len(mockClient.proxiedURLs) != 1 || mockClient.proxiedURLs[0] != tt.upstream {
+				t.Errorf("Expected upstream %s, got %v", tt.upstream, mockClient.proxiedURLs)
+			}
+		})
+	}
+
+	// Files found by a page fetch are verified like files of served pages
+	if digest := proxyInstance.fileDigest(config.IndexConfig{Name: config.PrivateIndexName}, "internal", "internal-1.0.tar.gz"); digest != "abc" {
+		t.Errorf("Expected the digest from the page to be remembered, got %q", digest)
+	}
+}
+
+// TestProxyFileLinkEscapedPath tests that links with escaped characters, such
+// as the + of local versions, resolve to the exact upstream URL they name.
+func TestProxyFileLinkEscapedPath(t *testing.T) {
+	cfg := &config.Config{
+		PublicPyPIURL:  "https://pypi.org/simple/",
+		PrivatePyPIURL: "https://download.example.com/whl/simple/",
+		Port:           8080,
+		CacheEnabled:   false,
+		CacheSize:      100,
+		CacheTTL:       1,
+	}
+
+	proxyInstance, err := NewProxy(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	const fileName = "torch-2.1.0+cu121-cp312-cp312-linux_x86_64.whl"
+	mockClient := NewMockPyPIClient()
+	proxyInstance.client = mockClient
+	mockClient.privateExists["torch"] = true
+	// The same file is mirrored at two locations, so its name alone is ambiguous
+	mockClient.pages[cfg.PrivatePyPIURL] = map[string]string{
+		"torch": `<a href="/whl/cu121/torch-2.1.0%2Bcu121-cp312-cp312-linux_x86_64.whl">` + fileName + `</a>` +
+			`<a href="/mirror/torch-2.1.0%2Bcu121-cp312-cp312-linux_x86_64.whl">` + fileName + `</a>`,
+	}
+
+	rr := httptest.NewRecorder()
+	proxyInstance.HandlePackage(rr, httptest.NewRequest("GET", "/simple/torch/", http.NoBody))
+	link := "/packages/private/whl/cu121/torch-2.1.0%2Bcu121-cp312-cp312-linux_x86_64.whl"
+	if !strings.Contains(rr.Body.String(), `href="`+link+`"`) {
+		t.Fatalf("Expected the link to keep its escaping, got %s", rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	proxyInstance.HandleFile(rr, httptest.NewRequest("GET", link, http.NoBody))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	want := "https://download.example.com/whl/cu121/torch-2.1.0%2Bcu121-cp312-cp312-linux_x86_64.whl"
+	if len(mockClient.proxiedURLs) != 1 || mockClient.proxiedURLs[0] != want {
+		t.Errorf("Expected upstream %s, got %v", want, mockClient.proxiedURLs)
+	}
+}
+
+// TestProxyWheelPolicy tests that public wheels are served according to the package's wheel policy.
+func TestProxyWheelPolicy(t *testing.T) {
+	cfg := &config.Config{
+		PublicPyPIURL:  "https://pypi.org/simple/",
+		PrivatePyPIURL: "https://private.example.com/simple/",
+		Port:           8080,
+		CacheEnabled:   false,
+		CacheSize:      100,
+		CacheTTL:       1,
+		WheelPolicies: []config.WheelPolicy{
+			{Packages: []string{"numpy"}, Policy: config.WheelPolicyAllowTags, Tags: []string{"cp312-cp312-manylinux*_x86_64"}},
+			{Packages: []string{"glob:pure-*"}, Policy: config.WheelPolicyAllowPure},
+		},
+	}
+
+	proxyInstance, err := NewProxy(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	mockClient := NewMockPyPIClient()
+	proxyInstance.client = mockClient
+	files := map[string][]string{
+		"numpy":    {"numpy-2.0.0.tar.gz", "numpy-2.0.0-cp312-cp312-manylinux_2_17_x86_64.whl", "numpy-2.0.0-cp312-cp312-win_amd64.whl"},
+		"pure-lib": {"pure_lib-1.0.tar.gz", "pure_lib-1.0-py3-none-any.whl", "pure_lib-1.0-cp312-abi3-macosx_11_0_arm64.whl"},
+		"denied":   {"denied-1.0.tar.gz", "denied-1.0-py3-none-any.whl"},
+	}
+	mockClient.pages[cfg.PublicPyPIURL] = make(map[string]string)
+	for name, fileNames := range files {
+		mockClient.publicExists[name] = true
+		var page strings.Builder
+		for _, fileName := range fileNames {
+			fmt.Fprintf(&page, "<a href=\"https://files.pythonhosted.org/packages/%s\">%s</a>\n", fileName, fileName)
+		}
+		mockClient.pages[cfg.PublicPyPIURL][name] = page.String()
+	}
+
+	tests := []struct {
+		pkg     string
+		allowed []string
+		refused []string
+	}{
+		{"numpy", []string{"numpy-2.0.0.tar.gz", "numpy-2.0.0-cp312-cp312-manylinux_2_17_x86_64.whl"}, []string{"numpy-2.0.0-cp312-cp312-win_amd64.whl"}},
+		{"pure-lib", []string{"pure_lib-1.0.tar.gz", "pure_lib-1.0-py3-none-any.whl"}, []string{"pure_lib-1.0-cp312-abi3-macosx_11_0_arm64.whl"}},
+		{"denied", []string{"denied-1.0.tar.gz"}, []string{"denied-1.0-py3-none-any.whl"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pkg, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/simple/"+tt.pkg+"/", http.NoBody)
+			rr := httptest.NewRecorder()
+			proxyInstance.HandlePackage(rr, req)
+			body := rr.Body.String()
+
+			for _, fileName := range tt.allowed {
+				if !strings.Contains(body, ">"+fileName+"<") {
+					t.Errorf("Expected %s to be listed, got %s", fileName, body)
+				}
+				rr := httptest.NewRecorder()
+				proxyInstance.HandleFile(rr, httptest.NewRequest("GET", "/packages/public/packages/"+fileName, http.NoBody))
+				if rr.Code != http.StatusOK {
+					t.Errorf("Expected %s to be served, got %d", fileName, rr.Code)
+				}
+			}
+			for _, fileName := range tt.refused {
+				if strings.Contains(body, fileName) {
+					t.Errorf("Expected %s to be filtered, got %s", fileName, body)
+				}
+				rr := httptest.NewRecorder()
+				proxyInstance.HandleFile(rr, httptest.NewRequest("GET", "/packages/public/packages/"+fileName, http.NoBody))
+				if rr.Code != http.StatusForbidden {
+					t.Errorf("Expected %s to be refused with 403, got %d", fileName, rr.Code)
+				}
+				if !strings.Contains(rr.Body.String(), "wheel policy for "+tt.pkg) {
+					t.Errorf("Expected refusal reason naming the policy, got %s", rr.Body.String())
+				}
+			}
+		})
+	}
+}
+
+// TestProxyRefusesPublicWheelDownloads tests that public wheels are refused on every download route.
+func TestProxyRefusesPublicWheelDownloads(t *testing.T) {
+	cfg := &config.Config{
+		PublicPyPIURL:  "https://pypi.org/simple/",
+		PrivatePyPIURL: "https://private.example.com/simple/",
+		Port:           8080,
+		CacheEnabled:   false,
+		CacheSize:      100,
+		CacheTTL:       1,
+		MergePackages:  []string{"merged"},
+	}
+
+	proxyInstance, err := NewProxy(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	mockClient := NewMockPyPIClient()
+	proxyInstance.client = mockClient
+	mockClient.publicExists["requests"] = true
+	mockClient.privateExists["internal"] = true
+	mockClient.publicExists["merged"] = true
+	mockClient.pages[cfg.PublicPyPIURL] = map[string]string{
+		"merged": `<a href="https://files.pythonhosted.org/packages/merged-1.0-py3-none-any.whl">merged-1.0-py3-none-any.whl</a>`,
+		"requests": `<a href="https://files.pythonhosted.org/packages/aa/bb/requests-2.31.0-py3-none-any.whl">requests-2.31.0-py3-none-any.whl</a>` +
+			`<a href="https://files.pythonhosted.org/packages/aa/bb/requests-2.31.0.tar.gz">requests-2.31.0.tar.gz</a>`,
+	}
+	mockClient.pages[cfg.PrivatePyPIURL] = map[string]string{
+		"internal": `<a href="../../packages/internal-1.0-cp312-cp312-linux_x86_64.whl">internal-1.0-cp312-cp312-linux_x86_64.whl</a>`,
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+	}{
+		{"packages route", "/packages/aa/bb/requests-2.31.0-py3-none-any.whl", http.StatusForbidden},
+		{"direct route", "/requests-2.31.0-py3-none-any.whl", http.StatusForbidden},
+		{"rewritten link", "/packages/public/packages/requests-2.31.0-py3-none-any.whl", http.StatusForbidden},
+		{"merged package", "/packages/merged-1.0-py3-none-any.whl", http.StatusForbidden},
+		{"public sdist", "/packages/aa/bb/requests-2.31.0.tar.gz", http.StatusOK},
+		{"private wheel", "/packages/internal-1.0-cp312-cp312-linux_x86_64.whl", http.StatusOK},
+		{"public wheel metadata", "/packages/aa/bb/requests-2.31.0-py3-none-any.whl.metadata", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient.proxiedURLs = nil
+			req := httptest.NewRequest("GET", tt.path, http.NoBody)
+			req.Header.Set("X-Forwarded-For", "10.0.0.7")
+			rr := httptest.NewRecorder()
+			proxyInstance.HandleFile(rr, req)
+
+			if rr.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, rr.Code, rr.Body.String())
+			}
+			if tt.status == http.StatusForbidden {
+				if !strings.Contains(rr.Body.String(), "Download refused: public wheel") {
+					t.Errorf("Expected refusal reason, got %s", rr.Body.String())
+				}
+				if len(mockClient.proxiedURLs) != 0 {
+					t.Errorf("Expected no upstream request, got %v", mockClient.proxiedURLs)
+				}
+			}
+		})
+	}
+}
+
+func TestProxyMinReleaseAge(t *testing.T) {
+	cfg := &config.Config{
+		PublicPyPIURL:  "https://pypi.org/simple/",
+		PrivatePyPIURL: "https://private.example.com/simple/",
+		Port:           8080,
+		CacheEnabled:   true,
+		CacheSize:      100,
+		CacheTTL:       1,
+		MinReleaseAge:  72 * time.Hour,
+		MinReleaseAgeOverrides: []config.ReleaseAgeOverride{
+			{Packages: []string{"trusted"}, Age: 0},
+		},
+		MergePackages: []string{"merged"},
+	}
+
+	proxyInstance, err := NewProxy(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	mockClient := NewMockPyPIClient()
+	proxyInstance.client = mockClient
+
+	old := time.Now().Add(-30 * 24 * time.Hour).UTC()
+	recent := time.Now().Add(-time.Hour).UTC()
+
+	// PEP 700 upload times are read from JSON pages
+	mockClient.publicExists["fresh"] = true
+	mockClient.pages[cfg.PublicPyPIURL] = map[string]string{
+		"fresh": fmt.Sprintf(`{"meta":{"api-version":"1.1"},"name":"fresh","files":[`+
+			`{"filename":"fresh-1.0.tar.gz","url":"https://files.pythonhosted.org/packages/fresh-1.0.tar.gz","hashes":{},"upload-time":%q},`+
+			`{"filename":"fresh-1.1.tar.gz","url":"https://files.pythonhosted.org/packages/fresh-1.1.tar.gz","hashes":{},"upload-time":%q}]}`,
+			old.Format(time.RFC3339Nano), recent.Format(time.RFC3339Nano)),
+		"legacy": `<a href="https://files.pythonhosted.org/packages/legacy-1.0.tar.gz">legacy-1.0.tar.gz</a>` +
+			`<a href="https://files.pythonhosted.org/packages/legacy-1.1.tar.gz">legacy-1.1.tar.gz</a>` +
+			`<a href="https://files.pythonhosted.org/packages/legacy-1.2.tar.gz">legacy-1.2.tar.gz</a>`,
+		"trusted": `<a href="https://files.pythonhosted.org/packages/trusted-1.0.tar.gz">trusted-1.0.tar.gz</a>`,
+		"merged":  `<a href="https://files.pythonhosted.org/packages/merged-1.1.tar.gz">merged-1.1.tar.gz</a>`,
+	}
+	mockClient.pages[cfg.PrivatePyPIURL] = map[string]string{
+		"merged": `<a href="https://private.example.com/packages/merged-1.0.tar.gz">merged-1.0.tar.gz</a>`,
+	}
+
+	// HTML pages fall back to the JSON API; files without a known upload time stay hidden
+	mockClient.publicExists["legacy"] = true
+	mockClient.uploadTimes["legacy"] = map[string]time.Time{
+		"legacy-1.0.tar.gz": old,
+		"legacy-1.1.tar.gz": recent,
+	}
+	mockClient.publicExists["trusted"] = true
+
+	// Merged pages quarantine the public files only
+	mockClient.publicExists["merged"] = true
+	mockClient.privateExists["merged"] = true
+	mockClient.uploadTimes["merged"] = map[string]time.Time{"merged-1.1.tar.gz": recent}
+
+	tests := []struct {
+		pkg    string
+		shown  []string
+		hidden []string
+	}{
+		{"fresh", []string{"fresh-1.0.tar.gz"}, []string{"fresh-1.1.tar.gz"}},
+		{"legacy", []string{"legacy-1.0.tar.gz"}, []string{"legacy-1.1.tar.gz", "legacy-1.2.tar.gz"}},
+		{"trusted", []string{"trusted-1.0.tar.gz"}, nil},
+		{"merged", []string{"merged-1.0.tar.gz"}, []string{"merged-1.1.tar.gz"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pkg, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/simple/"+tt.pkg+"/", http.NoBody)
+			rr := httptest.NewRecorder()
+			proxyInstance.HandlePackage(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+			}
+			for _, name := range tt.shown {
+				if !strings.Contains(rr.Body.String(), name) {
+					t.Errorf("Expected %s on the page, got %s", name, rr.Body.String())
+				}
+			}
+			for _, name := range tt.hidden {
+				if strings.Contains(rr.Body.String(), name) {
+					t.Errorf("Expected %s to be hidden, got %s", name, rr.Body.String())
+				}
+			}
+		})
+	}
+
+	if calls := mockClient.uploadTimeCalls["fresh"]; calls != 0 {
+		t.Errorf("Expected no JSON API lookup for a page with upload times, got %d", calls)
+	}
+
+	// Upload times from the JSON API are cached
+	req := httptest.NewRequest("GET", "/simple/legacy/", http.NoBody)
+	proxyInstance.HandlePackage(httptest.NewRecorder(), req)
+	if calls := mockClient.uploadTimeCalls["legacy"]; calls != 1 {
+		t.Errorf("Expected 1 JSON API lookup for legacy, got %d", calls)
+	}
+
+	// Downloads of quarantined files are refused
+	downloads := []struct {
+		path   string
+		status int
+	}{
+		{"/packages/public/packages/fresh-1.0.tar.gz", http.StatusOK},
+		{"/packages/public/packages/fresh-1.1.tar.gz", http.StatusForbidden},
+		{"/packages/public/packages/legacy-1.2.tar.gz", http.StatusForbidden},
+	}
+	for _, tt := range downloads {
+		req := httptest.NewRequest("GET", tt.path, http.NoBody)
+		rr := httptest.NewRecorder()
+		proxyInstance.HandleFile(rr, req)
+		if rr.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d: %s", tt.path, tt.status, rr.Code, rr.Body.String())
+		}
+		if tt.status == http.StatusForbidden && !strings.Contains(rr.Body.String(), "min_release_age") {
+			t.Errorf("%s: expected quarantine reason, got %s", tt.path, rr.Body.String())
+		}
+	}
+}
+
+func TestProxyAsOfSnapshots(t *testing.T) {
+	cfg := &config.Config{
+		PublicPyPIURL:  "https://pypi.org/simple/",
+		PrivatePyPIURL: "https://private.example.com/simple/",
+		Port:           8080,
+		CacheEnabled:   true,
+		CacheSize:      100,
+		CacheTTL:       1,
+	}
+
+	proxyInstance, err := NewProxy(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	mockClient := NewMockPyPIClient()
+	proxyInstance.client = mockClient
+
+	mockClient.publicExists["requests"] = true
+	mockClient.pages[cfg.PublicPyPIURL] = map[string]string{
+		"requests": `{"meta":{"api-version":"1.1"},"name":"requests","files":[` +
+			`{"filename":"requests-2.31.0.tar.gz","url":"https://files.pythonhosted.org/packages/requests-2.31.0.tar.gz","hashes":{},"upload-time":"2023-05-22T15:12:44.175Z"},` +
+			`{"filename":"requests-2.32.0.tar.gz","url":"https://files.pythonhosted.org/packages/requests-2.32.0.tar.gz","hashes":{},"upload-time":"2024-05-20T15:41:28.000Z"},` +
+			`{"filename":"requests-2.32.1.tar.gz","url":"https://files.pythonhosted.org/packages/requests-2.32.1.tar.gz","hashes":{}}]}`,
+	}
+	mockClient.privateExists["internal"] = true
+	mockClient.pages[cfg.PrivatePyPIURL] = map[string]string{
+		"internal": `<a href="https://private.example.com/packages/internal-1.0.tar.gz">internal-1.0.tar.gz</a>`,
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		header   string
+		untimed  string
+		status   int
+		shown    []string
+		hidden   []string
+		snapshot string
+	}{
+		{
+			name:   "current state",
+			path:   "/simple/requests/",
+			status: http.StatusOK,
+			shown:  []string{"requests-2.31.0.tar.gz", "requests-2.32.0.tar.gz", "requests-2.32.1.tar.gz"},
+		},
+		{
+			name:     "path prefix",
+			path:     "/as-of/2024-01-01T00:00:00Z/simple/requests/",
+			status:   http.StatusOK,
+			shown:    []string{"requests-2.31.0.tar.gz"},
+			hidden:   []string{"requests-2.32.0.tar.gz", "requests-2.32.1.tar.gz"},
+			snapshot: "2024-01-01T00:00:00Z",
+		},
+		{
+			name:     "request header",
+			path:     "/simple/requests/",
+			header:   "2024-06-01T00:00:00Z",
+			status:   http.StatusOK,
+			shown:    []string{"requests-2.31.0.tar.gz", "requests-2.32.0.tar.gz"},
+			hidden:   []string{"requests-2.32.1.tar.gz"},
+			snapshot: "2024-06-01T00:00:00Z",
+		},
+		{
+			name:     "path prefix wins over header",
+			path:     "/as-of/2024-01-01/simple/requests/",
+			header:   "2024-06-01T00:00:00Z",
+			status:   http.StatusOK,
+			hidden:   []string{"requests-2.32.0.tar.gz"},
+			snapshot: "2024-01-01T00:00:00Z",
+		},
+		{
+			name:   "invalid timestamp",
+			path:   "/as-of/last-week/simple/requests/",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:     "untimed private files included",
+			path:     "/as-of/2024-01-01T00:00:00Z/simple/internal/",
+			status:   http.StatusOK,
+			shown:    []string{"internal-1.0.tar.gz"},
+			snapshot: "2024-01-01T00:00:00Z",
+		},
+		{
+			name:     "untimed private files excluded",
+			path:     "/as-of/2024-01-01T00:00:00Z/simple/internal/",
+			untimed:  config.AsOfExcludeUntimed,
+			status:   http.StatusOK,
+			hidden:   []string{"internal-1.0.tar.gz"},
+			snapshot: "2024-01-01T00:00:00Z",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg.AsOfUntimedPrivateFiles = tt.untimed
+			req := httptest.NewRequest("GET", tt.path, http.NoBody)
+			if tt.header != "" {
+				req.Header.Set(pypi.HeaderAsOf, tt.header)
+			}
+			rr := httptest.NewRecorder()
+			proxyInstance.HandlePackage(rr, req)
+
+			if rr.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, rr.Code, rr.Body.String())
+			}
+			for _, name := range tt.shown {
+				if !strings.Contains(rr.Body.String(), name) {
+					t.Errorf("Expected %s on the page, got %s", name, rr.Body.String())
+				}
+			}
+			for _, name := range tt.hidden {
+				if strings.Contains(rr.Body.String(), name) {
+					t.Errorf("Expected %s to be hidden, got %s", name, rr.Body.String())
+				}
+			}
+			if snapshot := rr.Header().Get(pypi.HeaderAsOf); snapshot != tt.snapshot {
+				t.Errorf("Expected %s header %q, got %q", pypi.HeaderAsOf, tt.snapshot, snapshot)
+			}
+		})
+	}
+
+	// Non-canonical names redirect within the snapshot
+	req := httptest.NewRequest("GET", "/as-of/2024-01-01/simple/Requests/", http.NoBody)
+	rr := httptest.NewRecorder()
+	proxyInstance.HandlePackage(rr, req)
+	if location := rr.Header().Get("Location"); location != "/as-of/2024-01-01/simple/requests/" {
+		t.Errorf("Expected redirect within the snapshot, got %q", location)
+	}
+
+	// A configured timestamp applies to every request
+	cfg.AsOf = "2024-01-01T00:00:00Z"
+	req = httptest.NewRequest("GET", "/simple/requests/", http.NoBody)
+	rr = httptest.NewRecorder()
+	proxyInstance.HandlePackage(rr, req)
+	if strings.Contains(rr.Body.String(), "requests-2.32.0.tar.gz") {
+		t.Errorf("Expected the configured snapshot to hide requests-2.32.0.tar.gz, got %s", rr.Body.String())
+	}
+}
+
+func TestProxyBlocklist(t *testing.T) {
+	cfg := &config.Config{
+		PublicPyPIURL:  "https://pypi.org/simple/",
+		PrivatePyPIURL: "https://private.example.com/simple/",
+		Port:           8080,
+		CacheEnabled:   false,
+		CacheSize:      100,
+		CacheTTL:       1,
+		Blocklist: []config.BlockEntry{
+			{Package: "requests", Versions: "==2.32.0", Reason: "known-bad release"},
+			{Filename: "internal-1.1.tar.gz", Reason: "revoked internal build"},
+			{Package: "requests", Versions: "==2.31.0", Reason: "expired ban", Expires: "2020-01-01"},
+		},
+	}
+
+	proxyInstance, err := NewProxy(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	mockClient := NewMockPyPIClient()
+	proxyInstance.client = mockClient
+	mockClient.publicExists["requests"] = true
+	mockClient.privateExists["internal"] = true
+	mockClient.pages[cfg.PublicPyPIURL] = map[string]string{
+		"requests": `<a href="https://files.pythonhosted.org/packages/requests-2.31.0.tar.gz">requests-2.31.0.tar.gz</a>` +
+			`<a href="https://files.pythonhosted.org/packages/requests-2.32.0.tar.gz">requests-2.32.0.tar.gz</a>`,
+	}
+	mockClient.pages[cfg.PrivatePyPIURL] = map[string]string{
+		"internal": `<a href="https://private.example.com/packages/internal-1.0.tar.gz">internal-1.0.tar.gz</a>` +
+			`<a href="https://private.example.com/packages/internal-1.1.tar.gz">internal-1.1.tar.gz</a>`,
+	}
+
+	pages := []struct {
+		pkg    string
+		shown  string
+		hidden string
+	}{
+		{"requests", "requests-2.31.0.tar.gz", "requests-2.32.0.tar.gz"},
+		{"internal", "internal-1.0.tar.gz", "internal-1.1.tar.gz"},
+	}
+	for _, tt := range pages {
+		req := httptest.NewRequest("GET", "/simple/"+tt.pkg+"/", http.NoBody)
+		rr := httptest.NewRecorder()
+		proxyInstance.HandlePackage(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		if !strings.Contains(rr.Body.String(), tt.shown) {
+			t.Errorf("Expected %s on the page, got %s", tt.shown, rr.Body.String())
+		}
+		if strings.Contains(rr.Body.String(), tt.hidden) {
+			t.Errorf("Expected %s to be hidden, got %s", tt.hidden, rr.Body.String())
+		}
+	}
+
+	downloads := []struct {
+		path   string
+		status int
+		reason string
+	}{
+		{"/packages/public/packages/requests-2.32.0.tar.gz", http.StatusForbidden, "known-bad release"},
+		{"/packages/private/packages/internal-1.1.tar.gz", http.StatusForbidden, "revoked internal build"},
+		{"/packages/public/packages/requests-2.31.0.tar.gz", http.StatusOK, ""},
+		{"/packages/private/packages/internal-1.1.tar.gz.metadata", http.StatusNotFound, ""},
+	}
+	for _, tt := range downloads {
+		mockClient.proxiedURLs = nil
+		req := httptest.NewRequest("GET", tt.path, http.NoBody)
+		rr := httptest.NewRecorder()
+		proxyInstance.HandleFile(rr, req)
+
+		if rr.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d: %s", tt.path, tt.status, rr.Code, rr.Body.String())
+			continue
+		}
+		if tt.reason != "" && !strings.Contains(rr.Body.String(), tt.reason) {
+			t.Errorf("%s: expected reason %q, got %s", tt.path, tt.reason, rr.Body.String())
+		}
+		if tt.status != http.StatusOK && len(mockClient.proxiedURLs) != 0 {
+			t.Errorf("%s: expected no upstream request, got %v", tt.path, mockClient.proxiedURLs)
+		}
+	}
+}
+
+func TestProxyVulnerabilityFiltering(t *testing.T) {
+	dir := t.TempDir()
+	records := map[string]string{
+		"GHSA-crit.json": `{"id": "GHSA-crit", "database_specific": {"severity": "CRITICAL"},
+			"affected": [{"package": {"ecosystem": "PyPI", "name": "requests"}, "versions": ["2.30.0"]}]}`,
+		"GHSA-high.json": `{"id": "GHSA-high", "database_specific": {"severity": "HIGH"},
+			"affected": [{"package": {"ecosystem": "PyPI", "name": "requests"}, "versions": ["2.31.0"]}]}`,
+	}
+	for name, content := range records {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := &config.Config{
+		PublicPyPIURL:  "https://pypi.org/simple/",
+		PrivatePyPIURL: "https://private.example.com/simple/",
+		Port:           8080,
+		CacheEnabled:   false,
+		CacheSize:      100,
+		CacheTTL:       1,
+		AdminToken:     "secret",
+		OSV: config.OSVConfig{
+			Path:    dir,
+			Actions: map[string]string{"critical": config.OSVActionHide, "high": config.OSVActionYank},
+		},
+	}
+
+	proxyInstance, err := NewProxy(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	mockClient := NewMockPyPIClient()
+	proxyInstance.client = mockClient
+	mockClient.publicExists["requests"] = true
+	mockClient.pages[cfg.PublicPyPIURL] = map[string]string{
+		"requests": `<a href="https://files.pythonhosted.org/packages/requests-2.30.0.tar.gz">requests-2.30.0.tar.gz</a>` +
+			`<a href="https://files.pythonhosted.org/packages/requests-2.31.0.tar.gz">requests-2.31.0.tar.gz</a>` +
+			`<a href="https://files.pythonhosted.org/packages/requests-2.32.0.tar.gz">requests-2.32.0.tar.gz</a>`,
+	}
+
+	getPage := func() string {
+		req := httptest.NewRequest("GET", "/simple/requests/", http.NoBody)
+		rr := httptest.NewRecorder()
+		proxyInstance.HandlePackage(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		return rr.Body.String()
+	}
+
+	body := getPage()
+	if strings.Contains(body, "requests-2.30.0.tar.gz") {
+		t.Errorf("Expected the critical release to be hidden, got %s", body)
+	}
+	if !strings.Contains(body, `data-yanked="GHSA-high"`) {
+		t.Errorf("Expected the high severity release to be yanked with the advisory ID, got %s", body)
+	}
+	if !strings.Contains(body, "requests-2.32.0.tar.gz") || strings.Count(body, "data-yanked") != 1 {
+		t.Errorf("Expected the unaffected release to be listed as is, got %s", body)
+	}
+
+	req := httptest.NewRequest("GET", "/packages/public/packages/requests-2.30.0.tar.gz", http.NoBody)
+	rr := httptest.NewRecorder()
+	proxyInstance.HandleFile(rr, req)
+	if rr.Code != http.StatusForbidden || !strings.Contains(rr.Body.String(), "GHSA-crit") {
+		t.Errorf("Expected the hidden release download to be refused, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// A new advisory takes effect after an authenticated reload
+	record := `{"id": "GHSA-new", "database_specific": {"severity": "CRITICAL"},
+		"affected": [{"package": {"ecosystem": "PyPI", "name": "requests"}, "versions": ["2.32.0"]}]}`
+	if err := os.WriteFile(filepath.Join(dir, "GHSA-new.json"), []byte(record), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	req = httptest.NewRequest("POST", "/admin/osv/reload", http.NoBody)
+	rr = httptest.NewRecorder()
+	proxyInstance.HandleReloadVulnerabilities(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected an unauthenticated reload to be refused, got %d", rr.Code)
+	}
+
+	req = httptest.NewRequest("POST", "/admin/osv/reload", http.NoBody)
+	req.Header.Set("Authorization", "Bearer secret")
+	rr = httptest.NewRecorder()
+	proxyInstance.HandleReloadVulnerabilities(rr, req)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"advisories": 3`) {
+		t.Fatalf("Expected the reload to succeed, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	if body := getPage(); strings.Contains(body, "requests-2.32.0.tar.gz") {
+		t.Errorf("Expected the newly affected release to be hidden after the reload, got %s", body)
+	}
+}
+
+func TestProxyFileDigests(t *testing.T) {
+	cfg := &config.Config{
+		PublicPyPIURL:  "https://pypi.org/simple/",
+		PrivatePyPIURL: "https://private.example.com/simple/",
+		Port:           8080,
+		CacheEnabled:   false,
+		CacheSize:      100,
+		CacheTTL:       1,
+		RequireHashes:  true,
+	}
+
+	proxyInstance, err := NewProxy(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	digest := strings.Repeat("a", 64)
+	mockClient := NewMockPyPIClient()
+	proxyInstance.client = mockClient
+	mockClient.tampered = map[string]bool{"https://private.example.com/packages/internal-1.1.tar.gz": true}
+	mockClient.privateExists["internal"] = true
+	mockClient.pages[cfg.PrivatePyPIURL] = map[string]string{
+		"internal": `<a href="https://private.example.com/packages/internal-1.0.tar.gz#sha256=` + digest + `">internal-1.0.tar.gz</a>` +
+			`<a href="https://private.example.com/packages/internal-1.1.tar.gz#sha256=` + digest + `">internal-1.1.tar.gz</a>` +
+			`<a href="https://private.example.com/packages/internal-1.2.tar.gz">internal-1.2.tar.gz</a>`,
+	}
+
+	req := httptest.NewRequest("GET", "/simple/internal/", http.NoBody)
+	rr := httptest.NewRecorder()
+	proxyInstance.HandlePackage(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if strings.Contains(rr.Body.String(), "internal-1.2.tar.gz") {
+		t.Errorf("Expected the file without a hash to be hidden, got %s", rr.Body.String())
+	}
+	if !strings.Contains(rr.Body.String(), "internal-1.0.tar.gz#sha256="+digest) {
+		t.Errorf("Expected the hashed file to be listed, got %s", rr.Body.String())
+	}
+
+	// Files hidden for lack of a hash are refused when requested directly
+	for _, path := range []string{"/internal-1.2.tar.gz", "/packages/private/packages/internal-1.2.tar.gz"} {
+		rr = httptest.NewRecorder()
+		proxyInstance.HandleFile(rr, httptest.NewRequest("GET", path, http.NoBody))
+		if rr.Code != http.StatusForbidden {
+			t.Errorf("%s: expected status 403, got %d", path, rr.Code)
+		}
+	}
+	rr = httptest.NewRecorder()
+	proxyInstance.HandleFile(rr, httptest.NewRequest("GET", "/internal-1.2.tar.gz.metadata", http.NoBody))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected metadata of an unhashed file to be hidden, got %d", rr.Code)
+	}
+	if len(mockClient.proxiedURLs) != 0 {
+		t.Errorf("Expected no downloads of unhashed files, got %v", mockClient.proxiedURLs)
+	}
+
+	req = httptest.NewRequest("GET", "/packages/private/packages/internal-1.0.tar.gz", http.NoBody)
+	rr = httptest.NewRecorder()
+	proxyInstance.HandleFile(rr, req)
+	if rr.Code != http.StatusOK || mockClient.proxiedDigests[0] != digest {
+		t.Errorf("Expected a verified download, got %d with digests %v", rr.Code, mockClient.proxiedDigests)
+	}
+
+	defer func() {
+		if recovered := recover(); recovered != http.ErrAbortHandler {
+			t.Errorf("Expected a tampered download to abort the connection, got %v", recovered)
+		}
+	}()
+	req = httptest.NewRequest("GET", "/packages/private/packages/internal-1.1.tar.gz", http.NoBody)
+	proxyInstance.HandleFile(httptest.NewRecorder(), req)
+}
+
+func TestProxyLockMode(t *testing.T) {
+	digest := strings.Repeat("b", 64)
+	lockPath := filepath.Join(t.TempDir(), "requirements.txt")
+	lockContent := "requests==2.32.3 --hash=sha256:" + digest + "\n"
+	if err := os.WriteFile(lockPath, []byte(lockContent), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		PublicPyPIURL:  "https://pypi.org/simple/",
+		PrivatePyPIURL: "https://private.example.com/simple/",
+		Port:           8080,
+		CacheEnabled:   false,
+		CacheSize:      100,
+		CacheTTL:       1,
+		AdminToken:     "secret",
+		LockFiles:      []string{lockPath},
+	}
+
+	proxyInstance, err := NewProxy(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	mockClient := NewMockPyPIClient()
+	proxyInstance.client = mockClient
+	mockClient.publicExists["requests"] = true
+	mockClient.publicExists["urllib3"] = true
+	mockClient.pages[cfg.PublicPyPIURL] = map[string]string{
+		"requests": `<a href="https://files.pythonhosted.org/packages/requests-2.32.2.tar.gz#sha256=` + digest + `">requests-2.32.2.tar.gz</a>` +
+			`<a href="https://files.pythonhosted.org/packages/requests-2.32.3.tar.gz#sha256=` + digest + `">requests-2.32.3.tar.gz</a>` +
+			`<a href="https://files.pythonhosted.org/packages/requests-2.32.3-py3-none-any.whl#sha256=` + strings.Repeat("c", 64) + `">requests-2.32.3-py3-none-any.whl</a>`,
+	}
+
+	req := httptest.NewRequest("GET", "/simple/requests/", http.NoBody)
+	rr := httptest.NewRecorder()
+	proxyInstance.HandlePackage(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	body := rr.Body.String()
+	if !strings.Contains(body, "requests-2.32.3.tar.gz") {
+		t.Errorf("Expected the pinned sdist on the page, got %s", body)
+	}
+	if strings.Contains(body, "requests-2.32.2.tar.gz") || strings.Contains(body, "requests-2.32.3-py3-none-any.whl") {
+		t.Errorf("Expected unpinned files to be hidden, got %s", body)
+	}
+
+	req = httptest.NewRequest("GET", "/simple/urllib3/", http.NoBody)
+	rr = httptest.NewRecorder()
+	proxyInstance.HandlePackage(rr, req)
+	if rr.Code != http.StatusNotFound || !strings.Contains(rr.Body.String(), "requirements.txt") {
+		t.Errorf("Expected a 404 naming the lock, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if mockClient.publicCalls["urllib3"] != 0 {
+		t.Error("Expected no upstream lookup for a package outside the lock")
+	}
+
+	downloads := []struct {
+		path   string
+		status int
+	}{
+		{"/packages/public/packages/requests-2.32.3.tar.gz", http.StatusOK},
+		{"/packages/public/packages/requests-2.32.2.tar.gz", http.StatusForbidden},
+		{"/packages/public/packages/requests-2.32.3-py3-none-any.whl", http.StatusForbidden},
+	}
+	for _, tt := range downloads {
+		req := httptest.NewRequest("GET", tt.path, http.NoBody)
+		rr := httptest.NewRecorder()
+		proxyInstance.HandleFile(rr, req)
+		if rr.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d: %s", tt.path, tt.status, rr.Code, rr.Body.String())
+		}
+	}
+
+	req = httptest.NewRequest("GET", "/admin/lock/report", http.NoBody)
+	req.Header.Set("Authorization", "Bearer secret")
+	rr = httptest.NewRecorder()
+	proxyInstance.HandleLockReport(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	for _, expected := range []string{`"package": "urllib3"`, "requests-2.32.2.tar.gz", "requests-2.32.3-py3-none-any.whl"} {
+		if !strings.Contains(rr.Body.String(), expected) {
+			t.Errorf("Expected %s in the report, got %s", expected, rr.Body.String())
+		}
+	}
+}
+
+func TestLockReportIsBounded(t *testing.T) {
+	report, err := newLockReport(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report.record("first", "", "not pinned", "10.0.0.1")
+	report.record("second", "", "not pinned", "10.0.0.1")
+	report.record("first", "", "not pinned", "10.0.0.2")
+	report.record("third", "", "not pinned", "10.0.0.1")
+
+	violations, dropped := report.snapshot()
+	if dropped != 1 {
+		t.Errorf("Expected one dropped violation, got %d", dropped)
+	}
+	if len(violations) != 2 || violations[0].Package != "first" || violations[0].Count != 2 || violations[1].Package != "third" {
+		t.Errorf("Expected the least recently seen violation to be dropped, got %+v", violations)
+	}
+}
+
+func TestProxyYanks(t *testing.T) {
+	cfg := &config.Config{
+		PublicPyPIURL:  "https://pypi.org/simple/",
+		PrivatePyPIURL: "https://private.example.com/simple/",
+		Port:           8080,
+		CacheEnabled:   true,
+		CacheSize:      100,
+		CacheTTL:       1,
+		AdminToken:     "secret",
+		Yanks: []config.YankEntry{
+			{Package: "requests", Versions: "==2.32.0", Reason: "breaks our TLS setup"},
+		},
+	}
+
+	proxyInstance, err := NewProxy(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	mockClient := NewMockPyPIClient()
+	proxyInstance.client = mockClient
+	mockClient.publicExists["requests"] = true
+	mockClient.pages[cfg.PublicPyPIURL] = map[string]string{
+		"requests": `<a href="https://files.pythonhosted.org/packages/requests-2.31.0.tar.gz" data-yanked="upstream reason">requests-2.31.0.tar.gz</a>` +
+			`<a href="https://files.pythonhosted.org/packages/requests-2.32.0.tar.gz">requests-2.32.0.tar.gz</a>` +
+			`<a href="https://files.pythonhosted.org/packages/requests-2.32.3.tar.gz">requests-2.32.3.tar.gz</a>`,
+	}
+
+	getJSON := func() map[string]any {
+		req := httptest.NewRequest("GET", "/simple/requests/", http.NoBody)
+		req.Header.Set("Accept", pypi.ContentTypeJSON)
+		rr := httptest.NewRecorder()
+		proxyInstance.HandlePackage(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		yanked := make(map[string]any)
+		var page struct {
+			Files []struct {
+				Filename string `json:"filename"`
+				Yanked   any    `json:"yanked"`
+			} `json:"files"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &page); err != nil {
+			t.Fatalf("Expected a JSON page, got %v", err)
+		}
+		for _, f := range page.Files {
+			yanked[f.Filename] = f.Yanked
+		}
+		return yanked
+	}
+	admin := func(method string, body string) int {
+		req := httptest.NewRequest(method, "/admin/yanks", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		rr := httptest.NewRecorder()
+		proxyInstance.HandleYanks(rr, req)
+		return rr.Code
+	}
+
+	yanked := getJSON()
+	if yanked["requests-2.31.0.tar.gz"] != "upstream reason" {
+		t.Errorf("Expected the upstream yank to be preserved, got %v", yanked["requests-2.31.0.tar.gz"])
+	}
+	if yanked["requests-2.32.0.tar.gz"] != "breaks our TLS setup" {
+		t.Errorf("Expected the configured yank, got %v", yanked["requests-2.32.0.tar.gz"])
+	}
+	if yanked["requests-2.32.3.tar.gz"] != false {
+		t.Errorf("Expected 2.32.3 not to be yanked, got %v", yanked["requests-2.32.3.tar.gz"])
+	}
+
+	if code := admin("POST", `{"filename": "requests-2.32.3.tar.gz", "reason": "pending review"}`); code != http.StatusOK {
+		t.Fatalf("Expected the yank to be added, got %d", code)
+	}
+	if code := admin("POST", `{"package": "requests"}`); code != http.StatusBadRequest {
+		t.Errorf("Expected a yank without reason to be rejected, got %d", code)
+	}
+	if yanked := getJSON(); yanked["requests-2.32.3.tar.gz"] != "pending review" {
+		t.Errorf("Expected the admin yank on the cached page, got %v", yanked["requests-2.32.3.tar.gz"])
+	}
+
+	if code := admin("DELETE", `{"package": "requests", "versions": "==2.32.0"}`); code != http.StatusOK {
+		t.Fatalf("Expected the configured yank to be removed, got %d", code)
+	}
+	if yanked := getJSON(); yanked["requests-2.32.0.tar.gz"] != false {
+		t.Errorf("Expected the removed yank to be lifted, got %v", yanked["requests-2.32.0.tar.gz"])
+	}
+	if code := admin("DELETE", `{"package": "requests", "versions": "==2.32.0"}`); code != http.StatusNotFound {
+		t.Errorf("Expected a missing yank to return 404, got %d", code)
+	}
+
+	req := httptest.NewRequest("GET", "/admin/yanks", http.NoBody)
+	rr := httptest.NewRecorder()
+	proxyInstance.HandleYanks(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected unauthenticated admin calls to be refused, got %d", rr.Code)
+	}
+}
+
+func TestProxyProjectJSON(t *testing.T) {
+	cfg := &config.Config{
+		PublicPyPIURL:  "https://pypi.org/simple/",
+		PrivatePyPIURL: "https://private.example.com/simple/",
+		Port:           8080,
+		CacheEnabled:   true,
+		CacheSize:      100,
+		CacheTTL:       1,
+		Blocklist: []config.BlockEntry{
+			{Package: "requests", Versions: "==2.32.0", Reason: "regression"},
+		},
+	}
+
+	proxyInstance, err := NewProxy(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	mockClient := NewMockPyPIClient()
+	proxyInstance.client = mockClient
+	mockClient.publicExists["requests"] = true
+	mockClient.privateExists["internal-tool"] = true
+	mockClient.privateExists["internal-lib"] = true
+	// The private index serves its JSON API next to its simple API
+	mockClient.projectJSON["https://private.example.com/pypi/"] = map[string]string{
+		"internal-tool": `{"info": {"name": "internal-tool", "version": "1.0"}, "urls": [
+			{"filename": "internal_tool-1.0-py3-none-any.whl", "url": "https://private.example.com/packages/internal_tool-1.0-py3-none-any.whl"}
+		]}`,
+	}
+	mockClient.projectJSON["https://pypi.org/pypi/"] = map[string]string{
+		"requests": `{"info": {"name": "requests", "version": "2.32.3"},
+			"urls": [
+				{"filename": "requests-2.32.3.tar.gz", "url": "https://files.pythonhosted.org/packages/ab/requests-2.32.3.tar.gz", "digests": {"sha256": "abc"}, "size": 131218, "yanked": false, "yanked_reason": null},
+				{"filename": "requests-2.32.3-py3-none-any.whl", "url": "https://files.pythonhosted.org/packages/cd/requests-2.32.3-py3-none-any.whl", "digests": {"sha256": "def"}, "yanked": false, "yanked_reason": null}
+			],
+			"releases": {
+				"2.31.0": [{"filename": "requests-2.31.0.tar.gz", "url": "https://files.pythonhosted.org/packages/ef/requests-2.31.0.tar.gz", "yanked": true, "yanked_reason": "upstream reason"}],
+				"2.32.0": [{"filename": "requests-2.32.0.tar.gz", "url": "https://files.pythonhosted.org/packages/01/requests-2.32.0.tar.gz"}],
+				"2.32.3": [
+					{"filename": "requests-2.32.3.tar.gz", "url": "https://files.pythonhosted.org/packages/ab/requests-2.32.3.tar.gz", "digests": {"sha256": "abc"}},
+					{"filename": "requests-2.32.3-py3-none-any.whl", "url": "https://files.pythonhosted.org/packages/cd/requests-2.32.3-py3-none-any.whl", "digests": {"sha256": "def"}}
+				],
+				"3.0.0a1": []
+			},
+			"vulnerabilities": []}`,
+	}
+
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, http.NoBody)
+		rr := httptest.NewRecorder()
+		proxyInstance.HandleProjectJSON(rr, req)
+		return rr
+	}
+
+	rr := get("/pypi/requests/json")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if source := rr.Header().Get(pypi.ResponseHeaderSource); source != cfg.PublicPyPIURL {
+		t.Errorf("Expected source %s, got %s", cfg.PublicPyPIURL, source)
+	}
+
+	type jsonFile struct {
+		Filename     string  `json:"filename"`
+		URL          string  `json:"url"`
+		Size         int64   `json:"size"`
+		Yanked       bool    `json:"yanked"`
+		YankedReason *string `json:"yanked_reason"`
+	}
+	var project struct {
+		Info            map[string]any        `json:"info"`
+		URLs            []jsonFile            `json:"urls"`
+		Releases        map[string][]jsonFile `json:"releases"`
+		Vulnerabilities []any                 `json:"vulnerabilities"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &project); err != nil {
+		t.Fatalf("Expected a JSON response, got %v", err)
+	}
+
+	if project.Info["version"] != "2.32.3" || project.Vulnerabilities == nil {
+		t.Errorf("Expected other fields to be passed through, got %s", rr.Body.String())
+	}
+	if len(project.URLs) != 1 || project.URLs[0].Filename != "requests-2.32.3.tar.gz" {
+		t.Fatalf("Expected public wheels to be filtered from urls, got %+v", project.URLs)
+	}
+	if project.URLs[0].URL != "http://example.com/packages/public/packages/ab/requests-2.32.3.tar.gz" {
+		t.Errorf("Expected the link to point at the proxy, got %s", project.URLs[0].URL)
+	}
+	if project.URLs[0].Size != 131218 {
+		t.Errorf("Expected the size to be passed through, got %d", project.URLs[0].Size)
+	}
+	if _, ok := project.Releases["2.32.0"]; ok {
+		t.Error("Expected the blocked release to be dropped")
+	}
+	if files, ok := project.Releases["3.0.0a1"]; !ok || len(files) != 0 {
+		t.Errorf("Expected the release without files to be kept, got %+v", files)
+	}
+	if files := project.Releases["2.31.0"]; len(files) != 1 || !files[0].Yanked ||
+		files[0].YankedReason == nil || *files[0].YankedReason != "upstream reason" {
+		t.Errorf("Expected the upstream yank to be kept, got %+v", files)
+	}
+
+	// Served links are remembered, so downloads go to the listed upstream URL
+	fileReq := httptest.NewRequest("GET", "/packages/public/packages/ab/requests-2.32.3.tar.gz", http.NoBody)
+	proxyInstance.HandleFile(httptest.NewRecorder(), fileReq)
+	if len(mockClient.proxiedURLs) != 1 || mockClient.proxiedURLs[0] != "https://files.pythonhosted.org/packages/ab/requests-2.32.3.tar.gz" {
+		t.Errorf("Expected the upstream URL to be proxied, got %v", mockClient.proxiedURLs)
+	}
+	if len(mockClient.proxiedDigests) != 1 || mockClient.proxiedDigests[0] != "abc" {
+		t.Errorf("Expected the JSON API digest to be verified, got %v", mockClient.proxiedDigests)
+	}
+
+	// Responses are cached per index
+	get("/pypi/requests/json")
+	if calls := mockClient.projectJSONCalls["https://pypi.org/pypi/requests"]; calls != 1 {
+		t.Errorf("Expected 1 JSON API call, got %d", calls)
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		expected int
+	}{
+		{"non-canonical name", "/pypi/Requests/2.32.3/json", http.StatusMovedPermanently},
+		{"missing release", "/pypi/requests/9.9.9/json", http.StatusNotFound},
+		{"unknown package", "/pypi/unknown/json", http.StatusNotFound},
+		{"private package", "/pypi/internal-tool/json", http.StatusOK},
+		{"private package missing from the JSON API", "/pypi/internal-lib/json", http.StatusNotFound},
+		{"invalid path", "/pypi/requests", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rr := get(tt.path); rr.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d: %s", tt.expected, rr.Code, rr.Body.String())
+			}
+		})
+	}
+	if calls := mockClient.projectJSONCalls["https://pypi.org/pypi/internal-tool"]; calls != 0 {
+		t.Errorf("Expected private packages never to be looked up publicly, got %d calls", calls)
+	}
+	rr = get("/pypi/internal-tool/json")
+	if source := rr.Header().Get(pypi.ResponseHeaderSource); source != cfg.PrivatePyPIURL {
+		t.Errorf("Expected source %s, got %s", cfg.PrivatePyPIURL, source)
+	}
+	if !strings.Contains(rr.Body.String(), "http://example.com/packages/private/packages/internal_tool-1.0-py3-none-any.whl") {
+		t.Errorf("Expected private wheels to be served through the proxy, got %s", rr.Body.String())
+	}
+}
+
+// TestProxyMergedProjectJSON tests that the JSON API of merged packages lists
+// the files of every index, as their pages do.
+func TestProxyMergedProjectJSON(t *testing.T) {
+	cfg := &config.Config{
+		PublicPyPIURL:  "https://pypi.org/simple/",
+		PrivatePyPIURL: "https://private.example.com/simple/",
+		Port:           8080,
+		CacheEnabled:   false,
+		CacheSize:      100,
+		CacheTTL:       1,
+		MergePackages:  []string{"demo"},
+	}
+
+	proxyInstance, err := NewProxy(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	mockClient := NewMockPyPIClient()
+	proxyInstance.client = mockClient
+	mockClient.publicExists["demo"] = true
+	mockClient.privateExists["demo"] = true
+	mockClient.projectJSON["https://private.example.com/pypi/"] = map[string]string{
+		"demo": `{"info": {"name": "demo", "version": "1.0"},
+			"urls": [{"filename": "demo-1.0.tar.gz", "url": "https://private.example.com/packages/demo-1.0.tar.gz", "digests": {"sha256": "private"}}],
+			"releases": {"1.0": [{"filename": "demo-1.0.tar.gz", "url": "https://private.example.com/packages/demo-1.0.tar.gz", "digests": {"sha256": "private"}}]}}`,
+	}
+	mockClient.projectJSON["https://pypi.org/pypi/"] = map[string]string{
+		"demo": `{"info": {"name": "demo", "version": "2.0"},
+			"urls": [{"filename": "demo-2.0.tar.gz", "url": "https://files.pythonhosted.org/packages/bb/demo-2.0.tar.gz", "digests": {"sha256": "newer"}}],
+			"releases": {
+				"1.0": [{"filename": "demo-1.0.tar.gz", "url": "https://files.pythonhosted.org/packages/aa/demo-1.0.tar.gz", "digests": {"sha256": "public"}}],
+				"2.0": [
+					{"filename": "demo-2.0.tar.gz", "url": "https://files.pythonhosted.org/packages/bb/demo-2.0.tar.gz", "digests": {"sha256": "newer"}},
+					{"filename": "demo-2.0-py3-none-any.whl", "url": "https://files.pythonhosted.org/packages/cc/demo-2.0-py3-none-any.whl", "digests": {"sha256": "pubwheel"}}
+				]
+			}}`,
+	}
+
+	rr := httptest.NewRecorder()
+	proxyInstance.HandleProjectJSON(rr, httptest.NewRequest("GET", "/pypi/demo/json", http.NoBody))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if source := rr.Header().Get(pypi.ResponseHeaderSource); source != cfg.PrivatePyPIURL+", "+cfg.PublicPyPIURL {
+		t.Errorf("Expected both indexes in source header, got %s", source)
+	}
+
+	type jsonFile struct {
+		Filename string `json:"filename"`
+		URL      string `json:"url"`
+	}
+	var project struct {
+		Info     map[string]any        `json:"info"`
+		URLs     []jsonFile            `json:"urls"`
+		Releases map[string][]jsonFile `json:"releases"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &project); err != nil {
+		t.Fatalf("Expected a JSON response, got %v", err)
+	}
+
+	// Private files win on collisions, and public wheels are still filtered
+	if files := project.Releases["1.0"]; len(files) != 1 || files[0].URL != "http://example.com/packages/private/packages/demo-1.0.tar.gz" {
+		t.Errorf("Expected the private file of 1.0, got %+v", files)
+	}
+	if files := project.Releases["2.0"]; len(files) != 1 || files[0].Filename != "demo-2.0.tar.gz" {
+		t.Errorf("Expected the public sdist of 2.0, got %+v", files)
+	}
+	if project.Info["version"] != "1.0" || len(project.URLs) != 1 || project.URLs[0].Filename != "demo-1.0.tar.gz" {
+		t.Errorf("Expected info and urls of the private index, got %v and %+v", project.Info, project.URLs)
+	}
+
+	// A release only one index has is served from that index
+	rr = httptest.NewRecorder()
+	mockClient.projectJSON["https://pypi.org/pypi/"]["demo/2.0"] = `{"info": {"name": "demo", "version": "2.0"},
+		"urls": [{"filename": "demo-2.0.tar.gz", "url": "https://files.pythonhosted.org/packages/bb/demo-2.0.tar.gz"}]}`
+	proxyInstance.HandleProjectJSON(rr, httptest.NewRequest("GET", "/pypi/demo/2.0/json", http.NoBody))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "demo-2.0.tar.gz") {
+		t.Errorf("Expected the public release, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if source := rr.Header().Get(pypi.ResponseHeaderSource); source != cfg.PublicPyPIURL {
+		t.Errorf("Expected the public index in source header, got %s", source)
+	}
+}
+
+// uploadRequest builds a twine-style upload request for a file.
+func uploadRequest(t *testing.T, token, name, fileName, content, digest string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fields := map[string]string{":action": pypi.UploadAction, "name": name, "version": "1.0", "sha256_digest": digest}
+	for field, value := range fields {
+		if err := mw.WriteField(field, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	part, err := mw.CreateFormFile("content", fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := part.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("POST", "/legacy/", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	if token != "" {
+		req.SetBasicAuth("__token__", token)
+	}
+	return req
+}
+
+func TestProxyUpload(t *testing.T) {
+	sum := sha256.Sum256([]byte("sdist"))
+	digest := hex.EncodeToString(sum[:])
+
+	newProxy := func(upload config.UploadConfig) (*Proxy, *MockPyPIClient) {
+		cfg := &config.Config{
+			PublicPyPIURL:  "https://pypi.org/simple/",
+			PrivatePyPIURL: "https://private.example.com/simple/",
+			Port:           8080,
+			CacheEnabled:   true,
+			CacheSize:      100,
+			CacheTTL:       1,
+			RootListingTTL: 60,
+			Upload:         upload,
+		}
+		proxyInstance, err := NewProxy(cfg)
+		if err != nil {
+			t.Fatalf("Failed to create proxy: %v", err)
+		}
+		mockClient := NewMockPyPIClient()
+		proxyInstance.client = mockClient
+		return proxyInstance, mockClient
+	}
+	upload := func(proxyInstance *Proxy, req *http.Request) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		proxyInstance.HandleUpload(rr, req)
+		return rr
+	}
+
+	disabled, _ := newProxy(config.UploadConfig{})
+	if rr := upload(disabled, uploadRequest(t, "secret", "demo", "demo-1.0.tar.gz", "sdist", digest)); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 with uploads disabled, got %d", rr.Code)
+	}
+
+	proxyInstance, mockClient := newProxy(config.UploadConfig{URL: "https://private.example.com/legacy/", Token: "secret"})
+	proxyInstance.cache.SetPrivatePackage("demo", false)
+	proxyInstance.cache.SetPrivatePackagePage("demo", []byte("<html></html>"))
+	listing := func() string {
+		rr := httptest.NewRecorder()
+		proxyInstance.HandleSimpleIndex(rr, httptest.NewRequest("GET", "/simple/", http.NoBody))
+		return rr.Body.String()
+	}
+	if body := listing(); strings.Contains(body, "demo") {
+		t.Fatalf("Expected no demo project before the upload, got %s", body)
+	}
+
+	tests := []struct {
+		name     string
+		req      *http.Request
+		expected int
+	}{
+		{"no credentials", uploadRequest(t, "", "demo", "demo-1.0.tar.gz", "sdist", digest), http.StatusUnauthorized},
+		{"wrong token", uploadRequest(t, "wrong", "demo", "demo-1.0.tar.gz", "sdist", digest), http.StatusForbidden},
+		{"file of another project", uploadRequest(t, "secret", "demo", "other-1.0.tar.gz", "sdist", digest), http.StatusBadRequest},
+		{"file of a project with the name as prefix", uploadRequest(t, "secret", "demo", "demo-bar-1.0.tar.gz", "sdist", digest), http.StatusBadRequest},
+		{"file of another version", uploadRequest(t, "secret", "demo", "demo-2.0.tar.gz", "sdist", digest), http.StatusBadRequest},
+		{"digest mismatch", uploadRequest(t, "secret", "demo", "demo-1.0.tar.gz", "tampered", digest), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rr := upload(proxyInstance, tt.req); rr.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d: %s", tt.expected, rr.Code, rr.Body.String())
+			}
+		})
+	}
+	if len(mockClient.uploads) != 0 {
+		t.Fatalf("Expected refused uploads not to be forwarded, got %v", mockClient.uploads)
+	}
+
+	mockClient.projects["https://private.example.com/simple/"] = []string{"demo"}
+	rr := upload(proxyInstance, uploadRequest(t, "secret", "Demo", "demo-1.0.tar.gz", "sdist", digest))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if len(mockClient.uploads) != 1 || mockClient.uploads[0] != "demo-1.0.tar.gz" {
+		t.Errorf("Expected the file to be forwarded, got %v", mockClient.uploads)
+	}
+	if info, found := proxyInstance.cache.GetPrivatePackage("demo"); !found || !info.Exists {
+		t.Error("Expected the package to exist in the private index after the upload")
+	}
+	if _, found := proxyInstance.cache.GetPrivatePackagePage("demo"); found {
+		t.Error("Expected the private page to be invalidated after the upload")
+	}
+	if body := listing(); !strings.Contains(body, `<a href="demo/">demo</a>`) {
+		t.Errorf("Expected the root listing to be refreshed after the upload, got %s", body)
+	}
+
+	mockClient.uploadErr = &pypi.UploadError{StatusCode: http.StatusBadRequest, Message: "File already exists"}
+	rr = upload(proxyInstance, uploadRequest(t, "secret", "demo", "demo-1.0.tar.gz", "sdist", digest))
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "File already exists") {
+		t.Errorf("Expected the upstream rejection to be passed through, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// Without an upload URL, uploads are stored in a local upload index,
+	// which serves them like any other index of the chain
+	public := httptest.NewServer(http.NotFoundHandler())
+	defer public.Close()
+	storageDir := t.TempDir()
+	local, err := NewProxy(&config.Config{
+		PublicPyPIURL:  public.URL + "/simple/",
+		PrivatePyPIURL: "file://" + filepath.ToSlash(storageDir) + "/",
+		Port:           8080,
+		CacheEnabled:   true,
+		CacheSize:      100,
+		CacheTTL:       1,
+		RootListingTTL: 60,
+		Upload:         config.UploadConfig{Token: "secret"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+	if rr := upload(local, uploadRequest(t, "secret", "demo", "demo-1.0.tar.gz", "sdist", digest)); rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	stored, err := os.ReadFile(filepath.Join(storageDir, "demo", "demo-1.0.tar.gz"))
+	if err != nil || string(stored) != "sdist" {
+		t.Errorf("Expected the file to be stored, got %q (err=%v)", stored, err)
+	}
+	if rr := upload(local, uploadRequest(t, "secret", "demo", "demo-1.0.tar.gz", "sdist", digest)); rr.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for an existing file, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	local.HandlePackage(rr, httptest.NewRequest("GET", "/simple/demo/", http.NoBody))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "#sha256="+digest) {
+		t.Fatalf("Expected the stored file to be listed with its digest, got %d: %s", rr.Code, rr.Body.String())
+	}
+	files, err := parsePage(rr.Body.Bytes())
+	if err != nil || len(files) != 1 {
+		t.Fatalf("Expected one listed file, got %v (err=%v)", files, err)
+	}
+	href, _, _ := strings.Cut(files[0].URL, "#")
+	rr = httptest.NewRecorder()
+	local.HandleFile(rr, httptest.NewRequest("GET", href, http.NoBody))
+	if rr.Code != http.StatusOK || rr.Body.String() != "sdist" {
+		t.Errorf("Expected the stored file to be served, got %d: %q", rr.Code, rr.Body.String())
+	}
+}
+
+func TestProxyFileCache(t *testing.T) {
+	cfg := &config.Config{
+		PublicPyPIURL:  "https://pypi.org/simple/",
+		PrivatePyPIURL: "https://private.example.com/simple/",
+		Port:           8080,
+		CacheEnabled:   true,
+		CacheSize:      100,
+		CacheTTL:       1,
+		FileCacheDir:   t.TempDir(),
+	}
+
+	proxyInstance, err := NewProxy(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	mockClient := NewMockPyPIClient()
+	proxyInstance.client = mockClient
+	sum := sha256.Sum256([]byte("mock file content"))
+	digest := hex.EncodeToString(sum[:])
+	mockClient.privateExists["demo"] = true
+	mockClient.pages[cfg.PrivatePyPIURL] = map[string]string{
+		"demo": `<a href="https://files.example.com/demo-1.0.tar.gz#sha256=` + digest + `">demo-1.0.tar.gz</a>` +
+			`<a href="https://files.example.com/demo-1.1.tar.gz#sha256=` + strings.Repeat("0", 64) + `">demo-1.1.tar.gz</a>`,
+	}
+
+	download := func(method, fileName string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/"+fileName, http.NoBody)
+		for name, value := range header {
+			req.Header.Set(name, value)
+		}
+		rr := httptest.NewRecorder()
+		proxyInstance.HandleFile(rr, req)
+		return rr
+	}
+
+	// A ranged request for an uncached file fills the cache with the whole
+	// file, and the range is served from the fill
+	rr := download("GET", "demo-1.0.tar.gz", map[string]string{"Range": "bytes=5-"})
+	etag := `"sha256:` + digest + `"`
+	if rr.Code != http.StatusPartialContent || rr.Body.String() != "file content" || rr.Header().Get("ETag") != etag {
+		t.Fatalf("Expected the range of the verified file, got %d %v: %q", rr.Code, rr.Header(), rr.Body.String())
+	}
+	if len(mockClient.fetchedURLs) != 1 || len(mockClient.proxiedURLs) != 0 {
+		t.Fatalf("Expected the whole file to be fetched once, got %v and %v", mockClient.fetchedURLs, mockClient.proxiedURLs)
+	}
+
+	rr = download("GET", "demo-1.0.tar.gz", nil)
+	if rr.Code != http.StatusOK || rr.Body.String() != "mock file content" {
+		t.Fatalf("Expected the whole file, got %d: %q", rr.Code, rr.Body.String())
+	}
+	rr = download("GET", "demo-1.0.tar.gz", map[string]string{"Range": "bytes=5-", "If-Range": etag})
+	if rr.Code != http.StatusPartialContent {
+		t.Errorf("Expected a matching If-Range to resume, got %d", rr.Code)
+	}
+	rr = download("GET", "demo-1.0.tar.gz", map[string]string{"Range": "bytes=5-", "If-Range": `"other"`})
+	if rr.Code != http.StatusOK || rr.Body.String() != "mock file content" {
+		t.Errorf("Expected a stale If-Range to get the whole file, got %d: %q", rr.Code, rr.Body.String())
+	}
+	rr = download("GET", "demo-1.0.tar.gz", map[string]string{"Range": "bytes=100-"})
+	if rr.Code != http.StatusRequestedRangeNotSatisfiable {
+		t.Errorf("Expected status 416, got %d", rr.Code)
+	}
+	rr = download("HEAD", "demo-1.0.tar.gz", nil)
+	if rr.Code != http.StatusOK || rr.Header().Get("ETag") != etag {
+		t.Errorf("Expected HEAD from the cache with the ETag, got %d %v", rr.Code, rr.Header())
+	}
+	if len(mockClient.fetchedURLs) != 1 || len(mockClient.proxiedURLs) != 0 {
+		t.Errorf("Expected cached downloads not to reach upstream, got %v and %v", mockClient.fetchedURLs, mockClient.proxiedURLs)
+	}
+
+	// Bodies that don't match their digest are never cached, nor served
+	for _, header := range []map[string]string{nil, {"Range": "bytes=5-"}} {
+		rr = download("GET", "demo-1.1.tar.gz", header)
+		if rr.Code != http.StatusBadGateway {
+			t.Errorf("Expected a tampered file to be refused, got %d: %q", rr.Code, rr.Body.String())
+		}
+	}
+	if len(mockClient.fetchedURLs) != 3 {
+		t.Errorf("Expected unverified files to be downloaded again, got %v", mockClient.fetchedURLs)
+	}
+
+	// Requests during a download read from the same fill, and ranges are
+	// served as soon as their bytes arrived
+	mockClient.fetchGate = make(chan struct{})
+	if err := os.RemoveAll(cfg.FileCacheDir); err != nil {
+		t.Fatal(err)
+	}
+	whole := make(chan *httptest.ResponseRecorder)
+	go func() {
+		whole <- download("GET", "demo-1.0.tar.gz", nil)
+	}()
+	<-mockClient.fetchGate
+	ranged := make(chan *httptest.ResponseRecorder)
+	go func() {
+		ranged <- download("GET", "demo-1.0.tar.gz", map[string]string{"Range": "bytes=0-3"})
+	}()
+	select {
+	case rr = <-ranged:
+		if rr.Code != http.StatusPartialContent || rr.Body.String() != "mock" {
+			t.Errorf("Expected the range before the download completed, got %d: %q", rr.Code, rr.Body.String())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the range to be served before the download completed")
+	}
+	mockClient.fetchGate <- struct{}{}
+	if rr = <-whole; rr.Code != http.StatusOK || rr.Body.String() != "mock file content" {
+		t.Errorf("Expected the whole file, got %d: %q", rr.Code, rr.Body.String())
+	}
+	download("GET", "demo-1.0.tar.gz", nil)
+	if len(mockClient.fetchedURLs) != 4 {
+		t.Errorf("Expected one download for all requests, got %v", mockClient.fetchedURLs)
+	}
+}
+
+// TestProxyFileRangeWithoutCache tests that ranges are forwarded upstream without a file cache.
+func TestProxyFileRangeWithoutCache(t *testing.T) {
+	content := "mock file content"
+	sum := sha256.Sum256([]byte(content))
+	digest := hex.EncodeToString(sum[:])
+
+	var mu sync.Mutex
+	var forwarded []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/simple/") {
+			_, _ = w.Write([]byte(`<a href="/files/demo-1.0.tar.gz#sha256=` + digest + `">demo-1.0.tar.gz</a>`))
+			return
+		}
+		mu.Lock()
+		forwarded = append(forwarded, r.Header.Get("Range")+"|"+r.Header.Get("If-Range"))
+		mu.Unlock()
+		w.Header().Set("ETag", `"upstream"`)
+		http.ServeContent(w, r, "demo-1.0.tar.gz", time.Time{}, strings.NewReader(content))
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{
+		PublicPyPIURL:  upstream.URL + "/public/",
+		PrivatePyPIURL: upstream.URL + "/simple/",
+		Port:           8080,
+		CacheEnabled:   true,
+		CacheSize:      100,
+		CacheTTL:       1,
+	}
+	proxyInstance, err := NewProxy(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	rr := httptest.NewRecorder()
+	proxyInstance.HandlePackage(rr, httptest.NewRequest("GET", "/simple/demo/", http.NoBody))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	download := func(header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/demo-1.0.tar.gz", http.NoBody)
+		for name, value := range header {
+			req.Header.Set(name, value)
+		}
+		rr := httptest.NewRecorder()
+		proxyInstance.HandleFile(rr, req)
+		return rr
+	}
+
+	etag := `"sha256:` + digest + `"`
+	rr = download(map[string]string{"Range": "bytes=5-"})
+	if rr.Code != http.StatusPartialContent || rr.Body.String() != "file content" || rr.Header().Get("ETag") != etag {
+		t.Errorf("Expected the upstream range with the ETag of the digest, got %d %v: %q", rr.Code, rr.Header(), rr.Body.String())
+	}
+	rr = download(map[string]string{"Range": "bytes=5-", "If-Range": etag})
+	if rr.Code != http.StatusPartialContent || rr.Body.String() != "file content" {
+		t.Errorf("Expected a matching If-Range to resume, got %d: %q", rr.Code, rr.Body.String())
+	}
+	rr = download(map[string]string{"Range": "bytes=5-", "If-Range": `"sha256:` + strings.Repeat("0", 64) + `"`})
+	if rr.Code != http.StatusOK || rr.Body.String() != content {
+		t.Errorf("Expected a stale If-Range to get the whole file, got %d: %q", rr.Code, rr.Body.String())
+	}
+
+	// Only the range is fetched; upstream never sees the ETag of the digest
+	mu.Lock()
+	defer mu.Unlock()
+	if strings.Join(forwarded, ",") != "bytes=5-|,bytes=5-|,|" {
+		t.Errorf("Unexpected upstream Range and If-Range headers: %v", forwarded)
+	}
+}
+
+func TestProxyConditionalRequests(t *testing.T) {
+	cfg := &config.Config{
+		PublicPyPIURL:  "https://pypi.org/simple/",
+		PrivatePyPIURL: "https://private.example.com/simple/",
+		Port:           8080,
+		CacheEnabled:   true,
+		CacheSize:      100,
+		// Every cached page is expired, so each request revalidates upstream
+		CacheTTL:   0,
+		PageMaxAge: 10 * time.Minute,
+	}
+
+	proxyInstance, err := NewProxy(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	mockClient := NewMockPyPIClient()
+	proxyInstance.client = mockClient
+	mockClient.privateExists["demo"] = true
+	mockClient.pages[cfg.PrivatePyPIURL] = map[string]string{
+		"demo": `<a href="https://files.example.com/demo-1.0.tar.gz">demo-1.0.tar.gz</a>`,
+	}
+	mockClient.pageETags[cfg.PrivatePyPIURL] = map[string]string{"demo": `"upstream-v1"`}
+
+	get := func(header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/simple/demo/", http.NoBody)
+		for name, value := range header {
+			req.Header.Set(name, value)
+		}
+		rr := httptest.NewRecorder()
+		proxyInstance.HandlePackage(rr, req)
+		return rr
+	}
+
+	rr := get(nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	etag, lastModified := rr.Header().Get("ETag"), rr.Header().Get("Last-Modified")
+	if etag == "" || strings.HasPrefix(etag, "W/") || lastModified == "" {
+		t.Fatalf("Expected a strong ETag and Last-Modified, got %q and %q", etag, lastModified)
+	}
+	if cacheControl := rr.Header().Get("Cache-Control"); cacheControl != "max-age=600" {
+		t.Errorf("Expected Cache-Control max-age=600, got %q", cacheControl)
+	}
+
+	// The expired page was revalidated upstream instead of fetched again
+	get(nil)
+	if mockClient.revalidations != 1 {
+		t.Errorf("Expected 1 conditional upstream revalidation, got %d", mockClient.revalidations)
+	}
+
+	tests := []struct {
+		name     string
+		header   map[string]string
+		expected int
+	}{
+		{"matching ETag", map[string]string{"If-None-Match": etag}, http.StatusNotModified},
+		{"weak matching ETag", map[string]string{"If-None-Match": `"other", W/` + etag}, http.StatusNotModified},
+		{"stale ETag", map[string]string{"If-None-Match": `"other"`}, http.StatusOK},
+		{"stale ETag wins over date", map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": lastModified}, http.StatusOK},
+		{"not modified since", map[string]string{"If-Modified-Since": lastModified}, http.StatusNotModified},
+		{"modified since", map[string]string{"If-Modified-Since": "Mon, 01 Jan 2024 00:00:00 GMT"}, http.StatusOK},
+		{"other format", map[string]string{"If-None-Match": etag, "Accept": pypi.ContentTypeJSON}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := get(tt.header)
+			if rr.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, rr.Code)
+			}
+			if rr.Code == http.StatusNotModified && (rr.Body.Len() != 0 || rr.Header().Get("ETag") != etag) {
+				t.Errorf("Expected an empty 304 with the ETag, got %q and %v", rr.Body.String(), rr.Header())
+			}
+		})
+	}
+
+	// A changed page gets a new ETag and no longer matches
+	mockClient.pages[cfg.PrivatePyPIURL]["demo"] += `<a href="https://files.example.com/demo-1.1.tar.gz">demo-1.1.tar.gz</a>`
+	mockClient.pageETags[cfg.PrivatePyPIURL]["demo"] = `"upstream-v2"`
+	rr = get(map[string]string{"If-None-Match": etag})
+	if rr.Code != http.StatusOK || rr.Header().Get("ETag") == etag {
+		t.Errorf("Expected the changed page with a new ETag, got %d %q", rr.Code, rr.Header().Get("ETag"))
+	}
+	if !strings.Contains(rr.Body.String(), "demo-1.1.tar.gz") {
+		t.Errorf("Expected the changed page, got %s", rr.Body.String())
+	}
+}
+
 // failingResponseWriter is a response writer that fails on write for testing error scenarios.
 type failingResponseWriter struct {
 	*httptest.ResponseRecorder
@@ -0,0 +1,119 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"python-index-proxy/config"
+	"python-index-proxy/pypi"
+	"time"
+)
+
+// minReleaseAge returns the minimum release age that applies to a package:
+// the first matching override, or the configured default.
+func (p *Proxy) minReleaseAge(packageName string) time.Duration {
+	for _, o := range p.releaseAgeOverrides {
+		if o.Matches(packageName) {
+			return o.Age
+		}
+	}
+	return p.config.MinReleaseAge
+}
+
+// uploadTimes returns the upload time of the files of a package on an index.
+// PEP 700 upload times on the page are used when present; the others are
+// looked up in the JSON API of the index.
+func (p *Proxy) uploadTimes(ctx context.Context, source config.IndexConfig, packageName string, files []pypi.File) map[string]time.Time {
+	times := make(map[string]time.Time, len(files))
+	missing := false
+	for _, f := range files {
+		if uploaded, err := time.Parse(time.RFC3339Nano, f.UploadTime); err == nil {
+			times[f.Filename] = uploaded
+			continue
+		}
+		missing = true
+	}
+	if !missing {
+		return times
+	}
+
+	apiURL := source.JSONAPIBaseURL()
+	if apiURL == "" {
+		return times
+	}
+
+	cached, ok := p.cache.GetUploadTimes(source.Name, packageName)
+	if !ok {
+		fetched, err := p.client.GetUploadTimes(ctx, apiURL, packageName)
+		if err != nil {
+			log.Printf("UPLOAD TIMES: /simple/%s/ - unavailable from %s: %v", packageName, apiURL, err)
+			return times
+		}
+		p.cache.SetUploadTimes(source.Name, packageName, fetched)
+		cached.Times = fetched
+	}
+	for name, uploaded := range cached.Times {
+		if _, ok := times[name]; !ok {
+			times[name] = uploaded
+		}
+	}
+	return times
+}
+
+// quarantineReason explains why a file uploaded at the given time is still
+// in quarantine, or returns an empty string when it is old enough.
+func quarantineReason(fileName string, uploaded time.Time, known bool, age time.Duration, now time.Time) string {
+	if !known {
+		// Files of unknown age can't be shown to be old enough
+		return fmt.Sprintf("public file %s has no known upload time and min_release_age is %s", fileName, age)
+	}
+	if released := now.Sub(uploaded); released < age {
+		return fmt.Sprintf("public file %s was uploaded %s ago, less than the min_release_age of %s",
+			fileName, released.Truncate(time.Second), age)
+	}
+	return ""
+}
+
+// quarantineFiles returns the files of a public index page that are older
+// than the minimum release age of the package.
+func (p *Proxy) quarantineFiles(ctx context.Context, source config.IndexConfig, packageName string, files []pypi.File) []pypi.File {
+	age := p.minReleaseAge(packageName)
+	if age <= 0 || len(files) == 0 {
+		return files
+	}
+
+	times := p.uploadTimes(ctx, source, packageName, files)
+	now := time.Now()
+	kept := files[:0:0]
+	for _, f := range files {
+		uploaded, known := times[f.Filename]
+		if reason := quarantineReason(f.Filename, uploaded, known, age, now); reason != "" {
+			log.Printf("QUARANTINE: /simple/%s/ - hiding %s: %s", packageName, f.Filename, reason)
+			continue
+		}
+		kept = append(kept, f)
+	}
+	return kept
+}
+
+// quarantineRefusal explains why a file of a public index is refused by the
+// minimum release age, or returns an empty string when it may be served.
+func (p *Proxy) quarantineRefusal(ctx context.Context, source config.IndexConfig, packageName, fileName string) string {
+	age := p.minReleaseAge(packageName)
+	if age <= 0 {
+		return ""
+	}
+
+	var files []pypi.File
+	if page, err := p.getPackagePage(ctx, source, packageName); err == nil {
+		files, _ = parsePage(page)
+	}
+	times := p.uploadTimes(ctx, source, packageName, files)
+	uploaded, known := times[fileName]
+	if !known {
+		// The page may not list the file, so ask the JSON API directly
+		times = p.uploadTimes(ctx, source, packageName, []pypi.File{{Filename: fileName}})
+		uploaded, known = times[fileName]
+	}
+	return quarantineReason(fileName, uploaded, known, age, time.Now())
+}
@@ -0,0 +1,289 @@
+package proxy
+
+import (
+	"bytes"
+	"html"
+	"net/url"
+	"path"
+	"python-index-proxy/pypi"
+	"strings"
+)
+
+// tokenKind identifies the kind of an HTML token.
+type tokenKind int
+
+const (
+	textToken tokenKind = iota
+	startTagToken
+	endTagToken
+)
+
+// htmlAttr is a single attribute of a start tag, with its value unescaped.
+type htmlAttr struct {
+	key   string
+	value string
+}
+
+// htmlToken is a token produced by tokenizer. Comments, doctypes and
+// processing instructions are skipped.
+type htmlToken struct {
+	kind  tokenKind
+	name  string
+	attrs []htmlAttr
+	text  string
+}
+
+// tokenizer is a small HTML tokenizer for Simple API pages. It understands
+// double-quoted, single-quoted, bare and valueless attributes, comments and
+// raw text elements, which is all these pages use.
+type tokenizer struct {
+	data []byte
+	pos  int
+}
+
+// next returns the next token, or false at the end of the input.
+func (z *tokenizer) next() (htmlToken, bool) {
+	for z.pos < len(z.data) {
+		if z.data[z.pos] != '<' {
+			return z.readText(), true
+		}
+
+		rest := z.data[z.pos:]
+		switch {
+		case bytes.HasPrefix(rest, []byte("<!--")):
+			z.skipPast([]byte("-->"), 4)
+		case bytes.HasPrefix(rest, []byte("<!")), bytes.HasPrefix(rest, []byte("<?")):
+			z.skipPast([]byte(">"), 2)
+		case len(rest) > 2 && rest[1] == '/' && isASCIILetter(rest[2]):
+			z.pos += 2
+			name := z.readName()
+			z.skipPast([]byte(">"), 0)
+			return htmlToken{kind: endTagToken, name: name}, true
+		case len(rest) > 1 && isASCIILetter(rest[1]):
+			z.pos++
+			token := z.readStartTag()
+			if token.name == "script" || token.name == "style" {
+				z.skipRawText(token.name)
+			}
+			return token, true
+		default:
+			// A stray '<' is plain text
+			z.pos++
+			return htmlToken{kind: textToken, text: "<"}, true
+		}
+	}
+	return htmlToken{}, false
+}
+
+// readText reads character data up to the next '<'.
+func (z *tokenizer) readText() htmlToken {
+	end := bytes.IndexByte(z.data[z.pos:], '<')
+	if end < 0 {
+		end = len(z.data) - z.pos
+	}
+	text := z.data[z.pos : z.pos+end]
+	z.pos += end
+	return htmlToken{kind: textToken, text: html.UnescapeString(string(text))}
+}
+
+// readName reads a lowercased tag or attribute name.
+func (z *tokenizer) readName() string {
+	start := z.pos
+	for z.pos < len(z.data) {
+		c := z.data[z.pos]
+		if isSpace(c) || c == '/' || c == '>' || c == '=' {
+			break
+		}
+		z.pos++
+	}
+	return strings.ToLower(string(z.data[start:z.pos]))
+}
+
+// readStartTag reads a tag name and its attributes up to and including '>'.
+func (z *tokenizer) readStartTag() htmlToken {
+	token := htmlToken{kind: startTagToken, name: z.readName()}
+	for {
+		z.skipSpaceAndSlashes()
+		if z.pos >= len(z.data) {
+			return token
+		}
+		if z.data[z.pos] == '>' {
+			z.pos++
+			return token
+		}
+
+		key := z.readName()
+		if key == "" {
+			// Skip a stray '=' so malformed attributes can't stall the tokenizer
+			z.pos++
+			continue
+		}
+		z.skipSpace()
+
+		value := ""
+		if z.pos < len(z.data) && z.data[z.pos] == '=' {
+			z.pos++
+			z.skipSpace()
+			value = z.readValue()
+		}
+		token.attrs = append(token.attrs, htmlAttr{key: key, value: html.UnescapeString(value)})
+	}
+}
+
+// readValue reads a quoted or bare attribute value.
+func (z *tokenizer) readValue() string {
+	if z.pos >= len(z.data) {
+		return ""
+	}
+	if quote := z.data[z.pos]; quote == '"' || quote == '\'' {
+		z.pos++
+		end := bytes.IndexByte(z.data[z.pos:], quote)
+		if end < 0 {
+			end = len(z.data) - z.pos
+		}
+		value := string(z.data[z.pos : z.pos+end])
+		z.pos = min(z.pos+end+1, len(z.data))
+		return value
+	}
+	start := z.pos
+	for z.pos < len(z.data) && !isSpace(z.data[z.pos]) && z.data[z.pos] != '>' {
+		z.pos++
+	}
+	return string(z.data[start:z.pos])
+}
+
+// skipRawText skips the content of a raw text element up to its end tag.
+func (z *tokenizer) skipRawText(name string) {
+	closing := []byte("</" + name)
+	for i := z.pos; i+len(closing) <= len(z.data); i++ {
+		if bytes.EqualFold(z.data[i:i+len(closing)], closing) {
+			z.pos = i
+			return
+		}
+	}
+	z.pos = len(z.data)
+}
+
+// skipPast advances past the next occurrence of sep, searching from offset.
+func (z *tokenizer) skipPast(sep []byte, offset int) {
+	start := min(z.pos+offset, len(z.data))
+	end := bytes.Index(z.data[start:], sep)
+	if end < 0 {
+		z.pos = len(z.data)
+		return
+	}
+	z.pos = start + end + len(sep)
+}
+
+// skipSpace advances past whitespace.
+func (z *tokenizer) skipSpace() {
+	for z.pos < len(z.data) && isSpace(z.data[z.pos]) {
+		z.pos++
+	}
+}
+
+// skipSpaceAndSlashes advances past whitespace and the '/' of self-closing tags.
+func (z *tokenizer) skipSpaceAndSlashes() {
+	for z.pos < len(z.data) && (isSpace(z.data[z.pos]) || z.data[z.pos] == '/') {
+		z.pos++
+	}
+}
+
+// isSpace reports whether c is HTML whitespace.
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// isASCIILetter reports whether c is an ASCII letter.
+func isASCIILetter(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+// parseSimpleHTML parses the anchors of a Simple API HTML page into file
+// records. The file name is taken from the href path, falling back to the
+// link text, and every attribute other than href is kept.
+func parseSimpleHTML(body []byte) []pypi.File {
+	var files []pypi.File
+	var current *pypi.File
+	var text strings.Builder
+
+	finish := func() {
+		if current == nil {
+			return
+		}
+		if current.Filename == "" {
+			current.Filename = strings.TrimSpace(text.String())
+		}
+		if current.Filename != "" {
+			files = append(files, *current)
+		}
+		current = nil
+		text.Reset()
+	}
+
+	z := &tokenizer{data: body}
+	for {
+		token, ok := z.next()
+		if !ok {
+			break
+		}
+
+		switch {
+		case token.kind == startTagToken && token.name == "a":
+			// Anchors don't nest; an unclosed anchor ends at the next one
+			finish()
+			current = anchorRecord(token.attrs)
+		case token.kind == endTagToken && token.name == "a":
+			finish()
+		case token.kind == textToken && current != nil:
+			text.WriteString(token.text)
+		}
+	}
+	finish()
+
+	return files
+}
+
+// anchorRecord builds a file record from anchor attributes, or returns nil
+// for anchors without an href.
+func anchorRecord(attrs []htmlAttr) *pypi.File {
+	f := &pypi.File{Attrs: make(map[string]string)}
+	hasHref := false
+	for _, a := range attrs {
+		if a.key == "href" {
+			if !hasHref {
+				f.URL, hasHref = a.value, true
+			}
+			continue
+		}
+		if _, dup := f.Attrs[a.key]; !dup {
+			f.Attrs[a.key] = a.value
+		}
+	}
+	if !hasHref {
+		return nil
+	}
+	f.Filename = fileNameFromHref(f.URL)
+	return f
+}
+
+// fileNameFromHref returns the last path segment of a file link.
+func fileNameFromHref(href string) string {
+	u, err := url.Parse(href)
+	if err != nil {
+		return ""
+	}
+	name := path.Base(u.Path)
+	if name == "." || name == "/" {
+		return ""
+	}
+	return name
+}
+
+// parsePage parses a package page in either the JSON or the HTML format into file records.
+func parsePage(body []byte) ([]pypi.File, error) {
+	if pypi.IsJSONPage(body) {
+		return pypi.ParseProjectJSON(body)
+	}
+	return parseSimpleHTML(body), nil
+}
@@ -0,0 +1,91 @@
+package proxy
+
+import (
+	"python-index-proxy/config"
+	"python-index-proxy/pypi"
+	"strings"
+	"testing"
+)
+
+func TestParseSimpleHTML(t *testing.T) {
+	page := `<!DOCTYPE html>
+<html>
+<head>
+<title>Links for demo</title>
+<script>var s = "<a href='/not-a-file.tar.gz'>x</a>";</script>
+</head>
+<body>
+<!-- <a href="/commented-1.0.tar.gz">commented</a> -->
+<h1>Links for demo</h1>
+<a href='/packages/demo-1.0.tar.gz#sha256=aaa' data-requires-python='&gt;=3.8' data-yanked>demo-1.0.tar.gz</a>
+<A HREF=/packages/demo-1.0-py3-none-any.whl#sha256=bbb DATA-GPG-SIG=false>download wheel</A><p>kept</p>
+<a href="/packages/demo-2.0.zip" data-yanked="bad &amp; broken">demo-2.0.zip</a><br>
+<a href="/packages/demo-3.0.tar.gz"><span>demo-3.0.tar.gz</span>
+<a name="no-href">ignored</a>
+<a href="">demo-4.0.tar.gz</a>
+</body>
+</html>`
+
+	files := parseSimpleHTML([]byte(page))
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		names = append(names, f.Filename)
+	}
+	expected := "demo-1.0.tar.gz,demo-1.0-py3-none-any.whl,demo-2.0.zip,demo-3.0.tar.gz,demo-4.0.tar.gz"
+	if strings.Join(names, ",") != expected {
+		t.Fatalf("Expected files %s, got %s", expected, strings.Join(names, ","))
+	}
+
+	first := files[0]
+	if first.URL != "/packages/demo-1.0.tar.gz#sha256=aaa" {
+		t.Errorf("Expected single-quoted href with fragment, got %s", first.URL)
+	}
+	if first.Attrs["data-requires-python"] != ">=3.8" {
+		t.Errorf("Expected unescaped requires-python, got %q", first.Attrs["data-requires-python"])
+	}
+	if reason, ok := first.Attrs["data-yanked"]; !ok || reason != "" {
+		t.Errorf("Expected valueless data-yanked, got %q (present=%t)", reason, ok)
+	}
+
+	wheel := files[1]
+	if wheel.URL != "/packages/demo-1.0-py3-none-any.whl#sha256=bbb" || wheel.Attrs["data-gpg-sig"] != "false" {
+		t.Errorf("Expected bare uppercase attributes, got %+v", wheel)
+	}
+	if files[2].Attrs["data-yanked"] != "bad & broken" {
+		t.Errorf("Expected yank reason, got %q", files[2].Attrs["data-yanked"])
+	}
+}
+
+func TestRewrittenPageIsCanonical(t *testing.T) {
+	page := `<a href='https://files.example.com/demo-1.0-py3-none-any.whl'>demo-1.0-py3-none-any.whl</a><a href='https://files.example.com/demo-1.0.tar.gz#sha256=abc' data-yanked=''>demo-1.0.tar.gz</a><p>neighbour</p>`
+
+	p := &Proxy{config: &config.Config{}}
+	files := p.allowedPublicFiles("demo", parseSimpleHTML([]byte(page)))
+	rendered := string(pypi.RenderProjectPage("demo", files))
+
+	if strings.Contains(rendered, ".whl") {
+		t.Errorf("Expected wheel without <br> to be filtered, got %s", rendered)
+	}
+	expected := `<a href="https://files.example.com/demo-1.0.tar.gz#sha256=abc" data-yanked="">demo-1.0.tar.gz</a><br/>`
+	if !strings.Contains(rendered, expected) {
+		t.Errorf("Expected canonical anchor %s, got %s", expected, rendered)
+	}
+
+	// The canonical page parses back to the same records
+	reparsed := parseSimpleHTML([]byte(rendered))
+	if len(reparsed) != 1 || reparsed[0].URL != files[0].URL {
+		t.Errorf("Expected canonical page to round-trip, got %+v", reparsed)
+	}
+}
+
+func TestParsePage(t *testing.T) {
+	files, err := parsePage([]byte(`{"meta": {"api-version": "1.0"}, "name": "demo", "files": [{"filename": "demo-1.0.tar.gz", "url": "/demo-1.0.tar.gz", "hashes": {}}]}`))
+	if err != nil || len(files) != 1 || files[0].Filename != "demo-1.0.tar.gz" {
+		t.Errorf("Expected JSON page to be parsed, got %+v (%v)", files, err)
+	}
+
+	files, err = parsePage([]byte(`<a href="/demo-1.0.tar.gz">demo-1.0.tar.gz</a>`))
+	if err != nil || len(files) != 1 {
+		t.Errorf("Expected HTML page to be parsed, got %+v (%v)", files, err)
+	}
+}
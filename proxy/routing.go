@@ -0,0 +1,146 @@
+package proxy
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"python-index-proxy/config"
+)
+
+var (
+	// errPackageBlocked is returned when a routing rule blocks a package.
+	errPackageBlocked = errors.New("package blocked by routing rule")
+	// errPrivateNamespaceMiss is returned when a package in a private namespace
+	// is missing from every private index.
+	errPrivateNamespaceMiss = errors.New("package in private namespace not found in private indexes")
+)
+
+// matchRule returns the first routing rule that applies to a package.
+func (p *Proxy) matchRule(packageName string) (config.Rule, bool) {
+	for _, rule := range p.rules {
+		if rule.Matches(packageName) {
+			return rule, true
+		}
+	}
+	return config.Rule{}, false
+}
+
+// routeTarget returns the routing target for a package and the name of the
+// rule that selected it. An empty target allows every index in the chain.
+// Packages in a private namespace are never routed to a public index.
+func (p *Proxy) routeTarget(packageName string) (target, ruleName string) {
+	target, ruleName, overridden := p.resolveTarget(packageName)
+	if overridden.name != "" {
+		log.Printf("SECURITY: %s is in a private namespace; ignoring %s (target %s)", packageName, overridden.name, overridden.target)
+	}
+	return target, ruleName
+}
+
+// overriddenRule describes a rule whose target was overridden by a private namespace.
+type overriddenRule struct {
+	name   string
+	target string
+}
+
+// resolveTarget computes the routing target of a package without logging.
+func (p *Proxy) resolveTarget(packageName string) (target, ruleName string, overridden overriddenRule) {
+	if rule, ok := p.matchRule(packageName); ok {
+		target, ruleName = rule.Target, rule.Name
+	} else if p.config.IsPublicOnlyPackage(packageName) {
+		target, ruleName = config.TargetPublic, "public_only_packages"
+	}
+
+	if _, ok := p.privateNamespace(packageName); ok && !p.privateTarget(target) {
+		return config.TargetPrivate, "private_namespaces", overriddenRule{name: ruleName, target: target}
+	}
+	return target, ruleName, overriddenRule{}
+}
+
+// privateNamespace reports whether a package belongs to a private namespace
+// and returns the namespace pattern that matched.
+func (p *Proxy) privateNamespace(packageName string) (string, bool) {
+	return p.namespaces.Match(packageName)
+}
+
+// privateTarget reports whether a routing target can only reach private indexes.
+func (p *Proxy) privateTarget(target string) bool {
+	switch target {
+	case "", config.TargetPublic:
+		return false
+	case config.TargetPrivate, config.TargetBlock:
+		return true
+	}
+	for _, idx := range p.indexes {
+		if idx.Name == target {
+			return !idx.Public
+		}
+	}
+	return false
+}
+
+// eligible reports whether an index may serve packages routed to target.
+func eligible(idx config.IndexConfig, target string) bool {
+	switch target {
+	case "":
+		return true
+	case config.TargetPublic:
+		return idx.Public
+	case config.TargetPrivate:
+		return !idx.Public
+	default:
+		return idx.Name == target
+	}
+}
+
+// selectIndex walks the index chain in priority order and returns the first
+// eligible index that contains the package. It returns errPackageBlocked when
+// a routing rule blocks the package.
+func (p *Proxy) selectIndex(packageName string, exists map[string]bool) (config.IndexConfig, bool, error) {
+	candidates, err := p.candidateIndexes(packageName, exists)
+	if err != nil || len(candidates) == 0 {
+		return config.IndexConfig{}, false, err
+	}
+	return candidates[0], true, nil
+}
+
+// candidateIndexes returns every eligible index that contains the package, in
+// priority order.
+func (p *Proxy) candidateIndexes(packageName string, exists map[string]bool) ([]config.IndexConfig, error) {
+	target, ruleName := p.routeTarget(packageName)
+	if ruleName != "" {
+		log.Printf("ROUTING: %s matched rule %q (target %s)", packageName, ruleName, target)
+	}
+	if target == config.TargetBlock {
+		return nil, errPackageBlocked
+	}
+
+	var candidates []config.IndexConfig
+	for _, idx := range p.indexes {
+		if !eligible(idx, target) {
+			continue
+		}
+		if exists[idx.Name] {
+			candidates = append(candidates, idx)
+		}
+	}
+
+	if len(candidates) == 0 {
+		if pattern, ok := p.privateNamespace(packageName); ok {
+			log.Printf("SECURITY: prevented public fallback for %s (private namespace %q): not found in any private index", packageName, pattern)
+			return nil, errPrivateNamespaceMiss
+		}
+	}
+
+	return candidates, nil
+}
+
+// existenceErrorStatus returns the status to report when existence checks fail.
+// Private namespace packages report 503 so clients never mistake an outage for
+// a missing package.
+func (p *Proxy) existenceErrorStatus(packageName string) int {
+	if _, ok := p.privateNamespace(packageName); ok {
+		log.Printf("SECURITY: private index unavailable for %s (private namespace); refusing to fall back", packageName)
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
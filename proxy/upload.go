@@ -0,0 +1,245 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"python-index-proxy/config"
+	"python-index-proxy/pypi"
+	"strings"
+	"time"
+)
+
+// uploadMaxMemory is the part of an upload form kept in memory; larger files
+// are buffered in temporary files.
+const uploadMaxMemory = 32 << 20
+
+// errFileExists is returned when an uploaded file is already stored.
+var errFileExists = errors.New("file already exists")
+
+// isPrivateIndex reports whether the chain has a private index of that name.
+func isPrivateIndex(indexes []config.IndexConfig, name string) bool {
+	for _, idx := range indexes {
+		if idx.Name == name {
+			return !idx.Public
+		}
+	}
+	return false
+}
+
+// uploadDir returns the directory of the upload index when it is a local
+// index, or an empty string.
+func uploadDir(indexes []config.IndexConfig, name string) string {
+	for _, idx := range indexes {
+		if idx.Name != name {
+			continue
+		}
+		if dir, local := idx.LocalDir(); local {
+			return dir
+		}
+	}
+	return ""
+}
+
+// authorizeUpload checks that an upload carries the configured upload token
+// as its basic auth password, as twine sends it, and refuses it otherwise.
+func (p *Proxy) authorizeUpload(w http.ResponseWriter, r *http.Request) bool {
+	_, password, ok := r.BasicAuth()
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Basic realm="tejedor"`)
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return false
+	}
+	if subtle.ConstantTimeCompare([]byte(password), []byte(p.config.Upload.Token)) != 1 {
+		http.Error(w, "Invalid upload credentials", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// checkUploadFile verifies that an uploaded file is a distribution of the
+// project and version it is uploaded for and matches the SHA-256 digest sent
+// with it.
+func checkUploadFile(projectName, version, expectedSHA256 string, fh *multipart.FileHeader) error {
+	if fh.Filename != filepath.Base(fh.Filename) || strings.ContainsAny(fh.Filename, `/\`) {
+		return fmt.Errorf("invalid file name %q", fh.Filename)
+	}
+	dist, err := pypi.ParseDistributionFilename(fh.Filename)
+	if err != nil {
+		return err
+	}
+	if dist.ProjectName() != projectName {
+		return fmt.Errorf("file %s is not a distribution of %s", fh.Filename, projectName)
+	}
+	fileVersion, err := pypi.ParseVersion(dist.Version)
+	if err != nil {
+		return err
+	}
+	formVersion, err := pypi.ParseVersion(version)
+	if err != nil {
+		return err
+	}
+	if fileVersion.Compare(formVersion) != 0 {
+		return fmt.Errorf("file %s is not a distribution of %s %s", fh.Filename, projectName, version)
+	}
+	if expectedSHA256 == "" {
+		return nil
+	}
+
+	f, err := fh.Open()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return err
+	}
+	if digest := hex.EncodeToString(hash.Sum(nil)); !strings.EqualFold(digest, expectedSHA256) {
+		return fmt.Errorf("file %s has digest %s, expected %s", fh.Filename, digest, expectedSHA256)
+	}
+	return nil
+}
+
+// storeUpload writes an uploaded file to the directory of a local index, under
+// a directory per project, where the index serves it from. Existing files are
+// never replaced.
+func storeUpload(storageDir, projectName string, fh *multipart.FileHeader) error {
+	dir := filepath.Join(storageDir, projectName)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	target := filepath.Join(dir, fh.Filename)
+	if _, err := os.Stat(target); err == nil {
+		return errFileExists
+	}
+
+	src, err := fh.Open()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = src.Close()
+	}()
+
+	// Write to a temporary file first so partial uploads are never served
+	tmp, err := os.CreateTemp(dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+	if _, err := io.Copy(tmp, src); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Link(tmp.Name(), target); err != nil {
+		if os.IsExist(err) {
+			return errFileExists
+		}
+		return err
+	}
+	return nil
+}
+
+// HandleUpload accepts the legacy PyPI upload API, as used by twine, and
+// forwards uploads to the upload URL of the private index or stores them in
+// the local upload index. The caches of the project on the upload index and the root listing
+// are invalidated once the upload succeeds, so the new file is listed right away.
+func (p *Proxy) HandleUpload(w http.ResponseWriter, r *http.Request) {
+	upload := p.config.Upload
+	if !upload.Enabled() {
+		http.Error(w, "Uploads are not enabled", http.StatusNotFound)
+		return
+	}
+	if !p.authorizeUpload(w, r) {
+		return
+	}
+
+	// Large distributions take longer to upload and forward than the server
+	// timeouts allow, so they are lifted for authorized uploads
+	rc := http.NewResponseController(w)
+	_ = rc.SetReadDeadline(time.Time{})
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	if err := r.ParseMultipartForm(uploadMaxMemory); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid upload form: %v", err), http.StatusBadRequest)
+		return
+	}
+	defer func() {
+		_ = r.MultipartForm.RemoveAll()
+	}()
+
+	if action := r.FormValue(":action"); action != pypi.UploadAction {
+		http.Error(w, fmt.Sprintf("Unsupported action %q", action), http.StatusBadRequest)
+		return
+	}
+	name, version := r.FormValue("name"), r.FormValue("version")
+	if name == "" || version == "" {
+		http.Error(w, "Upload form requires name and version", http.StatusBadRequest)
+		return
+	}
+	files := r.MultipartForm.File["content"]
+	if len(files) != 1 {
+		http.Error(w, "Upload form requires exactly one content file", http.StatusBadRequest)
+		return
+	}
+	fh := files[0]
+
+	projectName := pypi.NormalizeName(name)
+	if err := checkUploadFile(projectName, version, r.FormValue("sha256_digest"), fh); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid upload: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	var uploadErr *pypi.UploadError
+	var err error
+	if upload.URL != "" {
+		err = p.client.Upload(r.Context(), upload.URL, upload.Username, upload.Password, r.MultipartForm)
+	} else {
+		err = storeUpload(uploadDir(p.indexes, upload.IndexName()), projectName, fh)
+	}
+	switch {
+	case errors.As(err, &uploadErr):
+		log.Printf("UPLOAD: %s refused by %s for client %s: %v", fh.Filename, upload.URL, clientAddr(r), err)
+		http.Error(w, uploadErr.Message, uploadErr.StatusCode)
+		return
+	case errors.Is(err, errFileExists):
+		http.Error(w, fmt.Sprintf("File already exists: %s", fh.Filename), http.StatusConflict)
+		return
+	case err != nil:
+		log.Printf("UPLOAD: %s failed for client %s: %v", fh.Filename, clientAddr(r), err)
+		status := http.StatusBadGateway
+		if upload.URL == "" {
+			status = http.StatusInternalServerError
+		}
+		http.Error(w, fmt.Sprintf("Error uploading file: %v", err), status)
+		return
+	}
+
+	index := upload.IndexName()
+	p.cache.SetPackage(index, projectName, true)
+	p.cache.InvalidatePackage(index, projectName)
+	p.root.reset()
+	log.Printf("UPLOAD: %s %s uploaded %s to %s for client %s", projectName, version, fh.Filename, index, clientAddr(r))
+
+	w.Header().Set(pypi.ResponseHeaderSource, "proxy")
+	if _, err := w.Write([]byte("OK")); err != nil {
+		http.Error(w, fmt.Sprintf("Error writing response: %v", err), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,184 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"python-index-proxy/config"
+	"python-index-proxy/osv"
+	"python-index-proxy/pypi"
+	"strings"
+	"sync"
+	"time"
+)
+
+// vulnerabilityDB holds the loaded OSV database, which reloads replace.
+type vulnerabilityDB struct {
+	path string
+	mu   sync.RWMutex
+	db   *osv.Database
+}
+
+// get returns the current database, or nil when none is loaded.
+func (v *vulnerabilityDB) get() *osv.Database {
+	if v == nil {
+		return nil
+	}
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.db
+}
+
+// reload loads the database again, keeping the previous one on failure.
+func (v *vulnerabilityDB) reload() (*osv.Database, error) {
+	db, err := osv.Load(v.path)
+	if err != nil {
+		return nil, err
+	}
+	v.mu.Lock()
+	v.db = db
+	v.mu.Unlock()
+	log.Printf("OSV: loaded %d PyPI advisories from %s", db.Count(), db.Source)
+	return db, nil
+}
+
+// newVulnerabilityDB loads the configured OSV database, or returns nil when
+// none is configured.
+func newVulnerabilityDB(cfg config.OSVConfig) (*vulnerabilityDB, error) {
+	if !cfg.Enabled() {
+		return nil, nil
+	}
+	v := &vulnerabilityDB{path: cfg.Path}
+	if _, err := v.reload(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// RefreshVulnerabilities reloads the OSV database on the configured schedule
+// until the context is canceled. It returns immediately when no refresh
+// interval is configured.
+func (p *Proxy) RefreshVulnerabilities(ctx context.Context) {
+	if p.vulns == nil || p.config.OSV.RefreshMinutes <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(time.Duration(p.config.OSV.RefreshMinutes) * time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := p.vulns.reload(); err != nil {
+				log.Printf("OSV: refresh failed, keeping the previous database: %v", err)
+			}
+		}
+	}
+}
+
+// osvActionRank orders actions from weakest to strongest.
+var osvActionRank = map[string]int{
+	config.OSVActionLog:  0,
+	config.OSVActionYank: 1,
+	config.OSVActionHide: 2,
+}
+
+// vulnerabilityAction returns the strongest configured action for the
+// advisories affecting a file, along with those advisories. It returns an
+// empty action when no advisory applies.
+func (p *Proxy) vulnerabilityAction(packageName, fileName string) (action string, advisories []osv.Advisory) {
+	db := p.vulns.get()
+	if db == nil {
+		return "", nil
+	}
+
+	dist, err := pypi.ParseDistributionFilename(fileName)
+	if err != nil {
+		return "", nil
+	}
+	version, err := pypi.ParseVersion(dist.Version)
+	if err != nil {
+		return "", nil
+	}
+
+	advisories = db.Affecting(packageName, version)
+	for _, a := range advisories {
+		if candidate := p.config.OSV.Action(a.Severity); action == "" || osvActionRank[candidate] > osvActionRank[action] {
+			action = candidate
+		}
+	}
+	return action, advisories
+}
+
+// advisoryIDs lists the IDs of advisories for logs and yank reasons.
+func advisoryIDs(advisories []osv.Advisory) string {
+	ids := make([]string, 0, len(advisories))
+	for _, a := range advisories {
+		ids = append(ids, a.ID)
+	}
+	return strings.Join(ids, ", ")
+}
+
+// vulnerabilityRefusal explains why a file is refused for known
+// vulnerabilities, or returns an empty string when it may be served.
+func (p *Proxy) vulnerabilityRefusal(packageName, fileName string) string {
+	action, advisories := p.vulnerabilityAction(packageName, fileName)
+	if action != config.OSVActionHide {
+		return ""
+	}
+	return fmt.Sprintf("%s is affected by %s", fileName, advisoryIDs(advisories))
+}
+
+// screenVulnerableFiles applies the configured OSV actions to the files of an
+// index page: affected files are hidden, marked as yanked or only logged.
+func (p *Proxy) screenVulnerableFiles(packageName string, files []pypi.File) []pypi.File {
+	if p.vulns.get() == nil {
+		return files
+	}
+
+	kept := files[:0:0]
+	for _, f := range files {
+		action, advisories := p.vulnerabilityAction(packageName, f.Filename)
+		switch action {
+		case config.OSVActionHide:
+			log.Printf("OSV: /simple/%s/ - hiding %s, affected by %s", packageName, f.Filename, advisoryIDs(advisories))
+			continue
+		case config.OSVActionYank:
+			log.Printf("OSV: /simple/%s/ - yanking %s, affected by %s", packageName, f.Filename, advisoryIDs(advisories))
+			f.Yank(advisoryIDs(advisories))
+		case config.OSVActionLog:
+			log.Printf("OSV: /simple/%s/ - serving %s, affected by %s", packageName, f.Filename, advisoryIDs(advisories))
+		}
+		kept = append(kept, f)
+	}
+	return kept
+}
+
+// HandleReloadVulnerabilities reloads the OSV database on request of an
+// administrator authenticated by the configured admin token.
+func (p *Proxy) HandleReloadVulnerabilities(w http.ResponseWriter, r *http.Request) {
+	if !p.authorizeAdmin(w, r) {
+		return
+	}
+	if p.vulns == nil {
+		http.Error(w, "No OSV database is configured", http.StatusNotFound)
+		return
+	}
+
+	db, err := p.vulns.reload()
+	if err != nil {
+		log.Printf("OSV: reload failed, keeping the previous database: %v", err)
+		http.Error(w, fmt.Sprintf("Error reloading OSV database: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set(pypi.ResponseHeaderSource, "proxy")
+	response := fmt.Sprintf(`{"advisories": %d, "loaded": %q}`, db.Count(), db.Loaded.UTC().Format(time.RFC3339))
+	if _, err := w.Write([]byte(response)); err != nil {
+		http.Error(w, fmt.Sprintf("Error writing response: %v", err), http.StatusInternalServerError)
+		return
+	}
+}
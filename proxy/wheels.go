@@ -0,0 +1,80 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"python-index-proxy/config"
+	"python-index-proxy/pypi"
+	"strings"
+)
+
+// wheelPolicy returns the wheel policy that applies to a package: the first
+// matching configured policy, or the default policy.
+func (p *Proxy) wheelPolicy(packageName string) config.WheelPolicy {
+	for _, wp := range p.wheelPolicies {
+		if wp.Matches(packageName) {
+			return wp.WheelPolicy
+		}
+	}
+	return p.config.FallbackWheelPolicy()
+}
+
+// allowPublicFile reports whether a file listed by a public index may be
+// served. Source distributions always are; wheels are subject to the wheel
+// policy of the package, decided on their parsed tags.
+func (p *Proxy) allowPublicFile(packageName, fileName string) bool {
+	return p.publicFileRefusal(packageName, fileName) == ""
+}
+
+// publicFileRefusal explains why a file listed by a public index is refused,
+// or returns an empty string when it may be served.
+func (p *Proxy) publicFileRefusal(packageName, fileName string) string {
+	if !isWheelFile(fileName) {
+		return ""
+	}
+	dist, err := pypi.ParseDistributionFilename(fileName)
+	if err != nil {
+		// Wheels whose tags can't be parsed are never allowed
+		return fmt.Sprintf("public wheel %s has an invalid file name", fileName)
+	}
+
+	policy := p.wheelPolicy(packageName)
+	if policy.AllowsWheel(dist) {
+		return ""
+	}
+	if policy.Policy == config.WheelPolicyAllowTags {
+		return fmt.Sprintf("public wheel %s is refused by the %q wheel policy for %s (allowed tags: %s)",
+			fileName, policy.Policy, packageName, strings.Join(policy.Tags, ", "))
+	}
+	return fmt.Sprintf("public wheel %s is refused by the %q wheel policy for %s", fileName, policy.Policy, packageName)
+}
+
+// clientAddr describes the client of a request for logs, including the
+// forwarding chain when the proxy runs behind a load balancer.
+func clientAddr(r *http.Request) string {
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		return fmt.Sprintf("%s (forwarded for %s)", r.RemoteAddr, forwarded)
+	}
+	return r.RemoteAddr
+}
+
+// allowedPublicFiles returns the files of a public index page that may be served.
+func (p *Proxy) allowedPublicFiles(packageName string, files []pypi.File) []pypi.File {
+	kept := files[:0:0]
+	for _, f := range files {
+		if p.allowPublicFile(packageName, f.Filename) {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
+
+// isWheelFile reports whether a file name refers to a wheel.
+func isWheelFile(fileName string) bool {
+	dist, err := pypi.ParseDistributionFilename(fileName)
+	if err != nil {
+		// Fall back to the extension so malformed wheels are still treated as wheels
+		return strings.HasSuffix(strings.ToLower(fileName), ".whl")
+	}
+	return dist.IsWheel()
+}
@@ -0,0 +1,134 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"python-index-proxy/config"
+	"python-index-proxy/pypi"
+	"sync"
+)
+
+// yankOverlay holds the local yanks: those configured at startup and those
+// added through the admin API, which last until the proxy restarts.
+type yankOverlay struct {
+	mu      sync.RWMutex
+	entries []config.CompiledYankEntry
+}
+
+// reason returns the yank reasons of every entry applying to a file, or an
+// empty string when none applies.
+func (y *yankOverlay) reason(packageName, fileName string) string {
+	y.mu.RLock()
+	defer y.mu.RUnlock()
+
+	reason := ""
+	for _, e := range y.entries {
+		if !e.Yanks(packageName, fileName) {
+			continue
+		}
+		if reason != "" {
+			reason += "; "
+		}
+		reason += e.Reason
+	}
+	return reason
+}
+
+// list returns the current entries.
+func (y *yankOverlay) list() []config.YankEntry {
+	y.mu.RLock()
+	defer y.mu.RUnlock()
+
+	entries := make([]config.YankEntry, 0, len(y.entries))
+	for _, e := range y.entries {
+		entries = append(entries, e.YankEntry)
+	}
+	return entries
+}
+
+// add appends an entry.
+func (y *yankOverlay) add(e config.CompiledYankEntry) {
+	y.mu.Lock()
+	defer y.mu.Unlock()
+	y.entries = append(y.entries, e)
+}
+
+// remove deletes the entries selecting the same files as the given entry and
+// reports how many were removed.
+func (y *yankOverlay) remove(e config.YankEntry) int {
+	y.mu.Lock()
+	defer y.mu.Unlock()
+
+	kept := y.entries[:0]
+	removed := 0
+	for _, existing := range y.entries {
+		if pypi.NormalizeName(existing.Package) == pypi.NormalizeName(e.Package) &&
+			existing.Versions == e.Versions && existing.Filename == e.Filename {
+			removed++
+			continue
+		}
+		kept = append(kept, existing)
+	}
+	y.entries = kept
+	return removed
+}
+
+// applyYanks marks the files of an index page that the local overlay yanks.
+// Upstream yanks are kept; local reasons are added to theirs.
+func (p *Proxy) applyYanks(packageName string, files []pypi.File) {
+	for i := range files {
+		if reason := p.yanks.reason(packageName, files[i].Filename); reason != "" {
+			log.Printf("YANK: /simple/%s/ - yanking %s: %s", packageName, files[i].Filename, reason)
+			files[i].Yank(reason)
+		}
+	}
+}
+
+// HandleYanks lists, adds and removes local yanks. GET lists the entries,
+// POST adds the entry in the body and DELETE removes entries selecting the
+// same files as the one in the body. Changes made here are not persisted.
+func (p *Proxy) HandleYanks(w http.ResponseWriter, r *http.Request) {
+	if !p.authorizeAdmin(w, r) {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost, http.MethodDelete:
+		var entry config.YankEntry
+		if err := json.NewDecoder(r.Body).Decode(&entry); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid yank entry: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		if r.Method == http.MethodDelete {
+			if p.yanks.remove(entry) == 0 {
+				http.Error(w, "Yank entry not found", http.StatusNotFound)
+				return
+			}
+			log.Printf("YANK: removed %+v for client %s", entry, clientAddr(r))
+			break
+		}
+
+		compiled, err := config.CompileYankEntry(entry)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid yank entry: %v", err), http.StatusBadRequest)
+			return
+		}
+		p.yanks.add(compiled)
+		log.Printf("YANK: added %+v for client %s", entry, clientAddr(r))
+	}
+
+	body, err := json.MarshalIndent(p.yanks.list(), "", "  ")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error encoding yanks: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set(pypi.ResponseHeaderSource, "proxy")
+	if _, err := w.Write(body); err != nil {
+		http.Error(w, fmt.Sprintf("Error writing response: %v", err), http.StatusInternalServerError)
+		return
+	}
+}
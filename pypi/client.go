@@ -3,8 +3,13 @@ package pypi
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"net/url"
 	"strings"
@@ -18,31 +23,89 @@ const (
 	ResponseHeaderSourcePublic = "public"
 	// ResponseHeaderSourcePrivate indicates the package is from private PyPI.
 	ResponseHeaderSourcePrivate = "private"
+	// HeaderAsOf selects, and reports, the timestamp of an as-of index snapshot.
+	HeaderAsOf = "X-PyPI-As-Of"
 )
 
+// ErrDigestMismatch is returned by ProxyFile when a streamed file doesn't match
+// its expected SHA-256 digest. The end of the body is withheld from the client.
+var ErrDigestMismatch = errors.New("file digest mismatch")
+
+// ErrNotModified is returned by RevalidatePackagePage when the index reports
+// that a cached page is still current.
+var ErrNotModified = errors.New("not modified")
+
+// PageValidators are the HTTP validators an index served a page with.
+type PageValidators struct {
+	ETag         string
+	LastModified string
+}
+
+// IsZero reports whether no validators are known.
+func (v PageValidators) IsZero() bool {
+	return v.ETag == "" && v.LastModified == ""
+}
+
+// ErrNotFound is returned when an index reports that a resource doesn't exist.
+var ErrNotFound = errors.New("not found")
+
+// FileETag returns the strong ETag of a file with a known SHA-256 digest. The
+// digest identifies the content, so the ETag holds across file hosts, caches
+// and restarts.
+func FileETag(sha256Digest string) string {
+	return `"sha256:` + strings.ToLower(sha256Digest) + `"`
+}
+
+// proxyBufferSize is the size of the chunks ProxyFile streams files in.
+const proxyBufferSize = 32 * 1024
+
+// fileResponseHeaderTimeout bounds how long ProxyFile waits for upstream to
+// start answering. The body itself is bound only by the request context.
+const fileResponseHeaderTimeout = 30 * time.Second
+
 // PyPIClient defines the interface for PyPI client operations.
 //
 //nolint:revive // This interface name is intentionally descriptive and used throughout the codebase
 type PyPIClient interface {
 	PackageExists(ctx context.Context, baseURL, packageName string) (bool, error)
 	GetPackagePage(ctx context.Context, baseURL, packageName string) ([]byte, error)
+	RevalidatePackagePage(ctx context.Context, baseURL, packageName string, cached PageValidators) ([]byte, PageValidators, error)
 	GetPackageFile(ctx context.Context, fileURL string) ([]byte, error)
-	ProxyFile(ctx context.Context, fileURL string, w http.ResponseWriter, method string) error
+	ProxyFile(ctx context.Context, fileURL string, w http.ResponseWriter, clientReq *http.Request, expectedSHA256 string) error
+	FetchFile(ctx context.Context, fileURL string, dst io.Writer, expectedSHA256 string) error
+	ListProjects(ctx context.Context, baseURL string, fn func(name string) error) error
+	GetUploadTimes(ctx context.Context, jsonAPIURL, packageName string) (map[string]time.Time, error)
+	GetProjectJSON(ctx context.Context, jsonAPIURL, packageName, version string) ([]byte, error)
+	Upload(ctx context.Context, uploadURL, username, password string, form *multipart.Form) error
 }
 
 // HTTPClient represents a PyPI client.
 type HTTPClient struct {
 	httpClient *http.Client
+	// fileClient streams downloads, which may take longer than any overall timeout.
+	fileClient *http.Client
 }
 
 // Ensure HTTPClient implements PyPIClient interface.
 var _ PyPIClient = (*HTTPClient)(nil)
 
-// NewClient creates a new PyPI client.
+// NewClient creates a new PyPI client. Indexes at file:// URLs are read from
+// local directories.
 func NewClient() *HTTPClient {
+	local := newLocalIndexTransport()
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.RegisterProtocol("file", local)
+	fileTransport := http.DefaultTransport.(*http.Transport).Clone()
+	fileTransport.ResponseHeaderTimeout = fileResponseHeaderTimeout
+	fileTransport.RegisterProtocol("file", local)
+
 	return &HTTPClient{
 		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
+			Transport: transport,
+			Timeout:   30 * time.Second,
+		},
+		fileClient: &http.Client{
+			Transport: fileTransport,
 		},
 	}
 }
@@ -63,7 +126,7 @@ func joinURL(base, path string) (string, error) {
 // PackageExists checks if a package exists in the specified index.
 func (c *HTTPClient) PackageExists(ctx context.Context, baseURL, packageName string) (bool, error) {
 	// Normalize the package name for URL
-	normalizedName := strings.ToLower(strings.ReplaceAll(packageName, "_", "-"))
+	normalizedName := NormalizeName(packageName)
 
 	// Ensure base URL ends with a trailing slash for proper path joining
 	if !strings.HasSuffix(baseURL, "/") {
@@ -84,7 +147,8 @@ func (c *HTTPClient) PackageExists(ctx context.Context, baseURL, packageName str
 
 	// Create a client that doesn't follow redirects for package existence checks
 	noRedirectClient := &http.Client{
-		Timeout: 30 * time.Second,
+		Transport: c.httpClient.Transport,
+		Timeout:   30 * time.Second,
 		CheckRedirect: func(_ *http.Request, _ []*http.Request) error {
 			return http.ErrUseLastResponse
 		},
@@ -122,21 +186,44 @@ func (c *HTTPClient) PackageExists(ctx context.Context, baseURL, packageName str
 				_ = closeErr // explicitly ignore error
 			}
 		}()
-		return getResp.StatusCode == http.StatusOK, nil
+		return existenceStatus(getResp.StatusCode, packageURL)
 	}
 	// Treat 3xx redirects as "package not found" for private servers
 	// This prevents false positives when private servers redirect to public PyPI
 	if resp.StatusCode >= 300 && resp.StatusCode < 400 {
 		return false, nil
 	}
-	// Package does not exist
-	return false, nil
+	return existenceStatus(resp.StatusCode, packageURL)
+}
+
+// existenceStatus interprets the status of a package page request. Only 200
+// and 404 are answers; any other status means the index couldn't tell, and is
+// returned as an error so it is neither cached nor mistaken for a missing
+// package during an outage.
+func existenceStatus(statusCode int, packageURL string) (bool, error) {
+	switch statusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("unexpected status %d checking %s", statusCode, packageURL)
+	}
 }
 
 // GetPackagePage retrieves the package page from the specified index.
 func (c *HTTPClient) GetPackagePage(ctx context.Context, baseURL, packageName string) ([]byte, error) {
+	page, _, err := c.RevalidatePackagePage(ctx, baseURL, packageName, PageValidators{})
+	return page, err
+}
+
+// RevalidatePackagePage retrieves the package page from the specified index,
+// conditionally on the validators of a cached copy when they are set. It
+// returns ErrNotModified when the cached copy is still current, and the
+// validators of the page otherwise.
+func (c *HTTPClient) RevalidatePackagePage(ctx context.Context, baseURL, packageName string, cached PageValidators) ([]byte, PageValidators, error) {
 	// Normalize the package name for URL
-	normalizedName := strings.ToLower(strings.ReplaceAll(packageName, "_", "-"))
+	normalizedName := NormalizeName(packageName)
 
 	// Ensure base URL ends with a trailing slash for proper path joining
 	if !strings.HasSuffix(baseURL, "/") {
@@ -146,14 +233,120 @@ func (c *HTTPClient) GetPackagePage(ctx context.Context, baseURL, packageName st
 	// Construct the package URL robustly
 	packageURL, err := joinURL(baseURL, normalizedName+"/")
 	if err != nil {
-		return nil, fmt.Errorf("error joining URL: %w", err)
+		return nil, PageValidators{}, fmt.Errorf("error joining URL: %w", err)
 	}
 
 	// Make GET request to retrieve package page
 	req, err := http.NewRequestWithContext(ctx, "GET", packageURL, http.NoBody)
+	if err != nil {
+		return nil, PageValidators{}, fmt.Errorf("error creating request: %w", err)
+	}
+	// Prefer the PEP 691 JSON format; indexes without JSON support answer with HTML
+	req.Header.Set("Accept", AcceptSimple)
+	if cached.ETag != "" {
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
+	if cached.LastModified != "" {
+		req.Header.Set("If-Modified-Since", cached.LastModified)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, PageValidators{}, fmt.Errorf("error making request: %w", err)
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			// Log the error but don't fail the function
+			// This is a common pattern for defer close operations
+			_ = closeErr // explicitly ignore error
+		}
+	}()
+	if resp.StatusCode == http.StatusNotModified && !cached.IsZero() {
+		return nil, cached, ErrNotModified
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, PageValidators{}, fmt.Errorf("package not found: %s", packageName)
+	}
+
+	// Read the response body
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, PageValidators{}, fmt.Errorf("error reading response body: %w", err)
+	}
+
+	validators := PageValidators{ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")}
+	return body, validators, nil
+}
+
+// ListProjects streams the root project listing of the specified index, calling
+// fn for every project name.
+func (c *HTTPClient) ListProjects(ctx context.Context, baseURL string, fn func(name string) error) error {
+	// Ensure base URL ends with a trailing slash, as the root listing lives at the index root
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", baseURL, http.NoBody)
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Accept", AcceptSimple)
+
+	// The public listing is large, so the request is not bound by the client timeout
+	listClient := &http.Client{Transport: c.httpClient.Transport}
+	resp, err := listClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error making request: %w", err)
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			// Log the error but don't fail the function
+			// This is a common pattern for defer close operations
+			_ = closeErr // explicitly ignore error
+		}
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("project list not available: %s (status %d)", baseURL, resp.StatusCode)
+	}
+
+	return ScanProjectList(resp.Body, resp.Header.Get("Content-Type"), fn)
+}
+
+// projectJSONURL returns the JSON API URL of a project, or of one of its
+// releases when version is set.
+func projectJSONURL(jsonAPIURL, packageName, version string) (string, error) {
+	if !strings.HasSuffix(jsonAPIURL, "/") {
+		jsonAPIURL += "/"
+	}
+
+	path := url.PathEscape(NormalizeName(packageName)) + "/"
+	if version != "" {
+		path += url.PathEscape(version) + "/"
+	}
+	return joinURL(jsonAPIURL, path+"json")
+}
+
+// projectReleasesJSON is the part of a PyPI JSON API project response that lists files.
+type projectReleasesJSON struct {
+	Releases map[string][]struct {
+		Filename   string `json:"filename"`
+		UploadTime string `json:"upload_time_iso_8601"`
+	} `json:"releases"`
+}
+
+// GetUploadTimes retrieves the upload time of every file of a package from a
+// PyPI-compatible JSON API rooted at jsonAPIURL, e.g. "https://pypi.org/pypi/".
+func (c *HTTPClient) GetUploadTimes(ctx context.Context, jsonAPIURL, packageName string) (map[string]time.Time, error) {
+	projectURL, err := projectJSONURL(jsonAPIURL, packageName, "")
+	if err != nil {
+		return nil, fmt.Errorf("error joining URL: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", projectURL, http.NoBody)
 	if err != nil {
 		return nil, fmt.Errorf("error creating request: %w", err)
 	}
+	req.Header.Set("Accept", "application/json")
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
@@ -170,12 +363,61 @@ func (c *HTTPClient) GetPackagePage(ctx context.Context, baseURL, packageName st
 		return nil, fmt.Errorf("package not found: %s", packageName)
 	}
 
-	// Read the response body
+	var project projectReleasesJSON
+	if err := json.NewDecoder(resp.Body).Decode(&project); err != nil {
+		return nil, fmt.Errorf("error decoding JSON API response: %w", err)
+	}
+
+	times := make(map[string]time.Time)
+	for _, files := range project.Releases {
+		for _, f := range files {
+			uploaded, err := time.Parse(time.RFC3339Nano, f.UploadTime)
+			if err != nil {
+				continue
+			}
+			times[f.Filename] = uploaded
+		}
+	}
+	return times, nil
+}
+
+// GetProjectJSON retrieves the JSON API response of a project, or of one of
+// its releases when version is set, from a PyPI-compatible JSON API rooted at
+// jsonAPIURL. The body is returned as served.
+func (c *HTTPClient) GetProjectJSON(ctx context.Context, jsonAPIURL, packageName, version string) ([]byte, error) {
+	projectURL, err := projectJSONURL(jsonAPIURL, packageName, version)
+	if err != nil {
+		return nil, fmt.Errorf("error joining URL: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", projectURL, http.NoBody)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error making request: %w", err)
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			// Log the error but don't fail the function
+			// This is a common pattern for defer close operations
+			_ = closeErr // explicitly ignore error
+		}
+	}()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, projectURL)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d from %s", resp.StatusCode, projectURL)
+	}
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, fmt.Errorf("error reading response body: %w", err)
 	}
-
 	return body, nil
 }
 
@@ -210,14 +452,24 @@ func (c *HTTPClient) GetPackageFile(ctx context.Context, fileURL string) ([]byte
 	return body, nil
 }
 
-// ProxyFile proxies a file from the specified URL to the response writer.
-func (c *HTTPClient) ProxyFile(ctx context.Context, fileURL string, w http.ResponseWriter, method string) error {
-	req, err := http.NewRequestWithContext(ctx, method, fileURL, http.NoBody)
+// ProxyFile proxies a file from the specified URL to the response writer,
+// using the method of the client request.
+// When expectedSHA256 is set, the whole body is hashed while it is streamed
+// and the last chunk is only written once the digest matches; on a mismatch
+// ErrDigestMismatch is returned and the client receives a truncated body. The
+// response carries the ETag of the digest rather than the upstream one.
+// The Range and If-Range headers are forwarded so interrupted downloads can
+// resume, and partial content (206) and unsatisfiable ranges (416) are passed
+// through. A part of a file can't be verified against the digest of the
+// whole, so partial content is never verified.
+func (c *HTTPClient) ProxyFile(ctx context.Context, fileURL string, w http.ResponseWriter, clientReq *http.Request, expectedSHA256 string) error {
+	req, err := http.NewRequestWithContext(ctx, clientReq.Method, fileURL, http.NoBody)
 	if err != nil {
 		return fmt.Errorf("error creating request: %w", err)
 	}
+	ranged := forwardRange(req, clientReq, expectedSHA256)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.fileClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("error making request: %w", err)
 	}
@@ -228,7 +480,10 @@ func (c *HTTPClient) ProxyFile(ctx context.Context, fileURL string, w http.Respo
 			_ = closeErr // explicitly ignore error
 		}
 	}()
-	if resp.StatusCode != http.StatusOK {
+	switch {
+	case resp.StatusCode == http.StatusOK:
+	case ranged && (resp.StatusCode == http.StatusPartialContent || resp.StatusCode == http.StatusRequestedRangeNotSatisfiable):
+	default:
 		return fmt.Errorf("file not found: %s", fileURL)
 	}
 
@@ -238,12 +493,121 @@ func (c *HTTPClient) ProxyFile(ctx context.Context, fileURL string, w http.Respo
 			w.Header().Add(key, value)
 		}
 	}
+	if expectedSHA256 != "" {
+		w.Header().Set("ETag", FileETag(expectedSHA256))
+	}
+	w.WriteHeader(resp.StatusCode)
+
+	// HEAD responses have no body to verify, and partial ones can't be
+	if expectedSHA256 == "" || clientReq.Method == http.MethodHead || resp.StatusCode != http.StatusOK {
+		_, err = io.Copy(w, resp.Body)
+		if err != nil {
+			return fmt.Errorf("error copying response body: %w", err)
+		}
+		return nil
+	}
+
+	return copyVerified(w, resp.Body, expectedSHA256)
+}
 
-	// Copy the response body
-	_, err = io.Copy(w, resp.Body)
+// forwardRange copies the Range and If-Range headers of a client request to
+// the upstream request and reports whether a range was forwarded. Upstream
+// doesn't know the ETag of a digest, so with a known digest an If-Range
+// naming it is resolved here, and a range behind any other If-Range isn't
+// forwarded: the validator is stale and the client gets the whole file.
+func forwardRange(req, clientReq *http.Request, expectedSHA256 string) bool {
+	byteRange, ifRange := clientReq.Header.Get("Range"), clientReq.Header.Get("If-Range")
+	if byteRange == "" {
+		return false
+	}
+	if expectedSHA256 != "" {
+		if ifRange != "" && ifRange != FileETag(expectedSHA256) {
+			return false
+		}
+		ifRange = ""
+	}
+	req.Header.Set("Range", byteRange)
+	if ifRange != "" {
+		req.Header.Set("If-Range", ifRange)
+	}
+	return true
+}
+
+// SizeWriter is implemented by FetchFile destinations that want the size of
+// the file, when upstream sends it, before its body is written.
+type SizeWriter interface {
+	io.Writer
+	SetSize(size int64)
+}
+
+// FetchFile downloads a whole file from the specified URL to dst. When
+// expectedSHA256 is set, the body is verified as in ProxyFile and
+// ErrDigestMismatch is returned on a mismatch, with the end of the body
+// withheld from dst.
+func (c *HTTPClient) FetchFile(ctx context.Context, fileURL string, dst io.Writer, expectedSHA256 string) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", fileURL, http.NoBody)
 	if err != nil {
-		return fmt.Errorf("error copying response body: %w", err)
+		return fmt.Errorf("error creating request: %w", err)
+	}
+
+	resp, err := c.fileClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error making request: %w", err)
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			// Log the error but don't fail the function
+			// This is a common pattern for defer close operations
+			_ = closeErr // explicitly ignore error
+		}
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("file not found: %s", fileURL)
+	}
+	if sw, ok := dst.(SizeWriter); ok && resp.ContentLength >= 0 {
+		sw.SetSize(resp.ContentLength)
+	}
+
+	if expectedSHA256 == "" {
+		if _, err := io.Copy(dst, resp.Body); err != nil {
+			return fmt.Errorf("error copying response body: %w", err)
+		}
+		return nil
+	}
+	return copyVerified(dst, resp.Body, expectedSHA256)
+}
+
+// copyVerified streams a body while hashing it, holding back the last chunk
+// read until the whole body is known to match the expected SHA-256 digest.
+func copyVerified(w io.Writer, body io.Reader, expectedSHA256 string) error {
+	hash := sha256.New()
+	held := make([]byte, 0, proxyBufferSize)
+	buf := make([]byte, proxyBufferSize)
+
+	for {
+		n, readErr := body.Read(buf)
+		if n > 0 {
+			if len(held) > 0 {
+				if _, err := w.Write(held); err != nil {
+					return fmt.Errorf("error copying response body: %w", err)
+				}
+			}
+			hash.Write(buf[:n])
+			held = append(held[:0], buf[:n]...)
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return fmt.Errorf("error copying response body: %w", readErr)
+		}
 	}
 
+	if actual := hex.EncodeToString(hash.Sum(nil)); !strings.EqualFold(actual, expectedSHA256) {
+		return fmt.Errorf("%w: expected sha256 %s, got %s", ErrDigestMismatch, expectedSHA256, actual)
+	}
+	if _, err := w.Write(held); err != nil {
+		return fmt.Errorf("error copying response body: %w", err)
+	}
 	return nil
 }
@@ -1,11 +1,18 @@
 package pypi
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestNewClient(t *testing.T) {
@@ -65,7 +72,13 @@ func TestPackageExists(t *testing.T) {
 			name:           "500 Internal Server Error - error",
 			statusCode:     http.StatusInternalServerError,
 			expectedExists: false,
-			expectedError:  false,
+			expectedError:  true,
+		},
+		{
+			name:           "503 Service Unavailable - error",
+			statusCode:     http.StatusServiceUnavailable,
+			expectedExists: false,
+			expectedError:  true,
 		},
 	}
 
@@ -141,12 +154,32 @@ func TestPackageExistsWithGETFallbackNotFound(t *testing.T) {
 	}
 }
 
+func TestPackageExistsWithGETFallbackError(t *testing.T) {
+	// Test that a failing GET fallback is an error rather than a missing package
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "HEAD" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	if _, err := client.PackageExists(context.Background(), server.URL, "test-package"); err == nil {
+		t.Error("expected an error for a 502 from the GET fallback")
+	}
+}
+
 func TestGetPackagePage(t *testing.T) {
 	expectedContent := "<html><body>Package page</body></html>"
 
 	// Create test server
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == "GET" {
+			if accept := r.Header.Get("Accept"); accept != AcceptSimple {
+				t.Errorf("Expected Accept header %q, got %q", AcceptSimple, accept)
+			}
 			w.Header().Set("Content-Type", "text/html")
 			if _, err := w.Write([]byte(expectedContent)); err != nil {
 				t.Errorf("Error writing response: %v", err)
@@ -172,6 +205,40 @@ func TestGetPackagePage(t *testing.T) {
 	}
 }
 
+func TestRevalidatePackagePage(t *testing.T) {
+	const etag = `"page-v1"`
+	const lastModified = "Mon, 05 Oct 2026 09:10:11 GMT"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", lastModified)
+		_, _ = w.Write([]byte("<html>demo</html>"))
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	page, validators, err := client.RevalidatePackagePage(context.Background(), server.URL, "demo", PageValidators{})
+	if err != nil || string(page) != "<html>demo</html>" {
+		t.Fatalf("Expected the page, got %q (err=%v)", page, err)
+	}
+	if validators.ETag != etag || validators.LastModified != lastModified {
+		t.Errorf("Expected the upstream validators, got %+v", validators)
+	}
+
+	_, revalidated, err := client.RevalidatePackagePage(context.Background(), server.URL, "demo", validators)
+	if !errors.Is(err, ErrNotModified) || revalidated != validators {
+		t.Errorf("Expected ErrNotModified with the cached validators, got %+v (err=%v)", revalidated, err)
+	}
+
+	page, _, err = client.RevalidatePackagePage(context.Background(), server.URL, "demo", PageValidators{ETag: `"stale"`})
+	if err != nil || string(page) != "<html>demo</html>" {
+		t.Errorf("Expected a changed page to be fetched, got %q (err=%v)", page, err)
+	}
+}
+
 func TestGetPackagePageNotFound(t *testing.T) {
 	// Create test server that returns 404
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
@@ -254,7 +321,7 @@ func TestProxyFile(t *testing.T) {
 	rr := httptest.NewRecorder()
 
 	// Test proxying file
-	err := client.ProxyFile(ctx, server.URL, rr, "GET")
+	err := client.ProxyFile(ctx, server.URL, rr, httptest.NewRequest("GET", "/", http.NoBody), "")
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -273,6 +340,157 @@ func TestProxyFile(t *testing.T) {
 	}
 }
 
+func TestProxyFileDigest(t *testing.T) {
+	content := strings.Repeat("wheel bytes ", 10000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Errorf("Error writing response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	sum := sha256.Sum256([]byte(content))
+	digest := hex.EncodeToString(sum[:])
+
+	rr := httptest.NewRecorder()
+	if err := client.ProxyFile(context.Background(), server.URL, rr, httptest.NewRequest("GET", "/", http.NoBody), digest); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if rr.Body.String() != content {
+		t.Errorf("Expected the whole file, got %d bytes", rr.Body.Len())
+	}
+
+	rr = httptest.NewRecorder()
+	err := client.ProxyFile(context.Background(), server.URL, rr, httptest.NewRequest("GET", "/", http.NoBody), strings.Repeat("0", 64))
+	if !errors.Is(err, ErrDigestMismatch) {
+		t.Fatalf("Expected a digest mismatch, got %v", err)
+	}
+	if rr.Body.Len() >= len(content) {
+		t.Errorf("Expected the end of a tampered file to be withheld, got %d of %d bytes", rr.Body.Len(), len(content))
+	}
+}
+
+func TestProxyFileRange(t *testing.T) {
+	content := "0123456789"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"upstream"`)
+		http.ServeContent(w, r, "demo-1.0.tar.gz", time.Time{}, strings.NewReader(content))
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	sum := sha256.Sum256([]byte(content))
+	digest := hex.EncodeToString(sum[:])
+
+	req := httptest.NewRequest("GET", "/", http.NoBody)
+	req.Header.Set("Range", "bytes=4-")
+	rr := httptest.NewRecorder()
+	if err := client.ProxyFile(context.Background(), server.URL, rr, req, ""); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if rr.Code != http.StatusPartialContent || rr.Body.String() != "456789" {
+		t.Errorf("Expected the requested range, got %d: %q", rr.Code, rr.Body.String())
+	}
+	if contentRange := rr.Header().Get("Content-Range"); contentRange != "bytes 4-9/10" {
+		t.Errorf("Expected the upstream Content-Range, got %q", contentRange)
+	}
+
+	// Ranges of files with a digest carry the ETag of the digest, which
+	// If-Range is resolved against rather than forwarded
+	rr = httptest.NewRecorder()
+	if err := client.ProxyFile(context.Background(), server.URL, rr, req, digest); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if rr.Code != http.StatusPartialContent || rr.Body.String() != "456789" {
+		t.Errorf("Expected the requested range, got %d: %q", rr.Code, rr.Body.String())
+	}
+	if etag := rr.Header().Get("ETag"); etag != FileETag(digest) {
+		t.Errorf("Expected the ETag of the digest, got %q", etag)
+	}
+	req.Header.Set("If-Range", FileETag(digest))
+	rr = httptest.NewRecorder()
+	if err := client.ProxyFile(context.Background(), server.URL, rr, req, digest); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if rr.Code != http.StatusPartialContent || rr.Body.String() != "456789" {
+		t.Errorf("Expected a matching If-Range to resume, got %d: %q", rr.Code, rr.Body.String())
+	}
+	req.Header.Set("If-Range", `"sha256:`+strings.Repeat("0", 64)+`"`)
+	rr = httptest.NewRecorder()
+	if err := client.ProxyFile(context.Background(), server.URL, rr, req, digest); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if rr.Code != http.StatusOK || rr.Body.String() != content {
+		t.Errorf("Expected a stale If-Range to get the whole verified file, got %d: %q", rr.Code, rr.Body.String())
+	}
+	req.Header.Del("If-Range")
+
+	req.Header.Set("Range", "bytes=20-")
+	rr = httptest.NewRecorder()
+	if err := client.ProxyFile(context.Background(), server.URL, rr, req, ""); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if rr.Code != http.StatusRequestedRangeNotSatisfiable {
+		t.Errorf("Expected status 416, got %d", rr.Code)
+	}
+}
+
+func TestFetchFile(t *testing.T) {
+	content := strings.Repeat("wheel bytes ", 10000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Errorf("Error writing response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	sum := sha256.Sum256([]byte(content))
+	digest := hex.EncodeToString(sum[:])
+
+	dst := sizedBuffer{size: -1}
+	if err := client.FetchFile(context.Background(), server.URL, &dst, digest); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if dst.String() != content {
+		t.Errorf("Expected the whole file, got %d bytes", dst.Len())
+	}
+	if dst.size != int64(len(content)) {
+		t.Errorf("Expected the size to be announced, got %d", dst.size)
+	}
+
+	dst.Reset()
+	if err := client.FetchFile(context.Background(), server.URL, &dst, strings.Repeat("0", 64)); !errors.Is(err, ErrDigestMismatch) {
+		t.Fatalf("Expected a digest mismatch, got %v", err)
+	}
+	if dst.Len() >= len(content) {
+		t.Errorf("Expected the end of a tampered file to be withheld, got %d of %d bytes", dst.Len(), len(content))
+	}
+}
+
+// sizedBuffer is a SizeWriter recording the announced size.
+type sizedBuffer struct {
+	bytes.Buffer
+	size int64
+}
+
+func (b *sizedBuffer) SetSize(size int64) {
+	b.size = size
+}
+
+func TestProxyFileTimeouts(t *testing.T) {
+	client := NewClient()
+	if client.fileClient.Timeout != 0 {
+		t.Errorf("Expected downloads not to have an overall timeout, got %v", client.fileClient.Timeout)
+	}
+	transport, ok := client.fileClient.Transport.(*http.Transport)
+	if !ok || transport.ResponseHeaderTimeout != fileResponseHeaderTimeout {
+		t.Errorf("Expected downloads to bound the wait for response headers, got %+v", client.fileClient.Transport)
+	}
+}
+
 func TestProxyFileNotFound(t *testing.T) {
 	// Create test server that returns 404
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
@@ -287,7 +505,7 @@ func TestProxyFileNotFound(t *testing.T) {
 	rr := httptest.NewRecorder()
 
 	// Test proxying non-existent file
-	err := client.ProxyFile(ctx, server.URL, rr, "GET")
+	err := client.ProxyFile(ctx, server.URL, rr, httptest.NewRequest("GET", "/", http.NoBody), "")
 	if err == nil {
 		t.Error("Expected error for non-existent file")
 	}
@@ -364,6 +582,7 @@ func TestGetPackageFileWithError(t *testing.T) {
 func TestProxyFileWithError(t *testing.T) {
 	client := &HTTPClient{
 		httpClient: &http.Client{},
+		fileClient: &http.Client{},
 	}
 
 	// Test with invalid URL to trigger error
@@ -373,7 +592,7 @@ func TestProxyFileWithError(t *testing.T) {
 	}
 	rr := httptest.NewRecorder()
 
-	err = client.ProxyFile(context.Background(), "invalid://url", rr, req.Method)
+	err = client.ProxyFile(context.Background(), "invalid://url", rr, req, "")
 	if err == nil {
 		t.Error("Expected error for invalid URL, got nil")
 	}
@@ -384,3 +603,103 @@ func makeBaseURL(serverURL string) string {
 	u.Path = "/"
 	return u.String()
 }
+
+func TestListProjects(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/simple/" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", ContentTypeJSON)
+		if _, err := w.Write([]byte(`{"meta":{"api-version":"1.0"},"projects":[{"name":"demo"},{"name":"other"}]}`)); err != nil {
+			t.Errorf("Error writing response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	var names []string
+	err := client.ListProjects(context.Background(), server.URL+"/simple", func(name string) error {
+		names = append(names, name)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(names) != 2 || names[0] != "demo" || names[1] != "other" {
+		t.Errorf("Expected demo and other, got %v", names)
+	}
+
+	if err := client.ListProjects(context.Background(), server.URL+"/missing/", func(string) error { return nil }); err == nil {
+		t.Error("Expected error for missing listing")
+	}
+}
+
+func TestGetUploadTimes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/pypi/zope-interface/json" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		body := `{"info": {"name": "zope.interface"}, "releases": {
+			"6.0": [{"filename": "zope.interface-6.0.tar.gz", "upload_time_iso_8601": "2023-03-17T06:51:39.123456Z"}],
+			"6.1": [{"filename": "zope.interface-6.1.tar.gz", "upload_time_iso_8601": "2023-10-05T09:10:11Z"},
+			        {"filename": "broken.tar.gz", "upload_time_iso_8601": "yesterday"}]
+		}}`
+		if _, err := w.Write([]byte(body)); err != nil {
+			t.Errorf("Error writing response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	times, err := client.GetUploadTimes(context.Background(), server.URL+"/pypi", "Zope.Interface")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(times) != 2 {
+		t.Fatalf("Expected 2 upload times, got %v", times)
+	}
+	expected := time.Date(2023, 10, 5, 9, 10, 11, 0, time.UTC)
+	if !times["zope.interface-6.1.tar.gz"].Equal(expected) {
+		t.Errorf("Expected %v, got %v", expected, times["zope.interface-6.1.tar.gz"])
+	}
+
+	if _, err := client.GetUploadTimes(context.Background(), server.URL+"/pypi/", "missing"); err == nil {
+		t.Error("Expected error for missing package")
+	}
+}
+
+func TestGetProjectJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/pypi/zope-interface/json":
+			_, _ = w.Write([]byte(`{"info": {"version": "6.1"}}`))
+		case "/pypi/zope-interface/6.0/json":
+			_, _ = w.Write([]byte(`{"info": {"version": "6.0"}}`))
+		case "/pypi/broken/json":
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	body, err := client.GetProjectJSON(context.Background(), server.URL+"/pypi", "Zope.Interface", "")
+	if err != nil || string(body) != `{"info": {"version": "6.1"}}` {
+		t.Errorf("Expected the project JSON, got %q (err=%v)", body, err)
+	}
+	body, err = client.GetProjectJSON(context.Background(), server.URL+"/pypi/", "zope.interface", "6.0")
+	if err != nil || string(body) != `{"info": {"version": "6.0"}}` {
+		t.Errorf("Expected the release JSON, got %q (err=%v)", body, err)
+	}
+
+	if _, err := client.GetProjectJSON(context.Background(), server.URL+"/pypi/", "missing", ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a missing project, got %v", err)
+	}
+	if _, err := client.GetProjectJSON(context.Background(), server.URL+"/pypi/", "broken", ""); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected an upstream error, got %v", err)
+	}
+}
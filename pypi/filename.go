@@ -0,0 +1,164 @@
+package pypi
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DistributionType identifies the kind of distribution a file contains.
+type DistributionType string
+
+const (
+	// DistributionWheel is a PEP 427 built distribution.
+	DistributionWheel DistributionType = "wheel"
+	// DistributionSdist is a source distribution.
+	DistributionSdist DistributionType = "sdist"
+)
+
+// sdistExtensions lists the source distribution archive extensions, longest first.
+var sdistExtensions = []string{".tar.bz2", ".tar.gz", ".tar.xz", ".tgz", ".tar", ".zip"}
+
+// Distribution holds the components of a distribution file name.
+type Distribution struct {
+	Filename string
+	Type     DistributionType
+	// Name is the project name as written in the file name, which is not necessarily normalized.
+	Name    string
+	Version string
+	// BuildTag is the optional wheel build tag.
+	BuildTag     string
+	PythonTags   []string
+	ABITags      []string
+	PlatformTags []string
+	// Extension is the archive extension, e.g. ".whl" or ".tar.gz".
+	Extension string
+}
+
+// ProjectName returns the PEP 503 normalized project name.
+func (d Distribution) ProjectName() string {
+	return NormalizeName(d.Name)
+}
+
+// IsWheel reports whether the distribution is a wheel.
+func (d Distribution) IsWheel() bool {
+	return d.Type == DistributionWheel
+}
+
+// Tags returns the expanded "{python}-{abi}-{platform}" compatibility tags of
+// a wheel. Compressed tag sets such as "py2.py3-none-any" yield one tag per
+// combination. Source distributions have no tags.
+func (d Distribution) Tags() []string {
+	tags := make([]string, 0, len(d.PythonTags)*len(d.ABITags)*len(d.PlatformTags))
+	for _, py := range d.PythonTags {
+		for _, abi := range d.ABITags {
+			for _, platform := range d.PlatformTags {
+				tags = append(tags, strings.ToLower(py+"-"+abi+"-"+platform))
+			}
+		}
+	}
+	return tags
+}
+
+// IsPure reports whether a wheel is pure Python, i.e. built for no specific
+// ABI and platform, such as "py3-none-any".
+func (d Distribution) IsPure() bool {
+	if !d.IsWheel() {
+		return false
+	}
+	for _, abi := range d.ABITags {
+		if !strings.EqualFold(abi, "none") {
+			return false
+		}
+	}
+	for _, platform := range d.PlatformTags {
+		if !strings.EqualFold(platform, "any") {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseDistributionFilename parses a wheel (PEP 427) or source distribution
+// (PEP 625 and legacy) file name.
+// Example: "python-dateutil-2.9.0.tar.gz" -> name "python-dateutil", version "2.9.0".
+func ParseDistributionFilename(filename string) (Distribution, error) {
+	lower := strings.ToLower(filename)
+	if strings.HasSuffix(lower, ".whl") {
+		return parseWheelFilename(filename)
+	}
+
+	for _, ext := range sdistExtensions {
+		if !strings.HasSuffix(lower, ext) {
+			continue
+		}
+		name, version, err := splitNameVersion(filename[:len(filename)-len(ext)])
+		if err != nil {
+			return Distribution{}, fmt.Errorf("invalid sdist filename %q: %w", filename, err)
+		}
+		return Distribution{
+			Filename:  filename,
+			Type:      DistributionSdist,
+			Name:      name,
+			Version:   version,
+			Extension: filename[len(filename)-len(ext):],
+		}, nil
+	}
+
+	return Distribution{}, fmt.Errorf("unsupported distribution filename %q", filename)
+}
+
+// parseWheelFilename parses "{name}-{version}(-{build})?-{python}-{abi}-{platform}.whl".
+func parseWheelFilename(filename string) (Distribution, error) {
+	parts := strings.Split(filename[:len(filename)-len(".whl")], "-")
+	if len(parts) < 5 {
+		return Distribution{}, fmt.Errorf("invalid wheel filename %q", filename)
+	}
+
+	d := Distribution{
+		Filename:     filename,
+		Type:         DistributionWheel,
+		PythonTags:   strings.Split(parts[len(parts)-3], "."),
+		ABITags:      strings.Split(parts[len(parts)-2], "."),
+		PlatformTags: strings.Split(parts[len(parts)-1], "."),
+		Extension:    filename[len(filename)-len(".whl"):],
+	}
+
+	head := parts[:len(parts)-3]
+	switch {
+	case len(head) == 2 && IsValidVersion(head[1]):
+		d.Name, d.Version = head[0], head[1]
+	case len(head) == 3 && IsValidVersion(head[1]) && startsWithDigit(head[2]):
+		d.Name, d.Version, d.BuildTag = head[0], head[1], head[2]
+	default:
+		// Non-conforming wheels with unescaped dashes in the project name
+		name, version, err := splitNameVersion(strings.Join(head, "-"))
+		if err != nil {
+			return Distribution{}, fmt.Errorf("invalid wheel filename %q: %w", filename, err)
+		}
+		d.Name, d.Version = name, version
+	}
+
+	if d.Name == "" {
+		return Distribution{}, fmt.Errorf("invalid wheel filename %q: empty project name", filename)
+	}
+	return d, nil
+}
+
+// splitNameVersion splits "{name}-{version}" at the first dash that is
+// followed by a valid version, so legacy names containing dashes are kept whole.
+func splitNameVersion(stem string) (name, version string, err error) {
+	for i := 0; i < len(stem); i++ {
+		if stem[i] != '-' {
+			continue
+		}
+		if i > 0 && IsValidVersion(stem[i+1:]) {
+			return stem[:i], stem[i+1:], nil
+		}
+	}
+	return "", "", fmt.Errorf("no version found in %q", stem)
+}
+
+// startsWithDigit reports whether s begins with an ASCII digit.
+func startsWithDigit(s string) bool {
+	return s != "" && s[0] >= '0' && s[0] <= '9'
+}
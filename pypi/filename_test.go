@@ -0,0 +1,139 @@
+package pypi
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDistributionFilename(t *testing.T) {
+	tests := []struct {
+		filename string
+		expected Distribution
+	}{
+		{
+			filename: "requests-2.31.0.tar.gz",
+			expected: Distribution{Type: DistributionSdist, Name: "requests", Version: "2.31.0", Extension: ".tar.gz"},
+		},
+		{
+			filename: "python-dateutil-2.9.0.tar.gz",
+			expected: Distribution{Type: DistributionSdist, Name: "python-dateutil", Version: "2.9.0", Extension: ".tar.gz"},
+		},
+		{
+			filename: "python_dateutil-2.9.0.post0.tar.gz",
+			expected: Distribution{Type: DistributionSdist, Name: "python_dateutil", Version: "2.9.0.post0", Extension: ".tar.gz"},
+		},
+		{
+			filename: "py-3to2-1.1.1.zip",
+			expected: Distribution{Type: DistributionSdist, Name: "py-3to2", Version: "1.1.1", Extension: ".zip"},
+		},
+		{
+			filename: "Flask-3.0.0rc1.tar.bz2",
+			expected: Distribution{Type: DistributionSdist, Name: "Flask", Version: "3.0.0rc1", Extension: ".tar.bz2"},
+		},
+		{
+			filename: "pydantic-2.5.0-py3-none-any.whl",
+			expected: Distribution{
+				Type: DistributionWheel, Name: "pydantic", Version: "2.5.0", Extension: ".whl",
+				PythonTags: []string{"py3"}, ABITags: []string{"none"}, PlatformTags: []string{"any"},
+			},
+		},
+		{
+			filename: "numpy-1.26.0-1-cp312-cp312-manylinux_2_17_x86_64.manylinux2014_x86_64.whl",
+			expected: Distribution{
+				Type: DistributionWheel, Name: "numpy", Version: "1.26.0", BuildTag: "1", Extension: ".whl",
+				PythonTags: []string{"cp312"}, ABITags: []string{"cp312"},
+				PlatformTags: []string{"manylinux_2_17_x86_64", "manylinux2014_x86_64"},
+			},
+		},
+		{
+			filename: "six-1.16.0-py2.py3-none-any.whl",
+			expected: Distribution{
+				Type: DistributionWheel, Name: "six", Version: "1.16.0", Extension: ".whl",
+				PythonTags: []string{"py2", "py3"}, ABITags: []string{"none"}, PlatformTags: []string{"any"},
+			},
+		},
+		{
+			filename: "simple-package-1.0.0-py3-none-any.whl",
+			expected: Distribution{
+				Type: DistributionWheel, Name: "simple-package", Version: "1.0.0", Extension: ".whl",
+				PythonTags: []string{"py3"}, ABITags: []string{"none"}, PlatformTags: []string{"any"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			got, err := ParseDistributionFilename(tt.filename)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			tt.expected.Filename = tt.filename
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("ParseDistributionFilename(%q) = %+v, want %+v", tt.filename, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseDistributionFilenameInvalid(t *testing.T) {
+	for _, filename := range []string{".tar.gz", "noversion.tar.gz", "foo-bar.zip", "foo-1.0-py3.whl", "foo-1.0.txt"} {
+		t.Run(filename, func(t *testing.T) {
+			if _, err := ParseDistributionFilename(filename); err == nil {
+				t.Errorf("Expected error for %q", filename)
+			}
+		})
+	}
+}
+
+func TestDistributionProjectName(t *testing.T) {
+	d, err := ParseDistributionFilename("zope.interface-6.1.tar.gz")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if d.ProjectName() != "zope-interface" {
+		t.Errorf("Expected zope-interface, got %s", d.ProjectName())
+	}
+}
+
+func TestIsValidVersion(t *testing.T) {
+	for _, v := range []string{"1", "1.0", "2.9.0.post0", "1.0rc1", "1!2.0", "1.0.dev3", "v1.0", "1.0+local.7", "1.0-1"} {
+		if !IsValidVersion(v) {
+			t.Errorf("Expected %q to be a valid version", v)
+		}
+	}
+	for _, v := range []string{"", "dateutil-2.9.0", "abc", "1.0-beta-x"} {
+		if IsValidVersion(v) {
+			t.Errorf("Expected %q to be an invalid version", v)
+		}
+	}
+}
+
+func TestDistributionTags(t *testing.T) {
+	tests := []struct {
+		filename string
+		tags     string
+		pure     bool
+	}{
+		{"six-1.16.0-py2.py3-none-any.whl", "py2-none-any,py3-none-any", true},
+		{"numpy-2.0.0-cp312-cp312-manylinux_2_17_x86_64.manylinux2014_x86_64.whl", "cp312-cp312-manylinux_2_17_x86_64,cp312-cp312-manylinux2014_x86_64", false},
+		{"cffi-1.16.0-cp312-abi3-win_amd64.whl", "cp312-abi3-win_amd64", false},
+		{"demo-1.0-py3-none-macosx_11_0_arm64.whl", "py3-none-macosx_11_0_arm64", false},
+		{"demo-1.0.tar.gz", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			dist, err := ParseDistributionFilename(tt.filename)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if tags := strings.Join(dist.Tags(), ","); tags != tt.tags {
+				t.Errorf("Expected tags %s, got %s", tt.tags, tags)
+			}
+			if dist.IsPure() != tt.pure {
+				t.Errorf("Expected IsPure() = %t", tt.pure)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package pypi
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+)
+
+// maxListingTokenSize bounds a single anchor while scanning a root listing.
+const maxListingTokenSize = 1 << 20
+
+// ScanProjectList reads a Simple API root listing in either the JSON or the HTML
+// format and calls fn for every project name, without buffering the whole body.
+func ScanProjectList(r io.Reader, contentType string, fn func(name string) error) error {
+	if strings.Contains(contentType, "json") {
+		return scanProjectListJSON(r, fn)
+	}
+	return scanProjectListHTML(r, fn)
+}
+
+// scanProjectListJSON streams the projects array of a PEP 691 root listing.
+func scanProjectListJSON(r io.Reader, fn func(name string) error) error {
+	dec := json.NewDecoder(r)
+	if _, err := dec.Token(); err != nil {
+		return fmt.Errorf("error parsing JSON project list: %w", err)
+	}
+
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("error parsing JSON project list: %w", err)
+		}
+		if key != "projects" {
+			var skipped json.RawMessage
+			if err := dec.Decode(&skipped); err != nil {
+				return fmt.Errorf("error parsing JSON project list: %w", err)
+			}
+			continue
+		}
+
+		if _, err := dec.Token(); err != nil {
+			return fmt.Errorf("error parsing JSON project list: %w", err)
+		}
+		for dec.More() {
+			var project struct {
+				Name string `json:"name"`
+			}
+			if err := dec.Decode(&project); err != nil {
+				return fmt.Errorf("error parsing JSON project list: %w", err)
+			}
+			if project.Name == "" {
+				continue
+			}
+			if err := fn(project.Name); err != nil {
+				return err
+			}
+		}
+		if _, err := dec.Token(); err != nil {
+			return fmt.Errorf("error parsing JSON project list: %w", err)
+		}
+	}
+	return nil
+}
+
+// scanProjectListHTML streams the anchors of a PEP 503 root listing. The
+// project name is the text of each anchor.
+func scanProjectListHTML(r io.Reader, fn func(name string) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxListingTokenSize)
+	scanner.Split(splitAnchors)
+
+	for scanner.Scan() {
+		token := scanner.Bytes()
+		start := bytes.LastIndexByte(token, '>')
+		if start < 0 || !containsFold(token[:start], []byte("<a")) {
+			continue
+		}
+		name := strings.TrimSpace(html.UnescapeString(string(token[start+1:])))
+		if name == "" {
+			continue
+		}
+		if err := fn(name); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading project list: %w", err)
+	}
+	return nil
+}
+
+// splitAnchors is a bufio.SplitFunc that yields everything up to each closing
+// anchor tag, without the tag itself.
+func splitAnchors(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	const closing = "</a>"
+	if i := indexFold(data, []byte(closing)); i >= 0 {
+		return i + len(closing), data[:i], nil
+	}
+	if atEOF {
+		// Trailing markup after the last anchor holds no project
+		return len(data), nil, nil
+	}
+	return 0, nil, nil
+}
+
+// indexFold returns the index of the first ASCII case-insensitive match of sep in s.
+func indexFold(s, sep []byte) int {
+	for i := 0; i+len(sep) <= len(s); i++ {
+		if bytes.EqualFold(s[i:i+len(sep)], sep) {
+			return i
+		}
+	}
+	return -1
+}
+
+// containsFold reports whether sep occurs in s, ignoring ASCII case.
+func containsFold(s, sep []byte) bool {
+	return indexFold(s, sep) >= 0
+}
+
+// WriteRootPage writes a PEP 503 root listing of the given projects.
+func WriteRootPage(w io.Writer, projects []string) error {
+	bw := bufio.NewWriter(w)
+
+	bw.WriteString("<!DOCTYPE html>\n<html>\n  <head>\n")
+	bw.WriteString("    <meta name=\"pypi:repository-version\" content=\"1.0\">\n")
+	bw.WriteString("    <title>Simple index</title>\n  </head>\n  <body>\n")
+	for _, project := range projects {
+		escaped := html.EscapeString(project)
+		fmt.Fprintf(bw, "    <a href=\"%s/\">%s</a>\n", escaped, escaped)
+	}
+	bw.WriteString("  </body>\n</html>\n")
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("error writing project list: %w", err)
+	}
+	return nil
+}
+
+// WriteRootJSON writes a PEP 691 JSON root listing of the given projects.
+func WriteRootJSON(w io.Writer, projects []string) error {
+	bw := bufio.NewWriter(w)
+
+	bw.WriteString(`{"meta":{"api-version":"1.0"},"projects":[`)
+	for i, project := range projects {
+		name, err := json.Marshal(project)
+		if err != nil {
+			return fmt.Errorf("error encoding project %s: %w", project, err)
+		}
+		if i > 0 {
+			bw.WriteByte(',')
+		}
+		bw.WriteString(`{"name":`)
+		bw.Write(name)
+		bw.WriteByte('}')
+	}
+	bw.WriteString("]}\n")
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("error writing project list: %w", err)
+	}
+	return nil
+}
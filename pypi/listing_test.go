@@ -0,0 +1,68 @@
+package pypi
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestScanProjectList(t *testing.T) {
+	collect := func(body, contentType string) []string {
+		t.Helper()
+		var names []string
+		err := ScanProjectList(strings.NewReader(body), contentType, func(name string) error {
+			names = append(names, name)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		return names
+	}
+
+	htmlList := `<!DOCTYPE html><html><head><title>Simple index</title></head><body>
+<a href="/simple/requests/">requests</a>
+<A HREF="/simple/zope-interface/">zope.interface</A><a href="/simple/amp/">a&amp;b</a>
+</body></html>`
+	names := collect(htmlList, "text/html")
+	if strings.Join(names, ",") != "requests,zope.interface,a&b" {
+		t.Errorf("Unexpected HTML project names: %v", names)
+	}
+
+	jsonList := `{"meta": {"api-version": "1.1", "_last-serial": 1}, "projects": [{"name": "requests", "_last-serial": 2}, {"name": "Django"}, {"name": ""}]}`
+	names = collect(jsonList, ContentTypeJSON)
+	if strings.Join(names, ",") != "requests,Django" {
+		t.Errorf("Unexpected JSON project names: %v", names)
+	}
+
+	if err := ScanProjectList(strings.NewReader(`{"projects": [`), ContentTypeJSON, func(string) error { return nil }); err == nil {
+		t.Error("Expected error for truncated JSON listing")
+	}
+}
+
+func TestWriteRootListing(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteRootPage(&buf, []string{"requests", "a&b"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !strings.Contains(buf.String(), `<a href="requests/">requests</a>`) || !strings.Contains(buf.String(), `<a href="a&amp;b/">a&amp;b</a>`) {
+		t.Errorf("Unexpected HTML listing: %s", buf.String())
+	}
+
+	// The rendered listing scans back to the same projects
+	var names []string
+	if err := ScanProjectList(&buf, "text/html", func(name string) error {
+		names = append(names, name)
+		return nil
+	}); err != nil || strings.Join(names, ",") != "requests,a&b" {
+		t.Errorf("Expected listing to round-trip, got %v (%v)", names, err)
+	}
+
+	buf.Reset()
+	if err := WriteRootJSON(&buf, []string{"requests"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if strings.TrimSpace(buf.String()) != `{"meta":{"api-version":"1.0"},"projects":[{"name":"requests"}]}` {
+		t.Errorf("Unexpected JSON listing: %s", buf.String())
+	}
+}
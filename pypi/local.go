@@ -0,0 +1,180 @@
+package pypi
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// localIndexTransport serves indexes kept in a local directory, at file://
+// URLs, as a PEP 503 simple API. The directory holds one subdirectory per
+// project with its distribution files, as stored by uploads: directories of
+// subdirectories are root listings, the others project pages linking their
+// files with a sha256 hash. Names starting with a dot, such as uploads in
+// progress, are never listed or served.
+type localIndexTransport struct {
+	mu      sync.Mutex
+	digests map[string]localDigest
+}
+
+// localDigest is the SHA-256 digest of a local file, valid as long as its
+// size and modification time are unchanged.
+type localDigest struct {
+	size    int64
+	modTime time.Time
+	digest  string
+}
+
+// newLocalIndexTransport creates a transport for file:// index URLs.
+func newLocalIndexTransport() *localIndexTransport {
+	return &localIndexTransport{digests: make(map[string]localDigest)}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *localIndexTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return localResponse(req, http.StatusMethodNotAllowed, nil, "text/plain", 0), nil
+	}
+
+	path := filepath.Clean(filepath.FromSlash(req.URL.Path))
+	for _, part := range strings.Split(filepath.ToSlash(path), "/") {
+		if strings.HasPrefix(part, ".") {
+			return localResponse(req, http.StatusNotFound, nil, "text/plain", 0), nil
+		}
+	}
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return localResponse(req, http.StatusNotFound, nil, "text/plain", 0), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		var body io.ReadCloser = f
+		if req.Method == http.MethodHead {
+			_ = f.Close()
+			body = nil
+		}
+		return localResponse(req, http.StatusOK, body, "application/octet-stream", info.Size()), nil
+	}
+
+	page, err := t.listing(path)
+	if err != nil {
+		return nil, err
+	}
+	var body io.ReadCloser
+	if req.Method == http.MethodGet {
+		body = io.NopCloser(bytes.NewReader(page))
+	}
+	return localResponse(req, http.StatusOK, body, "text/html", int64(len(page))), nil
+}
+
+// listing renders the simple API page of a local directory.
+func (t *localIndexTransport) listing(dir string) ([]byte, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var projects []string
+	var files []File
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+		if entry.IsDir() {
+			projects = append(projects, name)
+			continue
+		}
+		digest, err := t.digest(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, File{Filename: name, URL: url.PathEscape(name) + "#sha256=" + digest})
+	}
+
+	if len(files) == 0 && len(projects) > 0 {
+		sort.Strings(projects)
+		var b bytes.Buffer
+		if err := WriteRootPage(&b, projects); err != nil {
+			return nil, err
+		}
+		return b.Bytes(), nil
+	}
+	return RenderProjectPage(filepath.Base(dir), files), nil
+}
+
+// digest returns the SHA-256 digest of a local file, hashing it only when it
+// changed since it was last hashed.
+func (t *localIndexTransport) digest(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	t.mu.Lock()
+	cached, ok := t.digests[path]
+	t.mu.Unlock()
+	if ok && cached.size == info.Size() && cached.modTime.Equal(info.ModTime()) {
+		return cached.digest, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = f.Close() // read-only file, nothing to flush
+	}()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", fmt.Errorf("error hashing %s: %w", path, err)
+	}
+	digest := hex.EncodeToString(hash.Sum(nil))
+
+	t.mu.Lock()
+	t.digests[path] = localDigest{size: info.Size(), modTime: info.ModTime(), digest: digest}
+	t.mu.Unlock()
+	return digest, nil
+}
+
+// localResponse builds the response to a request for a local index.
+func localResponse(req *http.Request, status int, body io.ReadCloser, contentType string, length int64) *http.Response {
+	if body == nil {
+		body = http.NoBody
+	}
+	header := make(http.Header)
+	header.Set("Content-Type", contentType)
+	header.Set("Content-Length", strconv.FormatInt(length, 10))
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          body,
+		ContentLength: length,
+		Request:       req,
+	}
+}
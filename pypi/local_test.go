@@ -0,0 +1,68 @@
+package pypi
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalIndex(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "demo", ".upload-123"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "demo", "demo-1.0+local.tar.gz"), []byte("sdist"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "other"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte("sdist"))
+	digest := hex.EncodeToString(sum[:])
+
+	ctx := context.Background()
+	client := NewClient()
+	baseURL := "file://" + filepath.ToSlash(dir) + "/"
+
+	for name, expected := range map[string]bool{"demo": true, "missing": false} {
+		exists, err := client.PackageExists(ctx, baseURL, name)
+		if err != nil || exists != expected {
+			t.Errorf("PackageExists(%s) = %v, %v; expected %v", name, exists, err, expected)
+		}
+	}
+
+	page, err := client.GetPackagePage(ctx, baseURL, "demo")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !strings.Contains(string(page), `href="demo-1.0+local.tar.gz#sha256=`+digest+`"`) {
+		t.Errorf("Expected the file to be linked with its digest, got %s", page)
+	}
+	if strings.Contains(string(page), ".upload") {
+		t.Errorf("Expected uploads in progress not to be listed, got %s", page)
+	}
+
+	var projects []string
+	if err := client.ListProjects(ctx, baseURL, func(name string) error {
+		projects = append(projects, name)
+		return nil
+	}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if strings.Join(projects, ",") != "demo,other" {
+		t.Errorf("Unexpected projects: %v", projects)
+	}
+
+	var b bytes.Buffer
+	if err := client.FetchFile(ctx, baseURL+"demo/demo-1.0%2Blocal.tar.gz", &b, digest); err != nil || b.String() != "sdist" {
+		t.Errorf("Expected the file to be fetched, got %q (err=%v)", b.String(), err)
+	}
+	if _, err := client.GetPackageFile(ctx, baseURL+"demo/.upload-123"); err == nil {
+		t.Error("Expected hidden paths not to be served")
+	}
+}
@@ -0,0 +1,15 @@
+package pypi
+
+import (
+	"regexp"
+	"strings"
+)
+
+// nameSeparators matches runs of the separators PEP 503 treats as equivalent.
+var nameSeparators = regexp.MustCompile(`[-_.]+`)
+
+// NormalizeName returns the PEP 503 normalized form of a project name.
+// Example: "Zope.Interface" -> "zope-interface".
+func NormalizeName(name string) string {
+	return strings.ToLower(nameSeparators.ReplaceAllString(name, "-"))
+}
@@ -0,0 +1,25 @@
+package pypi
+
+import "testing"
+
+func TestNormalizeName(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"requests", "requests"},
+		{"Zope.Interface", "zope-interface"},
+		{"zope_interface", "zope-interface"},
+		{"zope-interface", "zope-interface"},
+		{"Friendly--Bard", "friendly-bard"},
+		{"FRIENDLY._-bard", "friendly-bard"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NormalizeName(tt.name); got != tt.expected {
+				t.Errorf("NormalizeName(%q) = %q, want %q", tt.name, got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package pypi
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+)
+
+// File represents a distribution file listed on a Simple API project page.
+type File struct {
+	// Filename is the distribution file name, e.g. "requests-2.31.0.tar.gz".
+	Filename string
+	// URL is the link target as published by the index, including any hash fragment.
+	URL string
+	// Attrs holds the remaining anchor attributes, such as data-requires-python.
+	Attrs map[string]string
+	// Origin is the name of the index the file was listed by on merged pages.
+	// It is rendered as the data-origin attribute of the anchor.
+	Origin string
+	// UploadTime is the PEP 700 upload time reported by JSON indexes, if any.
+	UploadTime string
+	// Size is the file size in bytes reported by JSON indexes, or 0 when unknown.
+	Size int64
+}
+
+// Hash returns the hash published in the URL fragment of a file, such as
+// "sha256" and its hex digest.
+func (f File) Hash() (name, value string, ok bool) {
+	name, value, ok = strings.Cut(fragment(f.URL), "=")
+	if !ok || name == "" || value == "" {
+		return "", "", false
+	}
+	return name, value, true
+}
+
+// SHA256 returns the SHA-256 digest published in the URL fragment of a file,
+// or an empty string when the file has none.
+func (f File) SHA256() string {
+	if name, value, ok := f.Hash(); ok && name == "sha256" {
+		return value
+	}
+	return ""
+}
+
+// Yanked reports whether a file is yanked (PEP 592) and returns the reason,
+// which may be empty.
+func (f File) Yanked() (reason string, yanked bool) {
+	reason, yanked = f.Attrs["data-yanked"]
+	return reason, yanked
+}
+
+// Yank marks a file as yanked. The reason is appended to the reason of a file
+// that is already yanked.
+func (f *File) Yank(reason string) {
+	if f.Attrs == nil {
+		f.Attrs = make(map[string]string)
+	}
+	if existing := f.Attrs["data-yanked"]; existing != "" && existing != reason {
+		reason = existing + "; " + reason
+	}
+	f.Attrs["data-yanked"] = reason
+}
+
+// RenderProjectPage renders a PEP 503 project page listing the given files.
+// Files with an origin are tagged with a data-origin attribute.
+func RenderProjectPage(project string, files []File) []byte {
+	var b strings.Builder
+
+	b.WriteString("<!DOCTYPE html>\n<html>\n  <head>\n")
+	b.WriteString("    <meta name=\"pypi:repository-version\" content=\"1.0\">\n")
+	fmt.Fprintf(&b, "    <title>Links for %s</title>\n", html.EscapeString(project))
+	b.WriteString("  </head>\n  <body>\n")
+	fmt.Fprintf(&b, "    <h1>Links for %s</h1>\n", html.EscapeString(project))
+
+	for _, f := range files {
+		fmt.Fprintf(&b, "    <a href=\"%s\"", html.EscapeString(f.URL))
+
+		attrs := f.Attrs
+		if f.Origin != "" {
+			attrs = make(map[string]string, len(f.Attrs)+1)
+			for k, v := range f.Attrs {
+				attrs[k] = v
+			}
+			attrs["data-origin"] = f.Origin
+		}
+
+		keys := make([]string, 0, len(attrs))
+		for k := range attrs {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(&b, " %s=\"%s\"", k, html.EscapeString(attrs[k]))
+		}
+
+		fmt.Fprintf(&b, ">%s</a><br/>\n", html.EscapeString(f.Filename))
+	}
+
+	b.WriteString("  </body>\n</html>\n")
+	return []byte(b.String())
+}
@@ -0,0 +1,246 @@
+package pypi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	// ContentTypeJSON is the PEP 691 JSON media type of the Simple API.
+	ContentTypeJSON = "application/vnd.pypi.simple.v1+json"
+	// ContentTypeHTML is the PEP 691 HTML media type of the Simple API.
+	ContentTypeHTML = "application/vnd.pypi.simple.v1+html"
+	// ContentTypeLegacyHTML is the media type served by PEP 503 indexes.
+	ContentTypeLegacyHTML = "text/html"
+
+	// AcceptSimple is the Accept header sent upstream, preferring JSON and
+	// falling back to HTML for indexes that do not implement PEP 691.
+	AcceptSimple = ContentTypeJSON + ", " + ContentTypeHTML + ";q=0.2, " + ContentTypeLegacyHTML + ";q=0.01"
+)
+
+// projectJSON is the PEP 691 representation of a project page.
+type projectJSON struct {
+	Meta  metaJSON   `json:"meta"`
+	Name  string     `json:"name"`
+	Files []fileJSON `json:"files"`
+}
+
+// metaJSON holds the PEP 691 response metadata.
+type metaJSON struct {
+	APIVersion string `json:"api-version"`
+}
+
+// fileJSON is a single file entry of a PEP 691 project page. The variant
+// fields may be booleans, strings or hash dictionaries.
+type fileJSON struct {
+	Filename         string            `json:"filename"`
+	URL              string            `json:"url"`
+	Hashes           map[string]string `json:"hashes"`
+	RequiresPython   *string           `json:"requires-python,omitempty"`
+	CoreMetadata     json.RawMessage   `json:"core-metadata,omitempty"`
+	DistInfoMetadata json.RawMessage   `json:"dist-info-metadata,omitempty"`
+	GPGSig           *bool             `json:"gpg-sig,omitempty"`
+	Yanked           json.RawMessage   `json:"yanked,omitempty"`
+	UploadTime       string            `json:"upload-time,omitempty"`
+	Size             int64             `json:"size,omitempty"`
+}
+
+// IsJSONPage reports whether a project page body is a PEP 691 JSON document.
+func IsJSONPage(body []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(body), []byte("{"))
+}
+
+// ParseProjectJSON extracts the distribution files listed on a PEP 691 JSON project page.
+// Files are mapped onto the HTML model: hashes become the URL fragment and the
+// remaining fields become data-* attributes.
+func ParseProjectJSON(body []byte) ([]File, error) {
+	var page projectJSON
+	if err := json.Unmarshal(body, &page); err != nil {
+		return nil, fmt.Errorf("error parsing JSON project page: %w", err)
+	}
+
+	files := make([]File, 0, len(page.Files))
+	for _, fj := range page.Files {
+		if fj.Filename == "" || fj.URL == "" {
+			continue
+		}
+
+		f := File{
+			Filename:   fj.Filename,
+			URL:        fj.URL,
+			Attrs:      make(map[string]string),
+			UploadTime: fj.UploadTime,
+			Size:       fj.Size,
+		}
+		if name, value, ok := preferredHash(fj.Hashes); ok {
+			f.URL = stripFragment(f.URL) + "#" + name + "=" + value
+		}
+		if fj.RequiresPython != nil && *fj.RequiresPython != "" {
+			f.Attrs["data-requires-python"] = *fj.RequiresPython
+		}
+		if fj.GPGSig != nil {
+			f.Attrs["data-gpg-sig"] = fmt.Sprintf("%t", *fj.GPGSig)
+		}
+		if reason, ok := decodeYanked(fj.Yanked); ok {
+			f.Attrs["data-yanked"] = reason
+		}
+		metadata := fj.CoreMetadata
+		if len(metadata) == 0 {
+			metadata = fj.DistInfoMetadata
+		}
+		if value, ok := decodeMetadata(metadata); ok {
+			f.Attrs["data-core-metadata"] = value
+			f.Attrs["data-dist-info-metadata"] = value
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+// RenderProjectJSON renders a PEP 691 JSON project page listing the given files.
+func RenderProjectJSON(project string, files []File) ([]byte, error) {
+	page := projectJSON{
+		Meta:  metaJSON{APIVersion: "1.0"},
+		Name:  project,
+		Files: make([]fileJSON, 0, len(files)),
+	}
+
+	for _, f := range files {
+		fj := fileJSON{
+			Filename:   f.Filename,
+			URL:        stripFragment(f.URL),
+			Hashes:     make(map[string]string),
+			UploadTime: f.UploadTime,
+			Size:       f.Size,
+		}
+		if name, value, ok := strings.Cut(fragment(f.URL), "="); ok && value != "" {
+			fj.Hashes[name] = value
+		}
+		if rp, ok := f.Attrs["data-requires-python"]; ok {
+			fj.RequiresPython = &rp
+		}
+		if sig, ok := f.Attrs["data-gpg-sig"]; ok {
+			hasSig := sig == "true"
+			fj.GPGSig = &hasSig
+		}
+
+		yanked := json.RawMessage("false")
+		if reason, ok := f.Attrs["data-yanked"]; ok {
+			yanked = encodeYanked(reason)
+		}
+		fj.Yanked = yanked
+
+		metadata, ok := f.Attrs["data-core-metadata"]
+		if !ok {
+			metadata, ok = f.Attrs["data-dist-info-metadata"]
+		}
+		if ok {
+			fj.CoreMetadata = encodeMetadata(metadata)
+			fj.DistInfoMetadata = fj.CoreMetadata
+		}
+
+		page.Files = append(page.Files, fj)
+	}
+
+	body, err := json.Marshal(page)
+	if err != nil {
+		return nil, fmt.Errorf("error rendering JSON project page: %w", err)
+	}
+	return body, nil
+}
+
+// preferredHash returns the hash to publish in a URL fragment, preferring sha256.
+func preferredHash(hashes map[string]string) (name, value string, ok bool) {
+	if v, found := hashes["sha256"]; found {
+		return "sha256", v, true
+	}
+	names := make([]string, 0, len(hashes))
+	for n := range hashes {
+		names = append(names, n)
+	}
+	if len(names) == 0 {
+		return "", "", false
+	}
+	sort.Strings(names)
+	return names[0], hashes[names[0]], true
+}
+
+// decodeYanked converts the JSON yanked field to a data-yanked value.
+func decodeYanked(raw json.RawMessage) (reason string, yanked bool) {
+	if len(raw) == 0 {
+		return "", false
+	}
+	if err := json.Unmarshal(raw, &reason); err == nil {
+		return reason, true
+	}
+	if err := json.Unmarshal(raw, &yanked); err == nil {
+		return "", yanked
+	}
+	return "", false
+}
+
+// encodeYanked converts a data-yanked value to the JSON yanked field.
+func encodeYanked(reason string) json.RawMessage {
+	if reason == "" {
+		return json.RawMessage("true")
+	}
+	raw, err := json.Marshal(reason)
+	if err != nil {
+		return json.RawMessage("true")
+	}
+	return raw
+}
+
+// decodeMetadata converts a JSON core-metadata field to a data-core-metadata value.
+func decodeMetadata(raw json.RawMessage) (string, bool) {
+	if len(raw) == 0 {
+		return "", false
+	}
+	var available bool
+	if err := json.Unmarshal(raw, &available); err == nil {
+		if available {
+			return "true", true
+		}
+		return "", false
+	}
+	var hashes map[string]string
+	if err := json.Unmarshal(raw, &hashes); err == nil {
+		if name, value, ok := preferredHash(hashes); ok {
+			return name + "=" + value, true
+		}
+		return "true", true
+	}
+	return "", false
+}
+
+// encodeMetadata converts a data-core-metadata value to the JSON core-metadata field.
+func encodeMetadata(value string) json.RawMessage {
+	name, hash, ok := strings.Cut(value, "=")
+	if !ok || hash == "" {
+		return json.RawMessage("true")
+	}
+	raw, err := json.Marshal(map[string]string{name: hash})
+	if err != nil {
+		return json.RawMessage("true")
+	}
+	return raw
+}
+
+// stripFragment removes the fragment from a URL.
+func stripFragment(rawURL string) string {
+	if i := strings.IndexByte(rawURL, '#'); i >= 0 {
+		return rawURL[:i]
+	}
+	return rawURL
+}
+
+// fragment returns the fragment of a URL without the leading '#'.
+func fragment(rawURL string) string {
+	if i := strings.IndexByte(rawURL, '#'); i >= 0 {
+		return rawURL[i+1:]
+	}
+	return ""
+}
@@ -0,0 +1,125 @@
+package pypi
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseProjectJSON(t *testing.T) {
+	page := `{
+  "meta": {"api-version": "1.1"},
+  "name": "demo",
+  "files": [
+    {
+      "filename": "demo-1.0.tar.gz",
+      "url": "https://files.example.com/demo-1.0.tar.gz",
+      "hashes": {"sha256": "abc", "md5": "def"},
+      "requires-python": ">=3.8",
+      "core-metadata": {"sha256": "meta"},
+      "gpg-sig": false,
+      "yanked": "broken build",
+      "upload-time": "2024-01-02T03:04:05.000000Z",
+      "size": 1234
+    },
+    {
+      "filename": "demo-1.0-py3-none-any.whl",
+      "url": "../../packages/demo-1.0-py3-none-any.whl",
+      "hashes": {},
+      "dist-info-metadata": true,
+      "yanked": false
+    },
+    {"filename": "", "url": "ignored"}
+  ]
+}`
+
+	files, err := ParseProjectJSON([]byte(page))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("Expected 2 files, got %d", len(files))
+	}
+
+	sdist := files[0]
+	if sdist.URL != "https://files.example.com/demo-1.0.tar.gz#sha256=abc" {
+		t.Errorf("Expected sha256 fragment, got %s", sdist.URL)
+	}
+	if sdist.Attrs["data-requires-python"] != ">=3.8" {
+		t.Errorf("Expected requires-python attribute, got %q", sdist.Attrs["data-requires-python"])
+	}
+	if sdist.Attrs["data-yanked"] != "broken build" {
+		t.Errorf("Expected yank reason, got %q", sdist.Attrs["data-yanked"])
+	}
+	if sdist.Attrs["data-core-metadata"] != "sha256=meta" || sdist.Attrs["data-gpg-sig"] != "false" {
+		t.Errorf("Expected metadata and gpg-sig attributes, got %v", sdist.Attrs)
+	}
+	if sdist.UploadTime != "2024-01-02T03:04:05.000000Z" || sdist.Size != 1234 {
+		t.Errorf("Expected upload time and size, got %q %d", sdist.UploadTime, sdist.Size)
+	}
+
+	wheel := files[1]
+	if _, ok := wheel.Attrs["data-yanked"]; ok {
+		t.Error("Expected non-yanked wheel without data-yanked")
+	}
+	if wheel.Attrs["data-dist-info-metadata"] != "true" {
+		t.Errorf("Expected legacy metadata flag to be kept, got %v", wheel.Attrs)
+	}
+
+	if _, err := ParseProjectJSON([]byte("{not json")); err == nil {
+		t.Error("Expected error for invalid JSON")
+	}
+}
+
+func TestRenderProjectJSON(t *testing.T) {
+	files := []File{
+		{
+			Filename: "demo-1.0.tar.gz",
+			URL:      "/packages/demo-1.0.tar.gz#sha256=abc",
+			Attrs: map[string]string{
+				"data-requires-python": ">=3.8",
+				"data-yanked":          "",
+				"data-core-metadata":   "sha256=meta",
+			},
+		},
+		{Filename: "demo-2.0.tar.gz", URL: "/packages/demo-2.0.tar.gz", Attrs: map[string]string{"data-yanked": "security issue"}},
+	}
+
+	body, err := RenderProjectJSON("demo", files)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var page map[string]any
+	if err := json.Unmarshal(body, &page); err != nil {
+		t.Fatalf("Expected valid JSON, got %v", err)
+	}
+	if page["name"] != "demo" || page["meta"].(map[string]any)["api-version"] != "1.0" {
+		t.Errorf("Expected name and api-version, got %v", page)
+	}
+
+	rendered := page["files"].([]any)
+	first := rendered[0].(map[string]any)
+	if first["url"] != "/packages/demo-1.0.tar.gz" {
+		t.Errorf("Expected URL without fragment, got %v", first["url"])
+	}
+	if first["hashes"].(map[string]any)["sha256"] != "abc" {
+		t.Errorf("Expected hashes from fragment, got %v", first["hashes"])
+	}
+	if first["yanked"] != true || first["requires-python"] != ">=3.8" {
+		t.Errorf("Expected yanked and requires-python, got %v", first)
+	}
+	if first["core-metadata"].(map[string]any)["sha256"] != "meta" {
+		t.Errorf("Expected core-metadata hash, got %v", first["core-metadata"])
+	}
+
+	second := rendered[1].(map[string]any)
+	if second["yanked"] != "security issue" || len(second["hashes"].(map[string]any)) != 0 {
+		t.Errorf("Expected yank reason and empty hashes, got %v", second)
+	}
+
+	// Rendering round-trips through the parser
+	parsed, err := ParseProjectJSON(body)
+	if err != nil || len(parsed) != 2 || parsed[0].URL != files[0].URL || parsed[1].Attrs["data-yanked"] != "security issue" {
+		t.Errorf("Expected rendered JSON to parse back, got %+v (%v)", parsed, err)
+	}
+}
@@ -0,0 +1,53 @@
+package pypi
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderProjectPage(t *testing.T) {
+	files := []File{
+		{Filename: "demo-1.0.tar.gz", URL: "/packages/demo-1.0.tar.gz#sha256=abc", Attrs: map[string]string{"data-requires-python": ">=3.8"}},
+	}
+
+	page := string(RenderProjectPage("demo", files))
+	if !strings.Contains(page, "<title>Links for demo</title>") {
+		t.Error("Expected page title")
+	}
+	expected := `<a href="/packages/demo-1.0.tar.gz#sha256=abc" data-requires-python="&gt;=3.8">demo-1.0.tar.gz</a><br/>`
+	if !strings.Contains(page, expected) {
+		t.Errorf("Expected rendered anchor %s, got %s", expected, page)
+	}
+
+	files[0].Origin = "team"
+	page = string(RenderProjectPage("demo", files))
+	expected = `<a href="/packages/demo-1.0.tar.gz#sha256=abc" data-origin="team" data-requires-python="&gt;=3.8">demo-1.0.tar.gz</a><br/>`
+	if !strings.Contains(page, expected) {
+		t.Errorf("Expected anchor tagged with its origin, got %s", page)
+	}
+	if _, ok := files[0].Attrs["data-origin"]; ok {
+		t.Error("Expected rendering not to modify the file attributes")
+	}
+}
+
+func TestFileYank(t *testing.T) {
+	f := File{Filename: "demo-1.0.tar.gz"}
+	if _, yanked := f.Yanked(); yanked {
+		t.Fatal("Expected a file without data-yanked not to be yanked")
+	}
+
+	f.Yank("broken build")
+	if reason, yanked := f.Yanked(); !yanked || reason != "broken build" {
+		t.Errorf("Expected yank reason, got %q (yanked %t)", reason, yanked)
+	}
+	f.Yank("CVE-2024-0001")
+	if reason, _ := f.Yanked(); reason != "broken build; CVE-2024-0001" {
+		t.Errorf("Expected reasons to be combined, got %q", reason)
+	}
+
+	upstream := File{Filename: "demo-2.0.tar.gz", Attrs: map[string]string{"data-yanked": ""}}
+	upstream.Yank("local")
+	if reason, _ := upstream.Yanked(); reason != "local" {
+		t.Errorf("Expected a local reason on an upstream yank without reason, got %q", reason)
+	}
+}
@@ -0,0 +1,160 @@
+package pypi
+
+import (
+	"fmt"
+	"strings"
+)
+
+// specifierOperators lists the PEP 440 comparison operators, longest first.
+var specifierOperators = []string{"===", "~=", "==", "!=", "<=", ">=", "<", ">"}
+
+// Specifier is a single PEP 440 version clause such as ">=1.0" or "==2.*".
+type Specifier struct {
+	Operator string
+	// Raw is the version as written, used by the arbitrary equality operator.
+	Raw     string
+	version Version
+	// wildcard marks "==" and "!=" clauses ending in ".*".
+	wildcard bool
+}
+
+// SpecifierSet is a comma-separated list of specifiers that must all match.
+// An empty set matches every version.
+type SpecifierSet []Specifier
+
+// ParseSpecifierSet parses a PEP 440 version specifier such as ">=1.0,!=1.3.*,<2".
+func ParseSpecifierSet(spec string) (SpecifierSet, error) {
+	var set SpecifierSet
+	for _, clause := range strings.Split(spec, ",") {
+		clause = strings.TrimSpace(clause)
+		if clause == "" {
+			continue
+		}
+		s, err := parseSpecifier(clause)
+		if err != nil {
+			return nil, err
+		}
+		set = append(set, s)
+	}
+	return set, nil
+}
+
+// parseSpecifier parses a single version clause.
+func parseSpecifier(clause string) (Specifier, error) {
+	var s Specifier
+	for _, op := range specifierOperators {
+		if strings.HasPrefix(clause, op) {
+			s.Operator = op
+			break
+		}
+	}
+	if s.Operator == "" {
+		return Specifier{}, fmt.Errorf("invalid version specifier %q: missing operator", clause)
+	}
+	s.Raw = strings.TrimSpace(strings.TrimPrefix(clause, s.Operator))
+	if s.Operator == "===" {
+		return s, nil
+	}
+
+	raw := s.Raw
+	if s.Operator == "==" || s.Operator == "!=" {
+		raw, s.wildcard = strings.CutSuffix(raw, ".*")
+	}
+	v, err := ParseVersion(raw)
+	if err != nil {
+		return Specifier{}, fmt.Errorf("invalid version specifier %q: %w", clause, err)
+	}
+	if s.wildcard && (v.PreLabel != "" || v.Post >= 0 || v.Dev >= 0 || len(v.Local) > 0) {
+		return Specifier{}, fmt.Errorf("invalid version specifier %q: prefix matches only take release segments", clause)
+	}
+	if s.Operator == "~=" && len(v.Release) < 2 {
+		return Specifier{}, fmt.Errorf("invalid version specifier %q: ~= needs at least two release segments", clause)
+	}
+	s.version = v
+	return s, nil
+}
+
+// Contains reports whether a version satisfies every specifier in the set.
+// Pre-releases are matched like any other version.
+func (set SpecifierSet) Contains(v Version) bool {
+	for _, s := range set {
+		if !s.Contains(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the specifiers joined by commas.
+func (set SpecifierSet) String() string {
+	clauses := make([]string, len(set))
+	for i, s := range set {
+		clauses[i] = s.String()
+	}
+	return strings.Join(clauses, ",")
+}
+
+// String returns the specifier as written.
+func (s Specifier) String() string {
+	return s.Operator + s.Raw
+}
+
+// Contains reports whether a version satisfies the specifier.
+func (s Specifier) Contains(v Version) bool {
+	switch s.Operator {
+	case "===":
+		return strings.EqualFold(v.String(), s.Raw)
+	case "==":
+		return s.equals(v)
+	case "!=":
+		return !s.equals(v)
+	case "~=":
+		prefix := Specifier{version: Version{Epoch: s.version.Epoch, Release: s.version.Release[:len(s.version.Release)-1]}, wildcard: true}
+		return v.Public().Compare(s.version) >= 0 && prefix.equals(v)
+	case "<=":
+		return v.Public().Compare(s.version) <= 0
+	case ">=":
+		return v.Public().Compare(s.version) >= 0
+	case "<":
+		if v.Public().Compare(s.version) >= 0 {
+			return false
+		}
+		// "<V" excludes pre-releases of V itself unless V is a pre-release
+		return s.version.IsPrerelease() || !v.IsPrerelease() || v.BaseVersion().Compare(s.version.BaseVersion()) != 0
+	case ">":
+		if v.Public().Compare(s.version) <= 0 {
+			return false
+		}
+		// ">V" excludes post-releases and local versions of V itself
+		if !s.version.IsPostRelease() && v.IsPostRelease() && v.BaseVersion().Compare(s.version.BaseVersion()) == 0 {
+			return false
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// equals implements "==", including prefix matching of wildcard clauses.
+// Local version labels are ignored unless the clause has one.
+func (s Specifier) equals(v Version) bool {
+	if !s.wildcard {
+		if len(s.version.Local) == 0 {
+			v = v.Public()
+		}
+		return v.Compare(s.version) == 0
+	}
+	if v.Epoch != s.version.Epoch {
+		return false
+	}
+	for i, n := range s.version.Release {
+		var segment int
+		if i < len(v.Release) {
+			segment = v.Release[i]
+		}
+		if segment != n {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,113 @@
+package pypi
+
+import "testing"
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		version  string
+		expected string
+	}{
+		{"1.0", "1.0"},
+		{"v1.0", "1.0"},
+		{"1!2.0", "1!2.0"},
+		{"1.0-alpha.2", "1.0a2"},
+		{"1.0c1", "1.0rc1"},
+		{"1.0-1", "1.0.post1"},
+		{"1.0.post", "1.0.post0"},
+		{"1.0-dev", "1.0.dev0"},
+		{"1.0+Ubuntu-1", "1.0+ubuntu.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			v, err := ParseVersion(tt.version)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if v.String() != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, v.String())
+			}
+		})
+	}
+
+	if _, err := ParseVersion("not-a-version"); err == nil {
+		t.Error("Expected an error for an invalid version")
+	}
+}
+
+func TestVersionCompare(t *testing.T) {
+	// Each version sorts strictly before the next one
+	ordered := []string{
+		"1.0.dev0", "1.0a1.dev0", "1.0a1", "1.0a2", "1.0b1", "1.0rc1", "1.0",
+		"1.0+abc", "1.0+5", "1.0.post1.dev0", "1.0.post1", "1.1", "2.0", "1!0.5",
+	}
+	for i := 0; i+1 < len(ordered); i++ {
+		a, _ := ParseVersion(ordered[i])
+		b, _ := ParseVersion(ordered[i+1])
+		if a.Compare(b) != -1 || b.Compare(a) != 1 {
+			t.Errorf("Expected %s < %s", ordered[i], ordered[i+1])
+		}
+	}
+
+	a, _ := ParseVersion("1.0")
+	b, _ := ParseVersion("1.0.0")
+	if a.Compare(b) != 0 {
+		t.Error("Expected trailing zeros to be ignored")
+	}
+}
+
+func TestSpecifierSetContains(t *testing.T) {
+	tests := []struct {
+		spec     string
+		version  string
+		expected bool
+	}{
+		{"==2.0.1", "2.0.1", true},
+		{"==2.0.1", "2.0.1+local", true},
+		{"==2.0.1+local", "2.0.1", false},
+		{"==2.0", "2.0.0", true},
+		{"==2.*", "2.5.1", true},
+		{"==2.*", "3.0", false},
+		{"!=1.3.*", "1.3.4", false},
+		{"!=1.3.*", "1.4", true},
+		{"~=1.4.2", "1.4.9", true},
+		{"~=1.4.2", "1.5.0", false},
+		{"~=1.4", "1.9", true},
+		{">=1.0,<1.2", "1.1.5", true},
+		{">=1.0,<1.2", "1.2", false},
+		{"<2.0", "2.0b1", false},
+		{"<2.0", "1.9rc1", true},
+		{"<2.0rc1", "2.0b1", true},
+		{">1.0", "1.0.post1", false},
+		{">1.0", "1.0+local", false},
+		{">1.0", "1.0.1", true},
+		{">1.0.post1", "1.0.post2", true},
+		{"<=1.0", "1.0+local", true},
+		{"===1.0", "1.0", true},
+		{"", "0.1", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec+" "+tt.version, func(t *testing.T) {
+			set, err := ParseSpecifierSet(tt.spec)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			v, err := ParseVersion(tt.version)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if result := set.Contains(v); result != tt.expected {
+				t.Errorf("Expected %s in %q to be %t, got %t", tt.version, tt.spec, tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestParseSpecifierSetErrors(t *testing.T) {
+	for _, spec := range []string{"2.0", "==abc", "~=1", "==1.0rc1.*", ">=1.0,<"} {
+		if _, err := ParseSpecifierSet(spec); err == nil {
+			t.Errorf("Expected an error for %q", spec)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package pypi
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+)
+
+const (
+	// UploadAction is the :action field of legacy upload API file uploads.
+	UploadAction = "file_upload"
+	// uploadErrorLimit bounds how much of an upload error response is kept.
+	uploadErrorLimit = 4096
+)
+
+// UploadError is returned by Upload when the index rejects an upload. The
+// status and message are those of the index, so clients can be told why.
+type UploadError struct {
+	StatusCode int
+	Message    string
+}
+
+// Error implements the error interface.
+func (e *UploadError) Error() string {
+	return fmt.Sprintf("upload rejected with status %d: %s", e.StatusCode, e.Message)
+}
+
+// Upload forwards a legacy upload API form, as sent by twine, to the upload
+// URL of an index, authenticating with the given credentials. Fields and
+// files are streamed as a new multipart body.
+func (c *HTTPClient) Upload(ctx context.Context, uploadURL, username, password string, form *multipart.Form) error {
+	body, bodyWriter := io.Pipe()
+	mw := multipart.NewWriter(bodyWriter)
+	go func() {
+		bodyWriter.CloseWithError(writeUploadForm(mw, form))
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", uploadURL, body)
+	if err != nil {
+		_ = body.CloseWithError(err)
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	if username != "" || password != "" {
+		req.SetBasicAuth(username, password)
+	}
+
+	// Uploads of large wheels take longer than the client timeout allows
+	uploadClient := *c.httpClient
+	uploadClient.Timeout = 0
+	resp, err := uploadClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error making request: %w", err)
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			// Log the error but don't fail the function
+			// This is a common pattern for defer close operations
+			_ = closeErr // explicitly ignore error
+		}
+	}()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, uploadErrorLimit))
+		return &UploadError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(message))}
+	}
+	return nil
+}
+
+// writeUploadForm writes the fields and files of a form and closes the writer.
+func writeUploadForm(mw *multipart.Writer, form *multipart.Form) error {
+	for name, values := range form.Value {
+		for _, value := range values {
+			if err := mw.WriteField(name, value); err != nil {
+				return err
+			}
+		}
+	}
+	for name, headers := range form.File {
+		for _, fh := range headers {
+			if err := writeUploadFile(mw, name, fh); err != nil {
+				return err
+			}
+		}
+	}
+	return mw.Close()
+}
+
+// writeUploadFile copies an uploaded file into a form.
+func writeUploadFile(mw *multipart.Writer, name string, fh *multipart.FileHeader) error {
+	src, err := fh.Open()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = src.Close()
+	}()
+
+	part, err := mw.CreateFormFile(name, fh.Filename)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(part, src)
+	return err
+}
@@ -0,0 +1,88 @@
+package pypi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// uploadForm parses a twine-style upload form.
+func uploadForm(t *testing.T, fields map[string]string, fileName string, content []byte) *multipart.Form {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for name, value := range fields {
+		if err := mw.WriteField(name, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	part, err := mw.CreateFormFile("content", fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := part.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	form, err := multipart.NewReader(&body, mw.Boundary()).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return form
+}
+
+func TestUpload(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "ci-bot" || pass != "upstream-secret" {
+			http.Error(w, "Invalid credentials", http.StatusForbidden)
+			return
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if r.FormValue(":action") != UploadAction || r.FormValue("name") != "demo" {
+			http.Error(w, "Missing fields", http.StatusBadRequest)
+			return
+		}
+		f, fh, err := r.FormFile("content")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		content, _ := io.ReadAll(f)
+		if fh.Filename == "demo-1.0.tar.gz" && string(content) == "sdist" {
+			return
+		}
+		http.Error(w, "File already exists", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	fields := map[string]string{":action": UploadAction, "name": "demo", "version": "1.0"}
+
+	form := uploadForm(t, fields, "demo-1.0.tar.gz", []byte("sdist"))
+	if err := client.Upload(context.Background(), server.URL, "ci-bot", "upstream-secret", form); err != nil {
+		t.Errorf("Expected the upload to succeed, got %v", err)
+	}
+
+	form = uploadForm(t, fields, "demo-0.9.tar.gz", []byte("sdist"))
+	var uploadErr *UploadError
+	err := client.Upload(context.Background(), server.URL, "ci-bot", "upstream-secret", form)
+	if !errors.As(err, &uploadErr) || uploadErr.StatusCode != http.StatusBadRequest || uploadErr.Message != "File already exists" {
+		t.Errorf("Expected the upstream rejection, got %v", err)
+	}
+
+	err = client.Upload(context.Background(), server.URL, "ci-bot", "wrong", form)
+	if !errors.As(err, &uploadErr) || uploadErr.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected the upstream to refuse the credentials, got %v", err)
+	}
+}
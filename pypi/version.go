@@ -0,0 +1,253 @@
+package pypi
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// versionPattern matches PEP 440 versions, including the alternative
+// spellings the specification allows.
+var versionPattern = regexp.MustCompile(`(?i)^v?(?:(?:([0-9]+)!)?([0-9]+(?:\.[0-9]+)*)` +
+	`(?:[-_.]?(a|b|c|rc|alpha|beta|pre|preview)[-_.]?([0-9]*))?` +
+	`(?:-([0-9]+)|[-_.]?(post|rev|r)[-_.]?([0-9]*))?` +
+	`(?:[-_.]?(dev)[-_.]?([0-9]*))?)` +
+	`(?:\+([a-z0-9]+(?:[-_.][a-z0-9]+)*))?$`)
+
+// IsValidVersion reports whether a string is a valid PEP 440 version.
+func IsValidVersion(version string) bool {
+	return versionPattern.MatchString(version)
+}
+
+// Version is a parsed PEP 440 version.
+type Version struct {
+	Epoch   int
+	Release []int
+	// PreLabel is the normalized pre-release label ("a", "b" or "rc"), empty for final releases.
+	PreLabel string
+	Pre      int
+	// Post is the post-release number, or -1 for versions that aren't post-releases.
+	Post int
+	// Dev is the development release number, or -1 for versions that aren't development releases.
+	Dev int
+	// Local holds the lowercase segments of the local version label.
+	Local []string
+}
+
+// ParseVersion parses a PEP 440 version in any of its allowed spellings.
+func ParseVersion(version string) (Version, error) {
+	m := versionPattern.FindStringSubmatch(strings.TrimSpace(version))
+	if m == nil {
+		return Version{}, fmt.Errorf("invalid version %q", version)
+	}
+
+	v := Version{Post: -1, Dev: -1}
+	var err error
+	if m[1] != "" {
+		if v.Epoch, err = strconv.Atoi(m[1]); err != nil {
+			return Version{}, fmt.Errorf("invalid version %q: %w", version, err)
+		}
+	}
+	for _, part := range strings.Split(m[2], ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return Version{}, fmt.Errorf("invalid version %q: %w", version, err)
+		}
+		v.Release = append(v.Release, n)
+	}
+	if m[3] != "" {
+		switch strings.ToLower(m[3]) {
+		case "a", "alpha":
+			v.PreLabel = "a"
+		case "b", "beta":
+			v.PreLabel = "b"
+		default:
+			v.PreLabel = "rc"
+		}
+		if v.Pre, err = optionalNumber(m[4]); err != nil {
+			return Version{}, fmt.Errorf("invalid version %q: %w", version, err)
+		}
+	}
+	switch {
+	case m[5] != "":
+		v.Post, err = optionalNumber(m[5])
+	case m[6] != "":
+		v.Post, err = optionalNumber(m[7])
+	}
+	if err != nil {
+		return Version{}, fmt.Errorf("invalid version %q: %w", version, err)
+	}
+	if m[8] != "" {
+		if v.Dev, err = optionalNumber(m[9]); err != nil {
+			return Version{}, fmt.Errorf("invalid version %q: %w", version, err)
+		}
+	}
+	if m[10] != "" {
+		v.Local = strings.FieldsFunc(strings.ToLower(m[10]), func(r rune) bool {
+			return r == '-' || r == '_' || r == '.'
+		})
+	}
+	return v, nil
+}
+
+// optionalNumber parses a number that PEP 440 allows to be omitted, meaning 0.
+func optionalNumber(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(s)
+}
+
+// IsPrerelease reports whether the version is a pre-release or a development release.
+func (v Version) IsPrerelease() bool {
+	return v.PreLabel != "" || v.Dev >= 0
+}
+
+// IsPostRelease reports whether the version is a post-release.
+func (v Version) IsPostRelease() bool {
+	return v.Post >= 0
+}
+
+// Public returns the version without its local version label.
+func (v Version) Public() Version {
+	v.Local = nil
+	return v
+}
+
+// BaseVersion returns the epoch and release segments of the version.
+func (v Version) BaseVersion() Version {
+	return Version{Epoch: v.Epoch, Release: v.Release, Post: -1, Dev: -1}
+}
+
+// String returns the normalized form of the version.
+func (v Version) String() string {
+	var b strings.Builder
+	if v.Epoch != 0 {
+		fmt.Fprintf(&b, "%d!", v.Epoch)
+	}
+	for i, n := range v.Release {
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(strconv.Itoa(n))
+	}
+	if v.PreLabel != "" {
+		fmt.Fprintf(&b, "%s%d", v.PreLabel, v.Pre)
+	}
+	if v.Post >= 0 {
+		fmt.Fprintf(&b, ".post%d", v.Post)
+	}
+	if v.Dev >= 0 {
+		fmt.Fprintf(&b, ".dev%d", v.Dev)
+	}
+	if len(v.Local) > 0 {
+		b.WriteString("+" + strings.Join(v.Local, "."))
+	}
+	return b.String()
+}
+
+// Compare orders two versions as PEP 440 does, returning -1, 0 or 1.
+func (v Version) Compare(o Version) int {
+	if c := compareInts(v.Epoch, o.Epoch); c != 0 {
+		return c
+	}
+	if c := compareRelease(v.Release, o.Release); c != 0 {
+		return c
+	}
+	if c := compareInts(v.preRank(), o.preRank()); c != 0 {
+		return c
+	}
+	if v.PreLabel != "" && o.PreLabel != "" {
+		if c := compareInts(v.Pre, o.Pre); c != 0 {
+			return c
+		}
+	}
+	// Versions without a post-release sort before those with one
+	if c := compareInts(v.Post, o.Post); c != 0 {
+		return c
+	}
+	// Versions without a development release sort after those with one
+	if c := compareInts(devRank(v.Dev), devRank(o.Dev)); c != 0 {
+		return c
+	}
+	return compareLocal(v.Local, o.Local)
+}
+
+// preRank orders the pre-release phases. Development releases of a final
+// release sort before its pre-releases, which sort before the final release.
+func (v Version) preRank() int {
+	switch {
+	case v.PreLabel == "" && v.Post < 0 && v.Dev >= 0:
+		return 0
+	case v.PreLabel == "a":
+		return 1
+	case v.PreLabel == "b":
+		return 2
+	case v.PreLabel == "rc":
+		return 3
+	default:
+		return 4
+	}
+}
+
+// devRank maps a development release number so that its absence sorts last.
+func devRank(dev int) int {
+	if dev < 0 {
+		return int(^uint(0) >> 1)
+	}
+	return dev
+}
+
+// compareRelease compares release segments, ignoring trailing zeros.
+func compareRelease(a, b []int) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if c := compareInts(x, y); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// compareLocal compares local version labels. Numeric segments sort after
+// alphanumeric ones, and a label sorts after each of its prefixes.
+func compareLocal(a, b []string) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		x, xErr := strconv.Atoi(a[i])
+		y, yErr := strconv.Atoi(b[i])
+		switch {
+		case xErr == nil && yErr == nil:
+			if c := compareInts(x, y); c != 0 {
+				return c
+			}
+		case xErr == nil:
+			return 1
+		case yErr == nil:
+			return -1
+		default:
+			if c := strings.Compare(a[i], b[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return compareInts(len(a), len(b))
+}
+
+// compareInts returns -1, 0 or 1 as a is less than, equal to or greater than b.
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}